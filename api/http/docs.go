@@ -17,22 +17,106 @@ const docTemplate = `{
     "paths": {
         "/": {
             "get": {
-                "description": "Returns an HTML page with all metrics in a table",
+                "description": "Returns all metrics as an HTML dashboard, a JSON array or CSV depending on the Accept header; stale metrics are marked in the dashboard",
                 "consumes": [
                     "text/plain"
                 ],
                 "produces": [
-                    "text/html"
+                    "text/html",
+                    "application/json",
+                    "text/csv"
                 ],
                 "tags": [
                     "metrics"
                 ],
                 "summary": "List all metrics",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Case-insensitive metric name filter",
+                        "name": "q",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Metric type filter (gauge or counter)",
+                        "name": "type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 10,
+                        "description": "Dashboard auto-refresh interval in seconds, 0 disables it",
+                        "name": "refresh",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "All metrics as HTML dashboard, JSON array or CSV",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Metrics"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                }
+            }
+        },
+        "/agents": {
+            "get": {
+                "description": "Returns every reporting source with its last-seen time and status (live, stale or dead)",
+                "consumes": [
+                    "text/plain"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "agents"
+                ],
+                "summary": "List agents",
                 "responses": {
                     "200": {
-                        "description": "HTML table of all metrics",
+                        "description": "Agents",
                         "schema": {
-                            "type": "string"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Agent"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                }
+            }
+        },
+        "/alerts": {
+            "get": {
+                "description": "Returns the current state of every alerting rule",
+                "consumes": [
+                    "text/plain"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "alerts"
+                ],
+                "summary": "List alerts",
+                "responses": {
+                    "200": {
+                        "description": "Alert states",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Alert"
+                            }
                         }
                     },
                     "500": {
@@ -78,6 +162,9 @@ const docTemplate = `{
                     "404": {
                         "description": "Not Found"
                     },
+                    "429": {
+                        "description": "Too Many Requests"
+                    },
                     "500": {
                         "description": "Internal Server Error"
                     }
@@ -130,6 +217,9 @@ const docTemplate = `{
                     "404": {
                         "description": "Not Found"
                     },
+                    "429": {
+                        "description": "Too Many Requests"
+                    },
                     "500": {
                         "description": "Internal Server Error"
                     }
@@ -173,6 +263,9 @@ const docTemplate = `{
                     "404": {
                         "description": "Not Found"
                     },
+                    "429": {
+                        "description": "Too Many Requests"
+                    },
                     "500": {
                         "description": "Internal Server Error"
                     }
@@ -224,12 +317,13 @@ const docTemplate = `{
         },
         "/value/{type}/{id}": {
             "get": {
-                "description": "Retrieves a metric value or delta as plain text",
+                "description": "Retrieves a metric value or delta as plain text, or the full metric as JSON when requested via Accept",
                 "consumes": [
                     "text/plain"
                 ],
                 "produces": [
-                    "text/plain"
+                    "text/plain",
+                    "application/json"
                 ],
                 "tags": [
                     "metrics"
@@ -253,9 +347,9 @@ const docTemplate = `{
                 ],
                 "responses": {
                     "200": {
-                        "description": "Metric value as plain text",
+                        "description": "Metric value as plain text or full metric as JSON",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/models.Metrics"
                         }
                     },
                     "400": {
@@ -272,6 +366,69 @@ const docTemplate = `{
         }
     },
     "definitions": {
+        "models.Agent": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "description": "First time the agent reported (read-only).\n\nread only: true",
+                    "type": "string"
+                },
+                "id": {
+                    "description": "Agent identifier: agent ID or IP address of the reporting host.\n\nrequired: true",
+                    "type": "string",
+                    "example": "192.168.1.10"
+                },
+                "status": {
+                    "description": "Agent status: \"live\", \"stale\" or \"dead\".\n\nenum: live,stale,dead",
+                    "type": "string",
+                    "example": "live"
+                },
+                "updated_at": {
+                    "description": "Last time the agent reported (read-only).\n\nread only: true",
+                    "type": "string"
+                }
+            }
+        },
+        "models.Alert": {
+            "type": "object",
+            "properties": {
+                "active_since": {
+                    "description": "Time the condition started to match (pending and firing alerts).",
+                    "type": "string"
+                },
+                "condition": {
+                    "description": "Human-readable rule condition.",
+                    "type": "string",
+                    "example": "FreeMemory \u003c 1e+09 for 2m0s"
+                },
+                "evaluated_at": {
+                    "description": "Time of the last evaluation.",
+                    "type": "string"
+                },
+                "fired_at": {
+                    "description": "Time the alert started firing.",
+                    "type": "string"
+                },
+                "name": {
+                    "description": "Rule name.\n\nrequired: true",
+                    "type": "string",
+                    "example": "LowMemory"
+                },
+                "resolved_at": {
+                    "description": "Time the alert was resolved.",
+                    "type": "string"
+                },
+                "state": {
+                    "description": "Alert state: \"inactive\", \"pending\", \"firing\" or \"resolved\".\n\nrequired: true\nenum: inactive,pending,firing,resolved",
+                    "type": "string",
+                    "example": "firing"
+                },
+                "value": {
+                    "description": "Metric value observed on the last evaluation.",
+                    "type": "number"
+                }
+            }
+        },
         "models.MetricID": {
             "type": "object",
             "properties": {
@@ -2,18 +2,20 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"log"
 	"net"
+	"net/http"
 	"os"
 	"os/signal"
-	"strconv"
 	"syscall"
 	"time"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/sbilibin2017/gophmetrics/internal/agent"
+	"github.com/sbilibin2017/gophmetrics/internal/config"
 	"github.com/sbilibin2017/gophmetrics/internal/configs/address"
 	"github.com/sbilibin2017/gophmetrics/internal/configs/compressor"
 	"github.com/sbilibin2017/gophmetrics/internal/configs/cryptor"
@@ -22,19 +24,33 @@ import (
 	httpClient "github.com/sbilibin2017/gophmetrics/internal/configs/transport/http"
 	grpcFacades "github.com/sbilibin2017/gophmetrics/internal/facades/grpc"
 	httpFacades "github.com/sbilibin2017/gophmetrics/internal/facades/http"
+	"github.com/sbilibin2017/gophmetrics/internal/facades/multi"
+	httpHandlers "github.com/sbilibin2017/gophmetrics/internal/handlers/http"
+	httpMiddlewares "github.com/sbilibin2017/gophmetrics/internal/middlewares/http"
+	"github.com/sbilibin2017/gophmetrics/internal/queue"
+	"github.com/sbilibin2017/gophmetrics/internal/reload"
 	pb "github.com/sbilibin2017/gophmetrics/pkg/grpc"
 	"github.com/spf13/pflag"
 )
 
 // main is the entry point of the application.
-// It prints build info, parses command-line flags, and runs the agent.
+// It parses the configuration, prints build info, and runs the agent.
 func main() {
-	printBuildInfo()
-
 	err := parseFlags()
+	if errors.Is(err, pflag.ErrHelp) {
+		return
+	}
 	if err != nil {
 		log.Fatal(err)
 	}
+	if cfg.PrintConfig {
+		if err := config.Print(os.Stdout, &cfg); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	printBuildInfo()
 
 	if err := run(context.Background()); err != nil {
 		log.Fatal(err)
@@ -56,149 +72,154 @@ func printBuildInfo() {
 }
 
 var (
-	addr           string
-	pollInterval   string
-	reportInterval string
-	key            string
-	keyHeader      string = "HashSHA256"
-	limit          string
-	cryptoKeyPath  string
-	endpoint       string = "/updates/"
-	configFilePath string
+	keyHeader string = "HashSHA256"
+	endpoint  string = "/updates/"
 )
 
-// init registers command-line flags.
-func init() {
-	pflag.StringVarP(&addr, "address", "a", "http://localhost:8080", "server URL")
-	pflag.StringVarP(&pollInterval, "poll-interval", "p", "2", "poll interval in seconds")
-	pflag.StringVarP(&reportInterval, "report-interval", "r", "10", "report interval in seconds")
-	pflag.StringVarP(&key, "key", "k", "", "key for SHA256 hashing")
-	pflag.StringVarP(&limit, "limit", "l", "5", "max number of concurrent outbound requests")
-	pflag.StringVar(&cryptoKeyPath, "crypto-key", "", "path to PEM file with public key")
-	pflag.StringVarP(&configFilePath, "config", "c", "", "path to JSON config file")
-}
+// cfg is the effective configuration of the agent.
+var cfg config.Agent
 
-// parseFlags parses command-line flags and environment variables,
-// validates them and loads from config file if specified.
-func parseFlags() error {
-	pflag.Parse()
+// receiver collects metrics pushed by local applications.
+var receiver = agent.NewReceiver()
 
-	if len(pflag.Args()) > 0 {
-		return errors.New("unknown flags or arguments are provided")
+// telemetry counts what the agent itself does.
+var telemetry = agent.NewTelemetry()
+
+// keyHasher signs batches sent over HTTP with the current key.
+var keyHasher = hasher.NewReloadable("")
+
+// parseFlags loads the configuration from the defaults, the JSON config file,
+// environment variables and command-line flags, each overriding the previous,
+// and validates it.
+func parseFlags() error {
+	cfg = defaultConfig()
+	if err := config.Load(&cfg, os.Args[1:]); err != nil {
+		return err
 	}
+	if _, err := collectorRegistry().Select(collectorNames()...); err != nil {
+		return fmt.Errorf("invalid collectors value: %w", err)
+	}
+	return nil
+}
+
+// defaultConfig returns the default configuration enabling the default collectors.
+func defaultConfig() config.Agent {
+	c := config.NewAgent()
+	c.Collectors = defaultCollectorNames()
+	return c
+}
 
-	// Load config file path from ENV if not set
-	if env := os.Getenv("CONFIG"); env != "" && configFilePath == "" {
-		configFilePath = env
+// run builds the updater for the configured servers and runs the agent.
+func run(ctx context.Context) error {
+	keyHasher.SetSigningKey(cfg.KeyID, cfg.Key)
+	updater, closeUpdater, err := newUpdater()
+	if err != nil {
+		return err
 	}
+	defer closeUpdater()
 
-	// Load config file if provided
-	if configFilePath != "" {
-		cfgBytes, err := os.ReadFile(configFilePath)
-		if err != nil {
-			return fmt.Errorf("error reading config file: %w", err)
-		}
+	if cfg.BreakerThreshold > 0 {
+		updater = agent.NewBreaker(updater,
+			agent.WithFailureThreshold(cfg.BreakerThreshold),
+			agent.WithOpenTimeout(cfg.BreakerTimeout),
+		)
+	}
 
-		var cfg struct {
-			Address        *string `json:"address,omitempty"`
-			PollInterval   *string `json:"poll_interval,omitempty"`
-			ReportInterval *string `json:"report_interval,omitempty"`
-			Key            *string `json:"key,omitempty"`
-			Limit          *string `json:"limit,omitempty"`
-			CryptoKey      *string `json:"crypto_key,omitempty"`
-		}
+	pollTicker := time.NewTicker(cfg.PollInterval)
+	defer pollTicker.Stop()
 
-		if err := json.Unmarshal(cfgBytes, &cfg); err != nil {
-			return fmt.Errorf("error parsing config JSON: %w", err)
-		}
+	reportTicker := time.NewTicker(cfg.ReportInterval)
+	defer reportTicker.Stop()
 
-		if addr == "" && cfg.Address != nil {
-			addr = *cfg.Address
-		}
-		if pollInterval == "" && cfg.PollInterval != nil {
-			pollInterval = *cfg.PollInterval
-		}
-		if reportInterval == "" && cfg.ReportInterval != nil {
-			reportInterval = *cfg.ReportInterval
-		}
-		if key == "" && cfg.Key != nil {
-			key = *cfg.Key
-		}
-		if limit == "" && cfg.Limit != nil {
-			limit = *cfg.Limit
-		}
-		if cryptoKeyPath == "" && cfg.CryptoKey != nil {
-			cryptoKeyPath = *cfg.CryptoKey
-		}
-	}
+	// Listen for system interrupt signals for graceful shutdown
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
 
-	// Override with environment variables if set
-	if env := os.Getenv("ADDRESS"); env != "" {
-		addr = env
-	}
-	if env := os.Getenv("POLL_INTERVAL"); env != "" {
-		pollInterval = env
-	}
-	if env := os.Getenv("REPORT_INTERVAL"); env != "" {
-		reportInterval = env
-	}
-	if env := os.Getenv("KEY"); env != "" {
-		key = env
-	}
-	if env := os.Getenv("RATE_LIMIT"); env != "" {
-		limit = env
+	opts, err := agentOpts()
+	if err != nil {
+		return err
 	}
-	if env := os.Getenv("CRYPTO_KEY"); env != "" {
-		cryptoKeyPath = env
+	if err := startReceivers(ctx); err != nil {
+		return err
 	}
 
-	// Validate numeric flags
-	if pollInterval != "" {
-		if _, err := strconv.Atoi(pollInterval); err != nil {
-			return errors.New("invalid poll_interval value, must be integer seconds string")
-		}
-	}
-	if reportInterval != "" {
-		if _, err := strconv.Atoi(reportInterval); err != nil {
-			return errors.New("invalid report_interval value, must be integer seconds string")
+	concurrency := agent.NewConcurrency(cfg.Limit)
+	opts = append(opts, agent.WithConcurrency(concurrency))
+
+	go reload.Watch(ctx, cfg.Config, cfg.ConfigWatch, reloadConfig(pollTicker, reportTicker, concurrency))
+
+	return agent.Run(ctx, updater, pollTicker, reportTicker, cfg.Limit, opts...)
+}
+
+// newUpdater creates the updater sending metrics to the configured servers.
+// Several servers are combined according to the address policy.
+// The returned function releases the connections.
+func newUpdater() (agent.Updater, func(), error) {
+	var (
+		endpoints []multi.Endpoint
+		closers   []func()
+	)
+	closeAll := func() {
+		for _, c := range closers {
+			c()
 		}
 	}
-	if limit != "" {
-		i, err := strconv.Atoi(limit)
+
+	for _, a := range cfg.Address {
+		updater, closeUpdater, err := newAddressUpdater(a)
 		if err != nil {
-			return errors.New("invalid limit value, must be an integer")
-		}
-		if i <= 0 {
-			return errors.New("limit must be greater than 0")
+			closeAll()
+			return nil, nil, err
 		}
+		endpoints = append(endpoints, multi.Endpoint{Name: a, Updater: updater})
+		closers = append(closers, closeUpdater)
 	}
 
-	return nil
+	if len(endpoints) == 1 {
+		return endpoints[0].Updater, closeAll, nil
+	}
+
+	updater, err := multi.NewMetricMultiFacade(multi.Policy(cfg.AddressPolicy), endpoints...)
+	if err != nil {
+		closeAll()
+		return nil, nil, fmt.Errorf("invalid address_policy value: %w", err)
+	}
+	return updater, closeAll, nil
 }
 
-// run determines the protocol scheme from the address and runs the appropriate agent.
-func run(ctx context.Context) error {
-	parsedAddr := address.New(addr)
+// newAddressUpdater creates the updater for a single server, choosing the
+// protocol by the address scheme.
+func newAddressUpdater(a string) (agent.Updater, func(), error) {
+	parsedAddr := address.New(a)
 	switch parsedAddr.Scheme {
-	case address.SchemeHTTP:
-		return runHTTP(ctx)
+	case address.SchemeHTTP, address.SchemeHTTPS:
+		updater, err := newHTTPUpdater(a)
+		return updater, func() {}, err
 	case address.SchemeGRPC:
-		return runGRPC(ctx)
+		return newGRPCUpdater(parsedAddr.Address)
 	default:
-		return address.ErrUnsupportedScheme
+		return nil, nil, address.ErrUnsupportedScheme
 	}
 }
 
-// runHTTP runs the agent in HTTP mode.
+// newHTTPUpdater creates an HTTP updater.
 // It configures the HTTP client, optional crypto and hashing, and
 // creates a metric updater with the agent's outbound IP included in X-Real-IP header.
-func runHTTP(ctx context.Context) error {
-	pollInt, _ := strconv.Atoi(pollInterval)
-	reportInt, _ := strconv.Atoi(reportInterval)
-	limitInt, _ := strconv.Atoi(limit)
+func newHTTPUpdater(a string) (agent.Updater, error) {
+	var rootCA []byte
+	if cfg.TLSCA != "" {
+		pem, err := os.ReadFile(cfg.TLSCA)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read TLS CA certificate: %w", err)
+		}
+		if !x509.NewCertPool().AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.TLSCA)
+		}
+		rootCA = pem
+	}
 
 	client := httpClient.New(
-		addr,
+		a,
 		httpClient.WithRetryPolicy(
 			httpClient.RetryPolicy{
 				Count:   3,
@@ -206,60 +227,51 @@ func runHTTP(ctx context.Context) error {
 				MaxWait: 5 * time.Second,
 			},
 		),
+		httpClient.WithHeader("X-Agent-ID", cfg.AgentID),
+		httpClient.WithHeader("Authorization", authorization()),
+		httpClient.WithRootCertificate(rootCA),
 	)
 
-	var h *hasher.Hasher
-	if key != "" {
-		h = hasher.New(key)
-	}
-
 	c := compressor.NewCompressor()
 
-	var (
-		cr  *cryptor.Cryptor
-		err error
-	)
-	if cryptoKeyPath != "" {
-		cr, err = cryptor.New(cryptor.WithPublicKeyPath(cryptoKeyPath))
+	// The facade checks the cryptor for nil, so it is declared as an
+	// interface to avoid passing a typed nil pointer.
+	var cr httpFacades.Cryptor
+	if cfg.CryptoKey != "" {
+		pub, err := cryptor.New(cryptor.WithPublicKeyPath(cfg.CryptoKey))
 		if err != nil {
-			return fmt.Errorf("failed to load public key for cryptor: %w", err)
+			return nil, fmt.Errorf("failed to load public key for cryptor: %w", err)
 		}
+		cr = pub
 	}
 
 	// Determine outbound IP address for X-Real-IP header
 	conn, err := net.Dial("udp", "8.8.8.8:80")
 	if err != nil {
-		return fmt.Errorf("failed to determine outbound IP: %w", err)
+		return nil, fmt.Errorf("failed to determine outbound IP: %w", err)
 	}
 	defer conn.Close()
 	localAddr := conn.LocalAddr().(*net.UDPAddr)
 	agentIP := localAddr.IP.String()
 
 	// Create the MetricHTTPFacade that adds X-Real-IP header with agentIP
-	updater := httpFacades.NewMetricHTTPFacade(client, c, h, cr, key, keyHeader, endpoint, agentIP)
-
-	pollTicker := time.NewTicker(time.Duration(pollInt) * time.Second)
-	defer pollTicker.Stop()
-
-	reportTicker := time.NewTicker(time.Duration(reportInt) * time.Second)
-	defer reportTicker.Stop()
-
-	// Listen for system interrupt signals for graceful shutdown
-	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM, syscall.SIGINT)
-	defer stop()
-
-	return agent.Run(ctx, updater, pollTicker, reportTicker, limitInt)
+	return httpFacades.NewMetricHTTPFacade(client, c, keyHasher, cr, keyHeader, endpoint, agentIP), nil
 }
 
-// runGRPC runs the agent in GRPC mode.
-func runGRPC(ctx context.Context) error {
-	pollInt, _ := strconv.Atoi(pollInterval)
-	reportInt, _ := strconv.Atoi(reportInterval)
-	limitInt, _ := strconv.Atoi(limit)
+// authorization returns the Authorization value carrying the API token, or ""
+// if no token is configured.
+func authorization() string {
+	if cfg.AuthToken == "" {
+		return ""
+	}
+	return "Bearer " + cfg.AuthToken
+}
 
+// newGRPCUpdater creates a gRPC updater and returns a function closing its connection.
+func newGRPCUpdater(target string) (agent.Updater, func(), error) {
 	// Setup gRPC client connection with retry policy
 	conn, err := grpcClient.New(
-		addr,
+		target,
 		grpcClient.WithRetryPolicy(
 			grpcClient.RetryPolicy{
 				Count:   3,
@@ -267,25 +279,201 @@ func runGRPC(ctx context.Context) error {
 				MaxWait: 5 * time.Second,
 			},
 		),
+		grpcClient.WithMetadata("x-agent-id", cfg.AgentID),
+		grpcClient.WithMetadata("authorization", authorization()),
 	)
 	if err != nil {
-		return fmt.Errorf("failed to create grpc connection: %w", err)
+		return nil, nil, fmt.Errorf("failed to create grpc connection: %w", err)
 	}
-	defer conn.Close()
 
 	// Create the gRPC client facade
 	client := pb.NewMetricWriteServiceClient(conn)
-	updater := grpcFacades.NewMetricGRPCFacade(client)
+	return grpcFacades.NewMetricGRPCFacade(client), func() { conn.Close() }, nil
+}
 
-	pollTicker := time.NewTicker(time.Duration(pollInt) * time.Second)
-	defer pollTicker.Stop()
+// agentOpts builds optional agent settings from the configuration.
+// When a spool directory is set, failed batches are kept on disk.
+func agentOpts() ([]agent.Opt, error) {
+	opts := []agent.Opt{agent.WithTelemetry(telemetry)}
 
-	reportTicker := time.NewTicker(time.Duration(reportInt) * time.Second)
-	defer reportTicker.Stop()
+	if names := collectorNames(); len(names) > 0 {
+		enabled, err := collectorRegistry().Select(names...)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, agent.WithCollectors(enabled...))
+	}
 
-	// Listen for system interrupt signals for graceful shutdown
-	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM, syscall.SIGINT)
-	defer stop()
+	opts = append(opts, agent.WithMaxBatch(cfg.MaxBatchCount, cfg.MaxBatchBytes))
+
+	if cfg.SpoolDir == "" {
+		return opts, nil
+	}
+
+	spool, err := queue.New(cfg.SpoolDir, cfg.SpoolMax)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open spool: %w", err)
+	}
+	if n := spool.Len(); n > 0 {
+		log.Printf("spool %s holds %d batches to replay", cfg.SpoolDir, n)
+	}
+
+	return append(opts, agent.WithSpool(spool)), nil
+}
+
+// startReceivers starts the local endpoints: the server's update handlers
+// applications push metrics to over HTTP, StatsD over UDP and the agent's own
+// metrics on /metrics. They are stopped when ctx is done.
+func startReceivers(ctx context.Context) error {
+	if cfg.ReceiverAddress != "" {
+		r := chi.NewRouter()
+		r.Use(httpMiddlewares.GzipMiddleware)
+		r.Post("/update/{type}/{name}/{value}", httpHandlers.NewMetricUpdatePathHandler(receiver))
+		r.Post("/update/", httpHandlers.NewMetricUpdateBodyHandler(receiver))
+		r.Post("/updates/", httpHandlers.NewMetricUpdatesBodyHandler(receiver))
+
+		ln, err := serveHTTP(ctx, cfg.ReceiverAddress, r)
+		if err != nil {
+			return fmt.Errorf("failed to start receiver: %w", err)
+		}
+		log.Printf("receiving metrics on http://%s", ln.Addr())
+	}
+
+	if cfg.MetricsAddress != "" {
+		r := chi.NewRouter()
+		r.Get("/metrics", telemetry.ServeHTTP)
+
+		ln, err := serveHTTP(ctx, cfg.MetricsAddress, r)
+		if err != nil {
+			return fmt.Errorf("failed to start metrics endpoint: %w", err)
+		}
+		log.Printf("serving agent metrics on http://%s/metrics", ln.Addr())
+	}
+
+	if cfg.StatsDAddress != "" {
+		conn, err := net.ListenPacket("udp", cfg.StatsDAddress)
+		if err != nil {
+			return fmt.Errorf("failed to start statsd receiver: %w", err)
+		}
+		go func() {
+			if err := receiver.ServeStatsD(ctx, conn); err != nil {
+				log.Printf("statsd receiver stopped: %v", err)
+			}
+		}()
+		log.Printf("receiving statsd metrics on udp://%s", conn.LocalAddr())
+	}
+
+	return nil
+}
+
+// serveHTTP serves h on addr until ctx is done.
+func serveHTTP(ctx context.Context, addr string, h http.Handler) (net.Listener, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	srv := &http.Server{Handler: h, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		<-ctx.Done()
+		srv.Shutdown(context.Background())
+	}()
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("http server on %s stopped: %v", addr, err)
+		}
+	}()
+	return ln, nil
+}
 
-	return agent.Run(ctx, updater, pollTicker, reportTicker, limitInt)
+// defaultCollectorNames returns the names of the collectors enabled by
+// default: the built-in ones and the agent's own telemetry.
+func defaultCollectorNames() []string {
+	var names []string
+	for _, c := range agent.DefaultCollectors() {
+		names = append(names, c.Name())
+	}
+	return append(names, agent.TelemetryCollectorName)
+}
+
+// collectorNames returns the names of the enabled collectors.
+// The process, scrape and receiver collectors are enabled whenever they have
+// targets or listen addresses.
+func collectorNames() []string {
+	targeted := map[string]bool{
+		agent.ProcessCollectorName:  len(cfg.WatchProcess) > 0,
+		agent.ScrapeCollectorName:   len(cfg.ScrapeTargets) > 0,
+		agent.ReceiverCollectorName: cfg.ReceiverAddress != "" || cfg.StatsDAddress != "",
+	}
+
+	var names []string
+	for _, name := range cfg.Collectors {
+		if _, ok := targeted[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	for _, name := range []string{agent.ProcessCollectorName, agent.ScrapeCollectorName, agent.ReceiverCollectorName} {
+		if targeted[name] {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// collectorRegistry returns the built-in collectors, with the runtime/metrics
+// collector limited to the selected samples, the agent's telemetry and the
+// process, scrape and receiver collectors when they have targets or listen
+// addresses.
+func collectorRegistry() *agent.Registry {
+	collectors := agent.DefaultCollectors()
+	for i, c := range collectors {
+		switch c.Name() {
+		case agent.SystemCollectorName:
+			collectors[i] = agent.NewSystemCollector(systemOpts()...)
+		case agent.RuntimeMetricsCollectorName:
+			collectors[i] = agent.NewRuntimeMetricsCollector(cfg.RuntimeMetrics...)
+		}
+	}
+	collectors = append(collectors, agent.NewRuntimeCollector(), agent.NewHostCollector(), telemetry)
+	if len(cfg.WatchProcess) > 0 {
+		collectors = append(collectors, agent.NewProcessCollector(cfg.WatchProcess...))
+	}
+	if len(cfg.ScrapeTargets) > 0 {
+		collectors = append(collectors, agent.NewScrapeCollector(cfg.ScrapeTargets...))
+	}
+	if cfg.ReceiverAddress != "" || cfg.StatsDAddress != "" {
+		collectors = append(collectors, receiver)
+	}
+	return agent.NewRegistry(collectors...)
+}
+
+// systemOpts builds the system collector settings from the configuration.
+func systemOpts() []agent.SystemOpt {
+	var opts []agent.SystemOpt
+	if cfg.CPUModes {
+		opts = append(opts, agent.WithCPUModes())
+	}
+	if cfg.LegacyCPUNames {
+		opts = append(opts, agent.WithLegacyCPUNames())
+	}
+	return opts
+}
+
+// reloadConfig returns a function loading the configuration again and
+// applying the settings that can change while the agent runs: the poll and
+// report intervals, the limit of concurrent requests and the hash key. The
+// configuration is validated as a whole, so a bad file changes nothing.
+func reloadConfig(pollTicker, reportTicker *time.Ticker, concurrency *agent.Concurrency) func() error {
+	return func() error {
+		next := defaultConfig()
+		if err := config.Load(&next, os.Args[1:]); err != nil {
+			return err
+		}
+
+		pollTicker.Reset(next.PollInterval)
+		reportTicker.Reset(next.ReportInterval)
+		concurrency.Set(next.Limit)
+		keyHasher.SetSigningKey(next.KeyID, next.Key)
+		return nil
+	}
 }
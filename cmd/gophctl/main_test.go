@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun_SQLiteRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "metrics.db")
+	in := filepath.Join(dir, "in.ndjson")
+	out := filepath.Join(dir, "out.ndjson")
+
+	require.NoError(t, os.WriteFile(in, []byte(`{"id":"Alloc","type":"gauge","value":1.5}
+{"id":"PollCount","type":"counter","delta":3}
+{"id":"Alloc","type":"gauge","value":2.5}
+`), 0644))
+
+	require.NoError(t, run(ctx, []string{"import", "--to", "sqlite:" + dbPath, "--in", in}))
+	require.NoError(t, run(ctx, []string{"export", "--from", "sqlite:" + dbPath, "--out", out}))
+
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], `"id":"Alloc","type":"gauge","value":2.5`)
+	assert.Contains(t, lines[1], `"id":"PollCount","type":"counter","delta":3`)
+}
+
+func TestRun_Errors(t *testing.T) {
+	ctx := context.Background()
+
+	assert.ErrorIs(t, run(ctx, nil), errUsage)
+	assert.ErrorIs(t, run(ctx, []string{"unknown"}), errUsage)
+	assert.EqualError(t, run(ctx, []string{"export"}), "--from is required")
+	assert.EqualError(t, run(ctx, []string{"import", "--to", "sqlite"}), `invalid backend "sqlite", expected KIND:TARGET`)
+	assert.EqualError(t, run(ctx, []string{"import", "--to", "mysql:dsn"}), `unsupported backend "mysql"`)
+	assert.EqualError(t, run(ctx, []string{"import", "--to", "http://localhost:8080"}), "backend http is read-only")
+}
@@ -2,34 +2,42 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
+	"math"
 	"net"
 	"net/http"
 	"os"
-	"strconv"
-	"strings"
 	"sync"
 	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/go-resty/resty/v2"
 	"github.com/jmoiron/sqlx"
 	"github.com/pressly/goose"
+	"github.com/sbilibin2017/gophmetrics/internal/alerts"
+	"github.com/sbilibin2017/gophmetrics/internal/auth"
+	"github.com/sbilibin2017/gophmetrics/internal/config"
 	"github.com/sbilibin2017/gophmetrics/internal/configs/address"
 	"github.com/sbilibin2017/gophmetrics/internal/configs/db"
 	"github.com/sbilibin2017/gophmetrics/internal/configs/hasher"
 	"github.com/sbilibin2017/gophmetrics/internal/models"
+	"github.com/sbilibin2017/gophmetrics/internal/ratelimit"
+	"github.com/sbilibin2017/gophmetrics/internal/reload"
+	"github.com/sbilibin2017/gophmetrics/internal/replay"
 	"github.com/sbilibin2017/gophmetrics/internal/repositories/file"
 	"github.com/sbilibin2017/gophmetrics/internal/repositories/memory"
 	"github.com/sbilibin2017/gophmetrics/internal/services"
 	"github.com/sbilibin2017/gophmetrics/internal/worker"
 	"github.com/spf13/pflag"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 
+	httpFacades "github.com/sbilibin2017/gophmetrics/internal/facades/http"
 	grpcHandlers "github.com/sbilibin2017/gophmetrics/internal/handlers/grpc"
 	httpHandlers "github.com/sbilibin2017/gophmetrics/internal/handlers/http"
+	grpcInterceptors "github.com/sbilibin2017/gophmetrics/internal/interceptors/grpc"
 	httpMiddlewares "github.com/sbilibin2017/gophmetrics/internal/middlewares/http"
 	dbRepo "github.com/sbilibin2017/gophmetrics/internal/repositories/db"
 	pb "github.com/sbilibin2017/gophmetrics/pkg/grpc"
@@ -37,12 +45,21 @@ import (
 
 // Application entry point.
 func main() {
-	printBuildInfo()
-
 	err := parseFlags()
+	if errors.Is(err, pflag.ErrHelp) {
+		return
+	}
 	if err != nil {
 		log.Fatal(err)
 	}
+	if cfg.PrintConfig {
+		if err := config.Print(os.Stdout, &cfg); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	printBuildInfo()
 
 	if err := run(context.Background()); err != nil {
 		log.Fatal(err)
@@ -65,151 +82,90 @@ func printBuildInfo() {
 }
 
 var (
-	addr            string
-	storeInterval   string
-	fileStoragePath string
-	restore         string
-	databaseDSN     string
-	migrationsDir   string = "migrations"
-	key             string
-	keyHeader       string = "HashSHA256"
-	cryptoKeyPath   string
-	configFilePath  string
-	trustedSubnet   string
+	migrationsDir string = "migrations"
+	keyHeader     string = "HashSHA256"
 )
 
-// init sets up command-line flags.
-func init() {
-	pflag.StringVarP(&addr, "address", "a", "localhost:8080", "server URL")
-	pflag.StringVarP(&storeInterval, "interval", "i", "300", "interval in seconds to save metrics (0 = sync save)")
-	pflag.StringVarP(&fileStoragePath, "file", "f", "metrics.json", "file path to store metrics")
-	pflag.StringVarP(&restore, "restore", "r", "", "restore metrics from file on startup")
-	pflag.StringVarP(&databaseDSN, "database-dsn", "d", "", "PostgreSQL DSN connection string")
-	pflag.StringVarP(&key, "key", "k", "", "key for SHA256 hashing")
-	pflag.StringVar(&cryptoKeyPath, "crypto-key", "", "path to file with private key for hashing")
-	pflag.StringVarP(&configFilePath, "config", "c", "", "path to JSON config file")
-	pflag.StringVarP(&trustedSubnet, "trusted-subnet", "t", "", "trusted subnet in CIDR notation")
-}
-
-func parseFlags() error {
-	pflag.Parse()
+// cfg is the effective configuration of the server.
+var cfg config.Server
 
-	if len(pflag.Args()) > 0 {
-		return errors.New("unknown flags or arguments are provided")
-	}
-
-	if env := os.Getenv("CONFIG"); env != "" && configFilePath == "" {
-		configFilePath = env
-	}
+// hashMiddleware and subnetMiddleware check HTTP requests with the current
+// hash key and trusted subnet, which can be reloaded while the server runs.
+var (
+	hashMiddleware   *httpMiddlewares.ReloadableMiddleware
+	subnetMiddleware *httpMiddlewares.ReloadableMiddleware
+)
 
-	if configFilePath != "" {
-		cfgBytes, err := os.ReadFile(configFilePath)
-		if err != nil {
-			return fmt.Errorf("error reading config file: %w", err)
-		}
+// limiter and quota limit the requests and the distinct metrics of each client.
+// proxies are the reverse proxies trusted to name the client in X-Real-IP.
+var (
+	limiter *ratelimit.Limiter
+	quota   *ratelimit.Quota
+	proxies ratelimit.Proxies
+)
 
-		var cfg struct {
-			Address       *string `json:"address,omitempty"`
-			Restore       *string `json:"restore,omitempty"`
-			StoreInterval *string `json:"store_interval,omitempty"`
-			StoreFile     *string `json:"store_file,omitempty"`
-			DatabaseDSN   *string `json:"database_dsn,omitempty"`
-			CryptoKey     *string `json:"crypto_key,omitempty"`
-			TrustedSubnet *string `json:"trusted_subnet,omitempty"`
-		}
+// tokens are the API tokens clients authenticate with.
+var tokens *auth.Tokens
 
-		if err := json.Unmarshal(cfgBytes, &cfg); err != nil {
-			return fmt.Errorf("error parsing config JSON: %w", err)
-		}
+// replayGuard rejects stale and replayed signed requests. It outlives
+// reloads of the hash middleware so the nonces seen are kept.
+var replayGuard *replay.Guard
 
-		if addr == "" && cfg.Address != nil {
-			addr = *cfg.Address
-		}
-		if restore == "" && cfg.Restore != nil {
-			restore = *cfg.Restore
-		}
-		if storeInterval == "" && cfg.StoreInterval != nil {
-			storeInterval = *cfg.StoreInterval
-		}
-		if fileStoragePath == "" && cfg.StoreFile != nil {
-			fileStoragePath = *cfg.StoreFile
-		}
-		if databaseDSN == "" && cfg.DatabaseDSN != nil {
-			databaseDSN = *cfg.DatabaseDSN
-		}
-		if cryptoKeyPath == "" && cfg.CryptoKey != nil {
-			cryptoKeyPath = *cfg.CryptoKey
-		}
-		if trustedSubnet == "" && cfg.TrustedSubnet != nil {
-			trustedSubnet = *cfg.TrustedSubnet
-		}
+// parseFlags loads the configuration from the defaults, the JSON config file,
+// environment variables and command-line flags, each overriding the previous,
+// and validates it.
+func parseFlags() error {
+	cfg = config.NewServer()
+	if err := config.Load(&cfg, os.Args[1:]); err != nil {
+		return err
 	}
+	return httpMiddlewares.SetLogLevel(cfg.LogLevel)
+}
 
-	// env vars - имеют приоритет выше конфигурационного файла
-	if env := os.Getenv("ADDRESS"); env != "" {
-		addr = env
-	}
-	if env := os.Getenv("STORE_INTERVAL"); env != "" {
-		storeInterval = env
-	}
-	if env := os.Getenv("FILE_STORAGE_PATH"); env != "" {
-		fileStoragePath = env
-	}
-	if env := os.Getenv("RESTORE"); env != "" {
-		restore = env
-	}
-	if env := os.Getenv("DATABASE_DSN"); env != "" {
-		databaseDSN = env
-	}
-	if env := os.Getenv("KEY"); env != "" {
-		key = env
-	}
-	if env := os.Getenv("CRYPTO_KEY"); env != "" {
-		cryptoKeyPath = env
-	}
-	if env := os.Getenv("TRUSTED_SUBNET"); env != "" {
-		trustedSubnet = env
+// run starts the server with appropriate storage backend and middleware.
+// The config file is reloaded on SIGHUP and, if config_watch is set, when it changes.
+func run(ctx context.Context) error {
+	replayGuard = replay.NewGuard(cfg.ReplayWindow, cfg.ReplayCache, replay.WithRequired(cfg.ReplayRequired))
+	keyring, err := hasher.NewKeyring(cfg.Keys)
+	if err != nil {
+		return fmt.Errorf("invalid keys value: %w", err)
 	}
-
-	if restore != "" {
-		switch strings.ToLower(restore) {
-		case "true", "false":
-		default:
-			return errors.New("invalid restore value, must be 'true' or 'false'")
-		}
+	hashMiddleware = httpMiddlewares.NewReloadableMiddleware(newHashMiddleware(cfg.Key, keyring))
+	subnetMiddleware = httpMiddlewares.NewReloadableMiddleware(httpMiddlewares.TrustedSubnetMiddleware(cfg.TrustedSubnet))
+	limiter = ratelimit.NewLimiter(cfg.RequestRate, cfg.RequestBurst)
+	if proxies, err = ratelimit.ParseProxies(cfg.TrustedProxies); err != nil {
+		return fmt.Errorf("invalid trusted_proxies value: %w", err)
 	}
-
-	if storeInterval != "" {
-		if _, err := strconv.Atoi(storeInterval); err != nil {
-			return errors.New("invalid store_interval value, must be integer seconds string")
-		}
+	quota = ratelimit.NewQuota(cfg.MetricQuota)
+	t, err := auth.NewTokens(cfg.AuthTokens)
+	if err != nil {
+		return fmt.Errorf("invalid tokens value: %w", err)
 	}
+	tokens = t
 
-	return nil
-}
+	go reload.Watch(ctx, cfg.Config, cfg.ConfigWatch, reloadConfig)
 
-// run starts the server with appropriate storage backend and middleware.
-func run(ctx context.Context) error {
+	addr := cfg.Address
 	parsedAddr := address.New(addr)
 	switch parsedAddr.Scheme {
 	case address.SchemeHTTP:
 		switch {
-		case databaseDSN != "" && fileStoragePath != "":
+		case cfg.DatabaseDSN != "" && cfg.FileStoragePath != "":
 			return runDBWithWorkerHTTP(ctx, addr)
-		case databaseDSN != "" && fileStoragePath == "":
+		case cfg.DatabaseDSN != "" && cfg.FileStoragePath == "":
 			return runDBHTTP(ctx, addr)
-		case fileStoragePath != "":
+		case cfg.FileStoragePath != "":
 			return runFileHTTP(ctx, addr)
 		default:
 			return runMemoryHTTP(ctx, addr)
 		}
 	case address.SchemeGRPC:
 		switch {
-		case databaseDSN != "" && fileStoragePath != "":
+		case cfg.DatabaseDSN != "" && cfg.FileStoragePath != "":
 			return runDBWithWorkerGRPC(ctx, addr)
-		case databaseDSN != "" && fileStoragePath == "":
+		case cfg.DatabaseDSN != "" && cfg.FileStoragePath == "":
 			return runDBGRPC(ctx, addr)
-		case fileStoragePath != "":
+		case cfg.FileStoragePath != "":
 			return runFileGRPC(ctx, addr)
 		default:
 			return runMemoryGRPC(ctx, addr)
@@ -224,27 +180,43 @@ func runMemoryHTTP(ctx context.Context, addr string) error {
 	data := make(map[models.MetricID]models.Metrics)
 	writer := memory.NewMetricWriteRepository(data)
 	reader := memory.NewMetricReadRepository(data)
-	service := services.NewMetricService(writer, reader)
+	history := newMetricHistory()
+	service := services.NewMetricService(writer, reader, services.WithHistory(history), services.WithQuota(quota))
 
-	hasher := hasher.New(key)
+	alertEngine, err := startAlerts(ctx, service)
+	if err != nil {
+		return err
+	}
+
+	agentService := newAgentService()
 
 	r := chi.NewRouter()
 	r.Use(httpMiddlewares.LoggingMiddleware)
-	r.Use(httpMiddlewares.GzipMiddleware)
-	r.Use(httpMiddlewares.HashMiddleware(hasher, keyHeader))
-	r.Use(httpMiddlewares.TrustedSubnetMiddleware(trustedSubnet))
-
-	r.Post("/update/{type}/{name}/{value}", httpHandlers.NewMetricUpdatePathHandler(service))
-	r.Post("/update/", httpHandlers.NewMetricUpdateBodyHandler(service))
-	r.Post("/updates/", httpHandlers.NewMetricUpdatesBodyHandler(service))
-	r.Get("/value/{type}/{id}", httpHandlers.NewMetricGetPathHandler(service))
-	r.Post("/value/", httpHandlers.NewMetricGetBodyHandler(service))
-	r.Get("/", httpHandlers.NewMetricListHTMLHandler(service))
+	// Requests are limited by address before the subnet and token checks, so
+	// that rejected requests are limited too.
+	r.Use(httpMiddlewares.RateLimitMiddleware(limiter, proxies))
+	r.Use(httpMiddlewares.BodyLimitMiddleware(cfg.MaxBodySize))
+	r.Use(httpMiddlewares.GzipLimitMiddleware(cfg.MaxBodySize))
+	r.Use(hashMiddleware.Handler)
+	r.Use(subnetMiddleware.Handler)
+
+	// Only authorized updates are recorded as agent reports.
+	write := r.With(requireScope(auth.ScopeWrite), httpMiddlewares.AgentTrackingMiddleware(agentService))
+	write.Post("/update/{type}/{name}/{value}", httpHandlers.NewMetricUpdatePathHandler(service))
+	write.Post("/update/", httpHandlers.NewMetricUpdateBodyHandler(service))
+	write.Post("/updates/", httpHandlers.NewMetricUpdatesBodyHandler(service))
+	r.With(requireScope(auth.ScopeRead)).Get("/value/{type}/{id}", httpHandlers.NewMetricGetPathHandler(service))
+	r.With(requireScope(auth.ScopeRead)).Post("/value/", httpHandlers.NewMetricGetBodyHandler(service))
+	r.With(requireScope(auth.ScopeRead)).Get("/", httpHandlers.NewMetricListHandler(service, cfg.AgentStaleAfter, history))
+	r.With(requireScope(auth.ScopeRead)).Get("/agents", httpHandlers.NewAgentListHandler(agentService))
+	if alertEngine != nil {
+		r.With(requireScope(auth.ScopeRead)).Get("/alerts", httpHandlers.NewAlertListHandler(alertEngine))
+	}
 
 	server := &http.Server{Addr: addr, Handler: r}
 	errCh := make(chan error, 1)
 	go func() {
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		if err := listenAndServe(server); err != nil && err != http.ErrServerClosed {
 			errCh <- err
 		}
 	}()
@@ -262,50 +234,60 @@ func runMemoryHTTP(ctx context.Context, addr string) error {
 
 // runFileHTTP starts a server using file-based metric storage and periodic sync.
 func runFileHTTP(ctx context.Context, addr string) error {
-	writer := file.NewMetricWriteRepository(fileStoragePath)
-	reader := file.NewMetricReadRepository(fileStoragePath)
-	service := services.NewMetricService(writer, reader)
+	writer := file.NewMetricWriteRepository(cfg.FileStoragePath)
+	reader := file.NewMetricReadRepository(cfg.FileStoragePath)
+	history := newMetricHistory()
+	service := services.NewMetricService(writer, reader, services.WithHistory(history), services.WithQuota(quota))
+
+	alertEngine, err := startAlerts(ctx, service)
+	if err != nil {
+		return err
+	}
 
-	hasher := hasher.New(key)
+	agentService := newAgentService()
 
 	r := chi.NewRouter()
 	r.Use(httpMiddlewares.LoggingMiddleware)
-	r.Use(httpMiddlewares.GzipMiddleware)
-	r.Use(httpMiddlewares.HashMiddleware(hasher, keyHeader))
-	r.Use(httpMiddlewares.TrustedSubnetMiddleware(trustedSubnet))
-
-	r.Post("/update/{type}/{name}/{value}", httpHandlers.NewMetricUpdatePathHandler(service))
-	r.Post("/update/", httpHandlers.NewMetricUpdateBodyHandler(service))
-	r.Post("/updates/", httpHandlers.NewMetricUpdatesBodyHandler(service))
-	r.Get("/value/{type}/{id}", httpHandlers.NewMetricGetPathHandler(service))
-	r.Post("/value/", httpHandlers.NewMetricGetBodyHandler(service))
-	r.Get("/", httpHandlers.NewMetricListHTMLHandler(service))
+	// Requests are limited by address before the subnet and token checks, so
+	// that rejected requests are limited too.
+	r.Use(httpMiddlewares.RateLimitMiddleware(limiter, proxies))
+	r.Use(httpMiddlewares.BodyLimitMiddleware(cfg.MaxBodySize))
+	r.Use(httpMiddlewares.GzipLimitMiddleware(cfg.MaxBodySize))
+	r.Use(hashMiddleware.Handler)
+	r.Use(subnetMiddleware.Handler)
+
+	// Only authorized updates are recorded as agent reports.
+	write := r.With(requireScope(auth.ScopeWrite), httpMiddlewares.AgentTrackingMiddleware(agentService))
+	write.Post("/update/{type}/{name}/{value}", httpHandlers.NewMetricUpdatePathHandler(service))
+	write.Post("/update/", httpHandlers.NewMetricUpdateBodyHandler(service))
+	write.Post("/updates/", httpHandlers.NewMetricUpdatesBodyHandler(service))
+	r.With(requireScope(auth.ScopeRead)).Get("/value/{type}/{id}", httpHandlers.NewMetricGetPathHandler(service))
+	r.With(requireScope(auth.ScopeRead)).Post("/value/", httpHandlers.NewMetricGetBodyHandler(service))
+	r.With(requireScope(auth.ScopeRead)).Get("/", httpHandlers.NewMetricListHandler(service, cfg.AgentStaleAfter, history))
+	r.With(requireScope(auth.ScopeRead)).Get("/agents", httpHandlers.NewAgentListHandler(agentService))
+	if alertEngine != nil {
+		r.With(requireScope(auth.ScopeRead)).Get("/alerts", httpHandlers.NewAlertListHandler(alertEngine))
+	}
 
 	server := &http.Server{Addr: addr, Handler: r}
 
 	var ticker *time.Ticker
-	intervalSeconds, _ := strconv.Atoi(storeInterval)
-	if intervalSeconds > 0 {
-		ticker = time.NewTicker(time.Duration(intervalSeconds) * time.Second)
+	if cfg.StoreInterval > 0 {
+		ticker = time.NewTicker(cfg.StoreInterval)
 		defer ticker.Stop()
 	}
 
-	var restoreBool bool
-	if restore == "true" {
-		restoreBool = true
-	}
-
 	errCh := make(chan error, 2)
 	var wg sync.WaitGroup
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		if err := worker.Run(ctx, restoreBool, ticker, reader, writer, reader, writer); err != nil {
+		if err := worker.Run(ctx, cfg.Restore, ticker, reader, writer, reader, writer); err != nil {
 			errCh <- err
 		}
 	}()
 	go func() {
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		if err := listenAndServe(server); err != nil && err != http.ErrServerClosed {
 			errCh <- err
 		}
 	}()
@@ -318,14 +300,14 @@ func runFileHTTP(ctx context.Context, addr string) error {
 
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
-	err := server.Shutdown(shutdownCtx)
+	err = server.Shutdown(shutdownCtx)
 	wg.Wait()
 	return err
 }
 
 // runDBHTTP starts a server using PostgreSQL-based storage with health check.
 func runDBHTTP(ctx context.Context, addr string) error {
-	dbConn, err := db.New("pgx", databaseDSN)
+	dbConn, err := db.New("pgx", cfg.DatabaseDSN)
 	if err != nil {
 		return err
 	}
@@ -337,28 +319,44 @@ func runDBHTTP(ctx context.Context, addr string) error {
 
 	writer := dbRepo.NewMetricWriteRepository(dbConn)
 	reader := dbRepo.NewMetricReadRepository(dbConn)
-	service := services.NewMetricService(writer, reader)
+	history := newMetricHistory()
+	service := services.NewMetricService(writer, reader, services.WithHistory(history), services.WithQuota(quota))
 
-	hasher := hasher.New(key)
+	alertEngine, err := startAlerts(ctx, service)
+	if err != nil {
+		return err
+	}
+
+	agentService := newAgentService()
 
 	r := chi.NewRouter()
 	r.Use(httpMiddlewares.LoggingMiddleware)
-	r.Use(httpMiddlewares.GzipMiddleware)
-	r.Use(httpMiddlewares.HashMiddleware(hasher, keyHeader))
-	r.Use(httpMiddlewares.TrustedSubnetMiddleware(trustedSubnet))
-
-	r.Post("/update/{type}/{name}/{value}", httpHandlers.NewMetricUpdatePathHandler(service))
-	r.Post("/update/", httpHandlers.NewMetricUpdateBodyHandler(service))
-	r.Post("/updates/", httpHandlers.NewMetricUpdatesBodyHandler(service))
-	r.Get("/value/{type}/{id}", httpHandlers.NewMetricGetPathHandler(service))
-	r.Post("/value/", httpHandlers.NewMetricGetBodyHandler(service))
-	r.Get("/", httpHandlers.NewMetricListHTMLHandler(service))
+	// Requests are limited by address before the subnet and token checks, so
+	// that rejected requests are limited too.
+	r.Use(httpMiddlewares.RateLimitMiddleware(limiter, proxies))
+	r.Use(httpMiddlewares.BodyLimitMiddleware(cfg.MaxBodySize))
+	r.Use(httpMiddlewares.GzipLimitMiddleware(cfg.MaxBodySize))
+	r.Use(hashMiddleware.Handler)
+	r.Use(subnetMiddleware.Handler)
+
+	// Only authorized updates are recorded as agent reports.
+	write := r.With(requireScope(auth.ScopeWrite), httpMiddlewares.AgentTrackingMiddleware(agentService))
+	write.Post("/update/{type}/{name}/{value}", httpHandlers.NewMetricUpdatePathHandler(service))
+	write.Post("/update/", httpHandlers.NewMetricUpdateBodyHandler(service))
+	write.Post("/updates/", httpHandlers.NewMetricUpdatesBodyHandler(service))
+	r.With(requireScope(auth.ScopeRead)).Get("/value/{type}/{id}", httpHandlers.NewMetricGetPathHandler(service))
+	r.With(requireScope(auth.ScopeRead)).Post("/value/", httpHandlers.NewMetricGetBodyHandler(service))
+	r.With(requireScope(auth.ScopeRead)).Get("/", httpHandlers.NewMetricListHandler(service, cfg.AgentStaleAfter, history))
+	r.With(requireScope(auth.ScopeRead)).Get("/agents", httpHandlers.NewAgentListHandler(agentService))
+	if alertEngine != nil {
+		r.With(requireScope(auth.ScopeRead)).Get("/alerts", httpHandlers.NewAlertListHandler(alertEngine))
+	}
 	r.Get("/ping", newDBPingHandler(dbConn))
 
 	server := &http.Server{Addr: addr, Handler: r}
 	errCh := make(chan error, 1)
 	go func() {
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		if err := listenAndServe(server); err != nil && err != http.ErrServerClosed {
 			errCh <- err
 		}
 	}()
@@ -376,7 +374,7 @@ func runDBHTTP(ctx context.Context, addr string) error {
 
 // runDBWithWorkerHTTP runs a PostgreSQL-backed server with file-based persistence worker.
 func runDBWithWorkerHTTP(ctx context.Context, addr string) error {
-	dbConn, err := db.New("pgx", databaseDSN)
+	dbConn, err := db.New("pgx", cfg.DatabaseDSN)
 	if err != nil {
 		return err
 	}
@@ -388,52 +386,62 @@ func runDBWithWorkerHTTP(ctx context.Context, addr string) error {
 
 	writer := dbRepo.NewMetricWriteRepository(dbConn)
 	reader := dbRepo.NewMetricReadRepository(dbConn)
-	service := services.NewMetricService(writer, reader)
+	history := newMetricHistory()
+	service := services.NewMetricService(writer, reader, services.WithHistory(history), services.WithQuota(quota))
 
-	writerFile := file.NewMetricWriteRepository(fileStoragePath)
-	readerFile := file.NewMetricReadRepository(fileStoragePath)
+	writerFile := file.NewMetricWriteRepository(cfg.FileStoragePath)
+	readerFile := file.NewMetricReadRepository(cfg.FileStoragePath)
 
-	hasher := hasher.New(key)
+	alertEngine, err := startAlerts(ctx, service)
+	if err != nil {
+		return err
+	}
+
+	agentService := newAgentService()
 
 	r := chi.NewRouter()
 	r.Use(httpMiddlewares.LoggingMiddleware)
-	r.Use(httpMiddlewares.GzipMiddleware)
-	r.Use(httpMiddlewares.HashMiddleware(hasher, keyHeader))
-	r.Use(httpMiddlewares.TrustedSubnetMiddleware(trustedSubnet))
-
-	r.Post("/update/{type}/{name}/{value}", httpHandlers.NewMetricUpdatePathHandler(service))
-	r.Post("/update/", httpHandlers.NewMetricUpdateBodyHandler(service))
-	r.Post("/updates/", httpHandlers.NewMetricUpdatesBodyHandler(service))
-	r.Get("/value/{type}/{id}", httpHandlers.NewMetricGetPathHandler(service))
-	r.Post("/value/", httpHandlers.NewMetricGetBodyHandler(service))
-	r.Get("/", httpHandlers.NewMetricListHTMLHandler(service))
+	// Requests are limited by address before the subnet and token checks, so
+	// that rejected requests are limited too.
+	r.Use(httpMiddlewares.RateLimitMiddleware(limiter, proxies))
+	r.Use(httpMiddlewares.BodyLimitMiddleware(cfg.MaxBodySize))
+	r.Use(httpMiddlewares.GzipLimitMiddleware(cfg.MaxBodySize))
+	r.Use(hashMiddleware.Handler)
+	r.Use(subnetMiddleware.Handler)
+
+	// Only authorized updates are recorded as agent reports.
+	write := r.With(requireScope(auth.ScopeWrite), httpMiddlewares.AgentTrackingMiddleware(agentService))
+	write.Post("/update/{type}/{name}/{value}", httpHandlers.NewMetricUpdatePathHandler(service))
+	write.Post("/update/", httpHandlers.NewMetricUpdateBodyHandler(service))
+	write.Post("/updates/", httpHandlers.NewMetricUpdatesBodyHandler(service))
+	r.With(requireScope(auth.ScopeRead)).Get("/value/{type}/{id}", httpHandlers.NewMetricGetPathHandler(service))
+	r.With(requireScope(auth.ScopeRead)).Post("/value/", httpHandlers.NewMetricGetBodyHandler(service))
+	r.With(requireScope(auth.ScopeRead)).Get("/", httpHandlers.NewMetricListHandler(service, cfg.AgentStaleAfter, history))
+	r.With(requireScope(auth.ScopeRead)).Get("/agents", httpHandlers.NewAgentListHandler(agentService))
+	if alertEngine != nil {
+		r.With(requireScope(auth.ScopeRead)).Get("/alerts", httpHandlers.NewAlertListHandler(alertEngine))
+	}
 	r.Get("/ping", newDBPingHandler(dbConn))
 
 	server := &http.Server{Addr: addr, Handler: r}
 
 	var ticker *time.Ticker
-	intervalSeconds, _ := strconv.Atoi(storeInterval)
-	if intervalSeconds > 0 {
-		ticker = time.NewTicker(time.Duration(intervalSeconds) * time.Second)
+	if cfg.StoreInterval > 0 {
+		ticker = time.NewTicker(cfg.StoreInterval)
 		defer ticker.Stop()
 	}
 
-	var restoreBool bool
-	if restore == "true" {
-		restoreBool = true
-	}
-
 	errCh := make(chan error, 2)
 	var wg sync.WaitGroup
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		if err := worker.Run(ctx, restoreBool, ticker, reader, writer, readerFile, writerFile); err != nil {
+		if err := worker.Run(ctx, cfg.Restore, ticker, reader, writer, readerFile, writerFile); err != nil {
 			errCh <- err
 		}
 	}()
 	go func() {
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		if err := listenAndServe(server); err != nil && err != http.ErrServerClosed {
 			errCh <- err
 		}
 	}()
@@ -456,12 +464,30 @@ func runMemoryGRPC(ctx context.Context, addr string) error {
 	data := make(map[models.MetricID]models.Metrics)
 	writer := memory.NewMetricWriteRepository(data)
 	reader := memory.NewMetricReadRepository(data)
-	service := services.NewMetricService(writer, reader)
+	service := services.NewMetricService(writer, reader, services.WithQuota(quota))
+
+	if _, err := startAlerts(ctx, service); err != nil {
+		return err
+	}
 
 	metricWriteHandler := grpcHandlers.NewMetricWriteHandler(service)
 	metricReadHandler := grpcHandlers.NewMetricReadHandler(service, service)
 
-	grpcServer := grpc.NewServer()
+	agentService := newAgentService()
+
+	creds, err := grpcCredentials()
+	if err != nil {
+		return err
+	}
+	grpcServer := grpc.NewServer(
+		creds,
+		grpcMaxRecvMsgSize(),
+		grpc.ChainUnaryInterceptor(
+			grpcInterceptors.RateLimitInterceptor(limiter, proxies),
+			grpcInterceptors.AuthInterceptor(tokens),
+			grpcInterceptors.AgentTrackingInterceptor(agentService),
+		),
+	)
 	pb.RegisterMetricWriteServiceServer(grpcServer, metricWriteHandler)
 	pb.RegisterMetricReadServiceServer(grpcServer, metricReadHandler)
 
@@ -488,14 +514,32 @@ func runMemoryGRPC(ctx context.Context, addr string) error {
 
 // runFileGRPC starts a gRPC server using file-based metric storage.
 func runFileGRPC(ctx context.Context, addr string) error {
-	writer := file.NewMetricWriteRepository(fileStoragePath)
-	reader := file.NewMetricReadRepository(fileStoragePath)
-	service := services.NewMetricService(writer, reader)
+	writer := file.NewMetricWriteRepository(cfg.FileStoragePath)
+	reader := file.NewMetricReadRepository(cfg.FileStoragePath)
+	service := services.NewMetricService(writer, reader, services.WithQuota(quota))
+
+	if _, err := startAlerts(ctx, service); err != nil {
+		return err
+	}
 
 	metricWriteHandler := grpcHandlers.NewMetricWriteHandler(service)
 	metricReadHandler := grpcHandlers.NewMetricReadHandler(service, service)
 
-	grpcServer := grpc.NewServer()
+	agentService := newAgentService()
+
+	creds, err := grpcCredentials()
+	if err != nil {
+		return err
+	}
+	grpcServer := grpc.NewServer(
+		creds,
+		grpcMaxRecvMsgSize(),
+		grpc.ChainUnaryInterceptor(
+			grpcInterceptors.RateLimitInterceptor(limiter, proxies),
+			grpcInterceptors.AuthInterceptor(tokens),
+			grpcInterceptors.AgentTrackingInterceptor(agentService),
+		),
+	)
 	pb.RegisterMetricWriteServiceServer(grpcServer, metricWriteHandler)
 	pb.RegisterMetricReadServiceServer(grpcServer, metricReadHandler)
 
@@ -505,23 +549,17 @@ func runFileGRPC(ctx context.Context, addr string) error {
 	}
 
 	var ticker *time.Ticker
-	intervalSeconds, _ := strconv.Atoi(storeInterval)
-	if intervalSeconds > 0 {
-		ticker = time.NewTicker(time.Duration(intervalSeconds) * time.Second)
+	if cfg.StoreInterval > 0 {
+		ticker = time.NewTicker(cfg.StoreInterval)
 		defer ticker.Stop()
 	}
 
-	var restoreBool bool
-	if restore == "true" {
-		restoreBool = true
-	}
-
 	errCh := make(chan error, 2)
 	var wg sync.WaitGroup
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		if err := worker.Run(ctx, restoreBool, ticker, reader, writer, reader, writer); err != nil {
+		if err := worker.Run(ctx, cfg.Restore, ticker, reader, writer, reader, writer); err != nil {
 			errCh <- err
 		}
 	}()
@@ -544,7 +582,7 @@ func runFileGRPC(ctx context.Context, addr string) error {
 
 // runDBGRPC starts a gRPC server using PostgreSQL-based metric storage.
 func runDBGRPC(ctx context.Context, addr string) error {
-	dbConn, err := db.New("pgx", databaseDSN)
+	dbConn, err := db.New("pgx", cfg.DatabaseDSN)
 	if err != nil {
 		return err
 	}
@@ -556,12 +594,30 @@ func runDBGRPC(ctx context.Context, addr string) error {
 
 	writer := dbRepo.NewMetricWriteRepository(dbConn)
 	reader := dbRepo.NewMetricReadRepository(dbConn)
-	service := services.NewMetricService(writer, reader)
+	service := services.NewMetricService(writer, reader, services.WithQuota(quota))
+
+	if _, err := startAlerts(ctx, service); err != nil {
+		return err
+	}
 
 	metricWriteHandler := grpcHandlers.NewMetricWriteHandler(service)
 	metricReadHandler := grpcHandlers.NewMetricReadHandler(service, service)
 
-	grpcServer := grpc.NewServer()
+	agentService := newAgentService()
+
+	creds, err := grpcCredentials()
+	if err != nil {
+		return err
+	}
+	grpcServer := grpc.NewServer(
+		creds,
+		grpcMaxRecvMsgSize(),
+		grpc.ChainUnaryInterceptor(
+			grpcInterceptors.RateLimitInterceptor(limiter, proxies),
+			grpcInterceptors.AuthInterceptor(tokens),
+			grpcInterceptors.AgentTrackingInterceptor(agentService),
+		),
+	)
 	pb.RegisterMetricWriteServiceServer(grpcServer, metricWriteHandler)
 	pb.RegisterMetricReadServiceServer(grpcServer, metricReadHandler)
 
@@ -588,7 +644,7 @@ func runDBGRPC(ctx context.Context, addr string) error {
 
 // runDBWithWorkerGRPC starts a PostgreSQL-backed gRPC server with file-based persistence worker.
 func runDBWithWorkerGRPC(ctx context.Context, addr string) error {
-	dbConn, err := db.New("pgx", databaseDSN)
+	dbConn, err := db.New("pgx", cfg.DatabaseDSN)
 	if err != nil {
 		return err
 	}
@@ -600,15 +656,33 @@ func runDBWithWorkerGRPC(ctx context.Context, addr string) error {
 
 	writer := dbRepo.NewMetricWriteRepository(dbConn)
 	reader := dbRepo.NewMetricReadRepository(dbConn)
-	service := services.NewMetricService(writer, reader)
+	service := services.NewMetricService(writer, reader, services.WithQuota(quota))
 
-	writerFile := file.NewMetricWriteRepository(fileStoragePath)
-	readerFile := file.NewMetricReadRepository(fileStoragePath)
+	writerFile := file.NewMetricWriteRepository(cfg.FileStoragePath)
+	readerFile := file.NewMetricReadRepository(cfg.FileStoragePath)
+
+	if _, err := startAlerts(ctx, service); err != nil {
+		return err
+	}
 
 	metricWriteHandler := grpcHandlers.NewMetricWriteHandler(service)
 	metricReadHandler := grpcHandlers.NewMetricReadHandler(service, service)
 
-	grpcServer := grpc.NewServer()
+	agentService := newAgentService()
+
+	creds, err := grpcCredentials()
+	if err != nil {
+		return err
+	}
+	grpcServer := grpc.NewServer(
+		creds,
+		grpcMaxRecvMsgSize(),
+		grpc.ChainUnaryInterceptor(
+			grpcInterceptors.RateLimitInterceptor(limiter, proxies),
+			grpcInterceptors.AuthInterceptor(tokens),
+			grpcInterceptors.AgentTrackingInterceptor(agentService),
+		),
+	)
 	pb.RegisterMetricWriteServiceServer(grpcServer, metricWriteHandler)
 	pb.RegisterMetricReadServiceServer(grpcServer, metricReadHandler)
 
@@ -618,23 +692,17 @@ func runDBWithWorkerGRPC(ctx context.Context, addr string) error {
 	}
 
 	var ticker *time.Ticker
-	intervalSeconds, _ := strconv.Atoi(storeInterval)
-	if intervalSeconds > 0 {
-		ticker = time.NewTicker(time.Duration(intervalSeconds) * time.Second)
+	if cfg.StoreInterval > 0 {
+		ticker = time.NewTicker(cfg.StoreInterval)
 		defer ticker.Stop()
 	}
 
-	var restoreBool bool
-	if restore == "true" {
-		restoreBool = true
-	}
-
 	errCh := make(chan error, 2)
 	var wg sync.WaitGroup
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		if err := worker.Run(ctx, restoreBool, ticker, reader, writer, readerFile, writerFile); err != nil {
+		if err := worker.Run(ctx, cfg.Restore, ticker, reader, writer, readerFile, writerFile); err != nil {
 			errCh <- err
 		}
 	}()
@@ -655,6 +723,94 @@ func runDBWithWorkerGRPC(ctx context.Context, addr string) error {
 	return nil
 }
 
+// startAlerts loads the alerting rules, starts evaluating them in the background
+// until ctx is done and returns the alert engine.
+// It returns nil if no rules file is configured.
+func startAlerts(ctx context.Context, lister alerts.Lister) (*alerts.Engine, error) {
+	if cfg.AlertRules == "" {
+		return nil, nil
+	}
+
+	rules, err := alerts.LoadConfig(cfg.AlertRules)
+	if err != nil {
+		return nil, err
+	}
+
+	notifier := httpFacades.NewAlertWebhookFacade(resty.New().SetTimeout(5 * time.Second))
+	engine := alerts.NewEngine(rules, lister, notifier)
+
+	ticker := time.NewTicker(cfg.AlertInterval)
+	go func() {
+		defer ticker.Stop()
+		engine.Run(ctx, ticker)
+	}()
+
+	return engine, nil
+}
+
+// newAgentService creates an in-memory service tracking reporting agents.
+func newAgentService() *services.AgentService {
+	mu := &sync.RWMutex{}
+	data := make(map[string]models.Agent)
+	return services.NewAgentService(
+		memory.NewAgentWriteRepository(mu, data),
+		memory.NewAgentReadRepository(mu, data),
+		cfg.AgentStaleAfter,
+		cfg.AgentDeadAfter,
+		services.WithRetention(cfg.AgentRetention),
+	)
+}
+
+// newMetricHistory creates an in-memory history of recent metric values.
+func newMetricHistory() *memory.MetricHistoryRepository {
+	return memory.NewMetricHistoryRepository(cfg.HistorySize)
+}
+
+// grpcMaxRecvMsgSize applies the request body size limit to gRPC messages.
+func grpcMaxRecvMsgSize() grpc.ServerOption {
+	n := cfg.MaxBodySize
+	if n <= 0 || n > math.MaxInt32 {
+		n = math.MaxInt32
+	}
+	return grpc.MaxRecvMsgSize(int(n))
+}
+
+// newHashMiddleware creates the middleware checking request hashes with the
+// shared key, or with the keyring key named in the request, and rejecting
+// stale and replayed signed requests.
+func newHashMiddleware(key string, keyring *hasher.Keyring) httpMiddlewares.Middleware {
+	return httpMiddlewares.HashMiddleware(hasher.New(key), keyHeader,
+		httpMiddlewares.WithKeyring(keyring),
+		httpMiddlewares.WithReplayGuard(replayGuard),
+	)
+}
+
+// requireScope returns a middleware admitting requests with a token granting scope.
+func requireScope(scope auth.Scope) func(http.Handler) http.Handler {
+	return httpMiddlewares.AuthMiddleware(tokens, scope)
+}
+
+// listenAndServe serves HTTP, or HTTPS if a TLS certificate is configured.
+func listenAndServe(server *http.Server) error {
+	if cfg.TLSCert != "" {
+		return server.ListenAndServeTLS(cfg.TLSCert, cfg.TLSKey)
+	}
+	return server.ListenAndServe()
+}
+
+// grpcCredentials returns the TLS credentials of the gRPC server if a
+// certificate is configured.
+func grpcCredentials() (grpc.ServerOption, error) {
+	if cfg.TLSCert == "" {
+		return grpc.EmptyServerOption{}, nil
+	}
+	creds, err := credentials.NewServerTLSFromFile(cfg.TLSCert, cfg.TLSKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	return grpc.Creds(creds), nil
+}
+
 // newDBPingHandler check db connection.
 func newDBPingHandler(dbConn *sqlx.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -665,3 +821,35 @@ func newDBPingHandler(dbConn *sqlx.DB) http.HandlerFunc {
 		w.WriteHeader(http.StatusOK)
 	}
 }
+
+// reloadConfig loads the configuration again and applies the settings that
+// can change while the server runs: the hash key and keyring, the replay
+// window, the trusted subnet, the log level, the API tokens and the client
+// limits. The settings are checked before any is applied, so a bad file
+// changes nothing.
+func reloadConfig() error {
+	next := config.NewServer()
+	if err := config.Load(&next, os.Args[1:]); err != nil {
+		return err
+	}
+
+	keyring, err := hasher.NewKeyring(next.Keys)
+	if err != nil {
+		return fmt.Errorf("invalid keys value: %w", err)
+	}
+	// Set leaves the tokens unchanged on error, and the log level has been
+	// validated by Load, so the rest cannot fail.
+	if err := tokens.Set(next.AuthTokens); err != nil {
+		return fmt.Errorf("invalid tokens value: %w", err)
+	}
+	if err := httpMiddlewares.SetLogLevel(next.LogLevel); err != nil {
+		return err
+	}
+	replayGuard.SetWindow(next.ReplayWindow)
+	replayGuard.SetRequired(next.ReplayRequired)
+	hashMiddleware.Store(newHashMiddleware(next.Key, keyring))
+	subnetMiddleware.Store(httpMiddlewares.TrustedSubnetMiddleware(next.TrustedSubnet))
+	limiter.SetLimit(next.RequestRate, next.RequestBurst)
+	quota.SetMax(next.MetricQuota)
+	return nil
+}
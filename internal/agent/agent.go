@@ -2,15 +2,13 @@ package agent
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
-	"math/rand"
-	"runtime"
+	"log"
 	"sync"
 	"time"
 
 	"github.com/sbilibin2017/gophmetrics/internal/models"
-	"github.com/shirou/gopsutil/cpu"
-	"github.com/shirou/gopsutil/mem"
 )
 
 // Updater defines an interface for sending batches of metrics.
@@ -20,7 +18,82 @@ type Updater interface {
 	Update(ctx context.Context, metrics []*models.Metrics) error
 }
 
+// Spool keeps batches that could not be delivered until they can be replayed.
+type Spool interface {
+	// Push appends a batch to the tail of the spool.
+	Push(batch []*models.Metrics) error
+	// Peek returns the oldest batch, or an error if there is none.
+	Peek() ([]*models.Metrics, error)
+	// Pop removes the oldest batch.
+	Pop() error
+	// Len returns the number of spooled batches.
+	Len() int
+	// Dropped returns the number of batches lost because the spool was full.
+	Dropped() int64
+}
+
+// options holds optional agent settings.
+type options struct {
+	spool      Spool
+	collectors []Collector
+	telemetry  *Telemetry
+
+	// concurrency replaces the fixed limit of concurrent requests if set.
+	concurrency *Concurrency
+
+	// Batch limits, 0 if unlimited.
+	maxBatchCount int
+	maxBatchBytes int
+}
+
+// Opt configures optional agent settings.
+type Opt func(*options)
+
+// WithSpool makes the agent keep batches it failed to send in spool and
+// replay them in order once the server accepts updates again.
+func WithSpool(spool Spool) Opt {
+	return func(o *options) {
+		o.spool = spool
+	}
+}
+
+// WithMaxBatch limits the number of metrics and the size in bytes of the
+// uncompressed JSON of a batch; 0 means unlimited. A pending batch is sent as
+// soon as it reaches a limit, without waiting for the report tick, and larger
+// batches are split into several requests sent concurrently.
+func WithMaxBatch(count, bytes int) Opt {
+	return func(o *options) {
+		o.maxBatchCount = count
+		o.maxBatchBytes = bytes
+	}
+}
+
+// WithTelemetry makes the agent count its sends, failures and dropped
+// metrics in t. Add t to the collectors to report it to the server as well.
+func WithTelemetry(t *Telemetry) Opt {
+	return func(o *options) {
+		o.telemetry = t
+	}
+}
+
+// WithConcurrency limits concurrent outbound requests with c instead of the
+// limit passed to Run, so the limit can be changed while the agent runs.
+func WithConcurrency(c *Concurrency) Opt {
+	return func(o *options) {
+		o.concurrency = c
+	}
+}
+
+// WithCollectors replaces the default collectors with the given ones.
+func WithCollectors(collectors ...Collector) Opt {
+	return func(o *options) {
+		o.collectors = collectors
+	}
+}
+
 // Run runs metric agent.
+// Every enabled collector is polled on each tick of pollTicker, the default
+// collectors are used unless WithCollectors is given.
 // limit - max number of concurrent outbound requests (>0).
 func Run(
 	ctx context.Context,
@@ -28,135 +101,88 @@ func Run(
 	pollTicker *time.Ticker,
 	reportTicker *time.Ticker,
 	limit int,
+	opts ...Opt,
 ) error {
-	counterCh := runtimeCounterMetricsCollector(ctx, pollTicker)
-	gaugeCh := runtimeGaugeMetricsCollector(ctx, pollTicker)
-	systemCh := systemMetricsCollector(ctx, pollTicker)
-	mergedCh := fanIn(ctx, counterCh, gaugeCh, systemCh)
-	return sender(ctx, reportTicker, updater, mergedCh, limit)
-}
-
-// runtimeCounterMetricsCollector returns a channel emitting runtime counter metrics.
-func runtimeCounterMetricsCollector(ctx context.Context, pollTicker *time.Ticker) <-chan models.Metrics {
-	out := make(chan models.Metrics, 100)
+	o := options{collectors: DefaultCollectors()}
+	for _, opt := range opts {
+		opt(&o)
+	}
 
-	go func() {
-		defer close(out)
-		for {
-			select {
-			case <-pollTicker.C:
-				c := int64(1)
-				m := models.Metrics{ID: "PollCount", MType: models.Counter, Delta: &c}
-				select {
-				case out <- m:
-				case <-ctx.Done():
-					return
-				}
-			case <-ctx.Done():
-				return
-			}
-		}
-	}()
+	if o.telemetry != nil {
+		o.telemetry.observe(o.spool, updater)
+		updater = telemetryUpdater{Updater: updater, telemetry: o.telemetry}
+	}
 
-	return out
+	ticks := broadcast(ctx, pollTicker, len(o.collectors))
+	ins := make([]<-chan models.Metrics, len(o.collectors))
+	for i, c := range o.collectors {
+		ins[i] = runCollector(ctx, c, ticks[i], o.telemetry)
+	}
+	mergedCh := fanIn(ctx, ins...)
+	return sender(ctx, reportTicker, updater, mergedCh, limit, o)
 }
 
-// runtimeGaugeMetricsCollector returns a channel emitting runtime gauge metrics.
-func runtimeGaugeMetricsCollector(ctx context.Context, pollTicker *time.Ticker) <-chan models.Metrics {
-	out := make(chan models.Metrics, 100)
-	float64Ptr := func(v float64) *float64 { return &v }
+// broadcast fans the ticks of pollTicker out to n channels, so every
+// collector sees every tick. A tick is skipped for a collector that is still
+// busy with the previous one.
+func broadcast(ctx context.Context, pollTicker *time.Ticker, n int) []<-chan time.Time {
+	outs := make([]chan time.Time, n)
+	result := make([]<-chan time.Time, n)
+	for i := range outs {
+		outs[i] = make(chan time.Time, 1)
+		result[i] = outs[i]
+	}
 
 	go func() {
-		defer close(out)
+		defer func() {
+			for _, out := range outs {
+				close(out)
+			}
+		}()
 		for {
 			select {
-			case <-pollTicker.C:
-				var ms runtime.MemStats
-				runtime.ReadMemStats(&ms)
-
-				metrics := []models.Metrics{
-					{ID: "Alloc", MType: models.Gauge, Value: float64Ptr(float64(ms.Alloc))},
-					{ID: "BuckHashSys", MType: models.Gauge, Value: float64Ptr(float64(ms.BuckHashSys))},
-					{ID: "Frees", MType: models.Gauge, Value: float64Ptr(float64(ms.Frees))},
-					{ID: "GCCPUFraction", MType: models.Gauge, Value: float64Ptr(ms.GCCPUFraction)},
-					{ID: "GCSys", MType: models.Gauge, Value: float64Ptr(float64(ms.GCSys))},
-					{ID: "HeapAlloc", MType: models.Gauge, Value: float64Ptr(float64(ms.HeapAlloc))},
-					{ID: "HeapIdle", MType: models.Gauge, Value: float64Ptr(float64(ms.HeapIdle))},
-					{ID: "HeapInuse", MType: models.Gauge, Value: float64Ptr(float64(ms.HeapInuse))},
-					{ID: "HeapObjects", MType: models.Gauge, Value: float64Ptr(float64(ms.HeapObjects))},
-					{ID: "HeapReleased", MType: models.Gauge, Value: float64Ptr(float64(ms.HeapReleased))},
-					{ID: "HeapSys", MType: models.Gauge, Value: float64Ptr(float64(ms.HeapSys))},
-					{ID: "LastGC", MType: models.Gauge, Value: float64Ptr(float64(ms.LastGC))},
-					{ID: "Lookups", MType: models.Gauge, Value: float64Ptr(float64(ms.Lookups))},
-					{ID: "MCacheInuse", MType: models.Gauge, Value: float64Ptr(float64(ms.MCacheInuse))},
-					{ID: "MCacheSys", MType: models.Gauge, Value: float64Ptr(float64(ms.MCacheSys))},
-					{ID: "MSpanInuse", MType: models.Gauge, Value: float64Ptr(float64(ms.MSpanInuse))},
-					{ID: "MSpanSys", MType: models.Gauge, Value: float64Ptr(float64(ms.MSpanSys))},
-					{ID: "Mallocs", MType: models.Gauge, Value: float64Ptr(float64(ms.Mallocs))},
-					{ID: "NextGC", MType: models.Gauge, Value: float64Ptr(float64(ms.NextGC))},
-					{ID: "NumForcedGC", MType: models.Gauge, Value: float64Ptr(float64(ms.NumForcedGC))},
-					{ID: "NumGC", MType: models.Gauge, Value: float64Ptr(float64(ms.NumGC))},
-					{ID: "OtherSys", MType: models.Gauge, Value: float64Ptr(float64(ms.OtherSys))},
-					{ID: "PauseTotalNs", MType: models.Gauge, Value: float64Ptr(float64(ms.PauseTotalNs))},
-					{ID: "StackInuse", MType: models.Gauge, Value: float64Ptr(float64(ms.StackInuse))},
-					{ID: "StackSys", MType: models.Gauge, Value: float64Ptr(float64(ms.StackSys))},
-					{ID: "Sys", MType: models.Gauge, Value: float64Ptr(float64(ms.Sys))},
-					{ID: "TotalAlloc", MType: models.Gauge, Value: float64Ptr(float64(ms.TotalAlloc))},
-					{ID: "RandomValue", MType: models.Gauge, Value: float64Ptr(rand.Float64())},
-				}
-
-				for _, m := range metrics {
+			case t := <-pollTicker.C:
+				for _, out := range outs {
 					select {
-					case out <- m:
-					case <-ctx.Done():
-						return
+					case out <- t:
+					default:
 					}
 				}
-
 			case <-ctx.Done():
 				return
 			}
 		}
 	}()
 
-	return out
+	return result
 }
 
-// systemMetricsCollector returns a channel emitting system metrics periodically.
-func systemMetricsCollector(ctx context.Context, pollTicker *time.Ticker) <-chan models.Metrics {
+// runCollector returns a channel emitting the metrics of c on every tick.
+// Collection errors are logged, counted in telemetry if given, and the tick is skipped.
+func runCollector(ctx context.Context, c Collector, ticks <-chan time.Time, telemetry *Telemetry) <-chan models.Metrics {
 	out := make(chan models.Metrics, 100)
-	float64Ptr := func(v float64) *float64 { return &v }
 
 	go func() {
 		defer close(out)
 		for {
 			select {
-			case <-pollTicker.C:
-				vmem, err := mem.VirtualMemory()
-				if err == nil {
-					select {
-					case out <- models.Metrics{ID: "TotalMemory", MType: models.Gauge, Value: float64Ptr(float64(vmem.Total))}:
-					case <-ctx.Done():
-						return
-					}
+			case _, ok := <-ticks:
+				if !ok {
+					return
+				}
+				metrics, err := c.Collect(ctx)
+				if err != nil {
+					log.Printf("collector %s: %v", c.Name(), err)
+					telemetry.collectFailed()
+					continue
+				}
+				for _, m := range metrics {
 					select {
-					case out <- models.Metrics{ID: "FreeMemory", MType: models.Gauge, Value: float64Ptr(float64(vmem.Free))}:
+					case out <- m:
 					case <-ctx.Done():
 						return
 					}
 				}
-
-				percentages, err := cpu.Percent(0, true)
-				if err == nil {
-					for i, perc := range percentages {
-						select {
-						case out <- models.Metrics{ID: "CPUutilization" + string(rune('0'+i)), MType: models.Gauge, Value: float64Ptr(perc)}:
-						case <-ctx.Done():
-							return
-						}
-					}
-				}
-
 			case <-ctx.Done():
 				return
 			}
@@ -202,16 +228,25 @@ func fanIn(ctx context.Context, ins ...<-chan models.Metrics) <-chan models.Metr
 }
 
 // sender принимает метрики из канала, собирает их в батчи и отправляет с ограничением параллелизма.
+// Если задан spool, неотправленные батчи сохраняются в него и переотправляются по порядку,
+// в том числе по тику отчёта, когда новых метрик нет.
+// Батч отправляется досрочно при достижении лимитов размера и делится на части, не превышающие их.
 func sender(
 	ctx context.Context,
 	reportTicker *time.Ticker,
 	updater Updater,
 	metricsCh <-chan models.Metrics,
 	limit int,
+	o options,
 ) error {
 	if limit <= 0 {
 		return errors.New("limit must be > 0")
 	}
+	spool := o.spool
+	concurrency := o.concurrency
+	if concurrency == nil {
+		concurrency = NewConcurrency(limit)
+	}
 
 	type batchJob struct {
 		metrics []*models.Metrics
@@ -222,62 +257,313 @@ func sender(
 	var mu sync.Mutex
 	var errOccurred error
 
-	// Worker функция
-	worker := func() {
-		defer wg.Done()
-		for job := range jobsCh {
-			if err := updater.Update(ctx, job.metrics); err != nil {
-				mu.Lock()
-				errOccurred = err
-				mu.Unlock()
-			}
-		}
+	setErr := func(err error) {
+		mu.Lock()
+		errOccurred = err
+		mu.Unlock()
 	}
 
-	// Запускаем пул воркеров
-	wg.Add(limit)
-	for i := 0; i < limit; i++ {
-		go worker()
+	// spoolMu serializes replays so every spooled batch is sent once and in order.
+	var spoolMu sync.Mutex
+
+	// reject drops a batch the server refused, since resending it would fail again.
+	reject := func(metrics []*models.Metrics, err error) {
+		log.Printf("server rejected batch of %d metrics, dropping it: %v", len(metrics), err)
+		o.telemetry.metricsDroppedBy(len(metrics))
 	}
 
-	batch := make([]*models.Metrics, 0, 100)
+	// drain replays the spool; spoolMu must be held.
+	drain := func() {
+		if err := replay(ctx, updater, spool, reject); err != nil && !errors.Is(err, ErrCircuitOpen) {
+			log.Printf("spool replay stopped with %d batches queued, %d dropped: %v", spool.Len(), spool.Dropped(), err)
+		}
+	}
 
-	copyBatch := func(src []*models.Metrics) []*models.Metrics {
-		dst := make([]*models.Metrics, len(src))
-		copy(dst, src)
-		return dst
+	// deliver отправляет батч; при наличии spool неудачные батчи ставятся в очередь,
+	// а новые батчи встают за уже накопленными, чтобы сохранить порядок.
+	// Батчи, которые сервер отверг окончательно, не ставятся в очередь.
+	// Пустой батч только переотправляет очередь, если она не занята.
+	deliver := func(metrics []*models.Metrics) {
+		if spool == nil {
+			if err := updater.Update(ctx, metrics); err != nil {
+				setErr(err)
+				o.telemetry.metricsDroppedBy(len(metrics))
+			}
+			return
+		}
+
+		if metrics == nil {
+			if spoolMu.TryLock() {
+				defer spoolMu.Unlock()
+				drain()
+			}
+			return
+		}
+
+		pending := spool.Len() > 0
+
+		if !pending {
+			err := updater.Update(ctx, metrics)
+			if err == nil {
+				return
+			}
+			if permanent(err) {
+				reject(metrics, err)
+				return
+			}
+			// A breaker failing fast has already logged that the server is down.
+			if !errors.Is(err, ErrCircuitOpen) {
+				log.Printf("failed to send batch, spooling it: %v", err)
+			}
+		}
+
+		spoolMu.Lock()
+		defer spoolMu.Unlock()
+
+		if err := spool.Push(metrics); err != nil {
+			setErr(err)
+			o.telemetry.metricsDroppedBy(len(metrics))
+			return
+		}
+		if !pending {
+			return // the server has just failed, retry with the next batch
+		}
+		drain()
 	}
 
-	sendBatch := func(batchToSend []*models.Metrics) {
-		if len(batchToSend) == 0 {
+	// Диспетчер запускает отправку батчей по порядку, не превышая лимит
+	// одновременных запросов, который может меняться во время работы.
+	dispatched := make(chan struct{})
+	go func() {
+		defer close(dispatched)
+		for job := range jobsCh {
+			concurrency.acquire()
+			wg.Add(1)
+			go func(metrics []*models.Metrics) {
+				defer wg.Done()
+				defer concurrency.release()
+				deliver(metrics)
+			}(job.metrics)
+		}
+	}()
+
+	batch := newAggregator()
+	batch.measure = o.maxBatchBytes > 0
+
+	sendBatch := func(a *aggregator) {
+		if a.len() == 0 {
 			return
 		}
-		jobsCh <- batchJob{metrics: copyBatch(batchToSend)}
+		for _, part := range splitBatch(a.flush(), o.maxBatchCount, o.maxBatchBytes) {
+			jobsCh <- batchJob{metrics: part}
+		}
+	}
+
+	full := func(a *aggregator) bool {
+		return o.maxBatchCount > 0 && a.len() >= o.maxBatchCount ||
+			o.maxBatchBytes > 0 && a.bytes >= o.maxBatchBytes
+	}
+
+	stop := func() error {
+		sendBatch(batch)
+		close(jobsCh)
+		<-dispatched
+		wg.Wait()
+		if spool != nil && (spool.Len() > 0 || spool.Dropped() > 0) {
+			log.Printf("spool holds %d batches, %d dropped", spool.Len(), spool.Dropped())
+		}
+		return errOccurred
 	}
 
 	for {
 		select {
 		case <-ctx.Done():
-			sendBatch(batch)
-			close(jobsCh)
-			wg.Wait()
-			return errOccurred
+			return stop()
 
 		case m, ok := <-metricsCh:
 			if !ok {
+				return stop()
+			}
+			batch.add(m)
+			if full(batch) {
 				sendBatch(batch)
-				close(jobsCh)
-				wg.Wait()
-				return errOccurred
 			}
-			// Copy metrics because sender expects []*models.Metrics slice,
-			// but we receive single values from the channel
-			metricCopy := m // create local copy to take address safely
-			batch = append(batch, &metricCopy)
 
 		case <-reportTicker.C:
+			if batch.len() == 0 && spool != nil && spool.Len() > 0 {
+				// Nothing new to send: replay the spool anyway, so that it
+				// drains while the agent is idle.
+				jobsCh <- batchJob{}
+			}
 			sendBatch(batch)
-			batch = batch[:0]
 		}
 	}
 }
+
+// aggregator coalesces metrics by MetricID between reports: counter deltas
+// are summed and the latest gauge value wins. Metrics keep the order in which
+// they were first seen.
+type aggregator struct {
+	index   map[models.MetricID]int
+	metrics []*models.Metrics
+
+	// When measure is set, bytes is the size of the pending batch as JSON.
+	measure bool
+	sizes   []int
+	bytes   int
+}
+
+func newAggregator() *aggregator {
+	return &aggregator{index: make(map[models.MetricID]int)}
+}
+
+// add merges m into the pending batch.
+func (a *aggregator) add(m models.Metrics) {
+	key := models.MetricID{ID: m.ID, MType: m.MType}
+
+	i, ok := a.index[key]
+	if !ok {
+		// Copy the value pointers so later merges do not modify the sender's data.
+		if m.Delta != nil {
+			d := *m.Delta
+			m.Delta = &d
+		}
+		if m.Value != nil {
+			v := *m.Value
+			m.Value = &v
+		}
+		a.index[key] = len(a.metrics)
+		a.metrics = append(a.metrics, &m)
+		if a.measure {
+			size := metricSize(&m)
+			a.sizes = append(a.sizes, size)
+			a.bytes += size
+		}
+		return
+	}
+
+	cur := a.metrics[i]
+	switch {
+	case m.MType == models.Counter && m.Delta != nil:
+		if cur.Delta == nil {
+			cur.Delta = new(int64)
+		}
+		*cur.Delta += *m.Delta
+	case m.Value != nil:
+		v := *m.Value
+		cur.Value = &v
+	}
+	if !m.UpdatedAt.IsZero() {
+		cur.UpdatedAt = m.UpdatedAt
+	}
+	if a.measure {
+		size := metricSize(cur)
+		a.bytes += size - a.sizes[i]
+		a.sizes[i] = size
+	}
+}
+
+// len returns the number of distinct metrics pending.
+func (a *aggregator) len() int {
+	return len(a.metrics)
+}
+
+// flush returns the pending batch and starts a new one.
+func (a *aggregator) flush() []*models.Metrics {
+	batch := a.metrics
+	a.metrics = nil
+	a.index = make(map[models.MetricID]int)
+	a.sizes = nil
+	a.bytes = 0
+	return batch
+}
+
+// splitBatch splits a batch into parts of at most maxCount metrics and
+// maxBytes bytes of JSON; a limit of 0 is not applied. A metric larger than
+// maxBytes on its own is sent alone.
+func splitBatch(batch []*models.Metrics, maxCount, maxBytes int) [][]*models.Metrics {
+	if maxCount <= 0 && maxBytes <= 0 {
+		return [][]*models.Metrics{batch}
+	}
+
+	var (
+		parts [][]*models.Metrics
+		part  []*models.Metrics
+		bytes int
+	)
+	for _, m := range batch {
+		size := 0
+		if maxBytes > 0 {
+			size = metricSize(m)
+		}
+		if len(part) > 0 && (maxCount > 0 && len(part) >= maxCount || maxBytes > 0 && bytes+size > maxBytes) {
+			parts = append(parts, part)
+			part, bytes = nil, 0
+		}
+		part = append(part, m)
+		bytes += size
+	}
+	if len(part) > 0 {
+		parts = append(parts, part)
+	}
+	return parts
+}
+
+// metricSize returns the size of m as an element of a JSON array, including
+// the separating comma.
+func metricSize(m *models.Metrics) int {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return 0
+	}
+	return len(b) + 1
+}
+
+// replay sends spooled batches oldest first, removing each one once delivered.
+// A batch the server refused for good is passed to reject and removed too.
+// It stops at the first other failure, leaving the rest of the spool for the
+// next attempt.
+func replay(
+	ctx context.Context,
+	updater Updater,
+	spool Spool,
+	reject func([]*models.Metrics, error),
+) error {
+	for spool.Len() > 0 {
+		batch, err := spool.Peek()
+		if err != nil {
+			if spool.Len() == 0 {
+				return nil // everything left was unreadable and has been dropped
+			}
+			return err
+		}
+		if err := updater.Update(ctx, batch); err != nil {
+			if !permanent(err) {
+				return err
+			}
+			reject(batch, err)
+		}
+		if err := spool.Pop(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// permanent reports whether a failed send would fail again if retried, as
+// told by an error in its chain with a Permanent() bool method, such as the
+// server rejecting the batch itself. Any other error is taken as temporary.
+// Errors joined from several servers are permanent only if all of them are.
+func permanent(err error) bool {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs := joined.Unwrap()
+		for _, err := range errs {
+			if !permanent(err) {
+				return false
+			}
+		}
+		return len(errs) > 0
+	}
+	var p interface{ Permanent() bool }
+	return errors.As(err, &p) && p.Permanent()
+}
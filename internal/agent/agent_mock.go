@@ -48,3 +48,97 @@ func (mr *MockUpdaterMockRecorder) Update(ctx, metrics interface{}) *gomock.Call
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockUpdater)(nil).Update), ctx, metrics)
 }
+
+// MockSpool is a mock of Spool interface.
+type MockSpool struct {
+	ctrl     *gomock.Controller
+	recorder *MockSpoolMockRecorder
+}
+
+// MockSpoolMockRecorder is the mock recorder for MockSpool.
+type MockSpoolMockRecorder struct {
+	mock *MockSpool
+}
+
+// NewMockSpool creates a new mock instance.
+func NewMockSpool(ctrl *gomock.Controller) *MockSpool {
+	mock := &MockSpool{ctrl: ctrl}
+	mock.recorder = &MockSpoolMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockSpool) EXPECT() *MockSpoolMockRecorder {
+	return m.recorder
+}
+
+// Dropped mocks base method.
+func (m *MockSpool) Dropped() int64 {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Dropped")
+	ret0, _ := ret[0].(int64)
+	return ret0
+}
+
+// Dropped indicates an expected call of Dropped.
+func (mr *MockSpoolMockRecorder) Dropped() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Dropped", reflect.TypeOf((*MockSpool)(nil).Dropped))
+}
+
+// Len mocks base method.
+func (m *MockSpool) Len() int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Len")
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// Len indicates an expected call of Len.
+func (mr *MockSpoolMockRecorder) Len() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Len", reflect.TypeOf((*MockSpool)(nil).Len))
+}
+
+// Peek mocks base method.
+func (m *MockSpool) Peek() ([]*models.Metrics, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Peek")
+	ret0, _ := ret[0].([]*models.Metrics)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Peek indicates an expected call of Peek.
+func (mr *MockSpoolMockRecorder) Peek() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Peek", reflect.TypeOf((*MockSpool)(nil).Peek))
+}
+
+// Pop mocks base method.
+func (m *MockSpool) Pop() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Pop")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Pop indicates an expected call of Pop.
+func (mr *MockSpoolMockRecorder) Pop() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Pop", reflect.TypeOf((*MockSpool)(nil).Pop))
+}
+
+// Push mocks base method.
+func (m *MockSpool) Push(batch []*models.Metrics) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Push", batch)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Push indicates an expected call of Push.
+func (mr *MockSpoolMockRecorder) Push(batch interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Push", reflect.TypeOf((*MockSpool)(nil).Push), batch)
+}
@@ -3,12 +3,15 @@ package agent
 import (
 	"context"
 	"errors"
+	"fmt"
 	"testing"
 	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/sbilibin2017/gophmetrics/internal/models"
+	"github.com/sbilibin2017/gophmetrics/internal/queue"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestRun_Success(t *testing.T) {
@@ -87,7 +90,7 @@ func TestSender_ErrorPropagation(t *testing.T) {
 		close(metricsCh)
 	}()
 
-	err := sender(ctx, reportTicker, mockUpdater, metricsCh, 1)
+	err := sender(ctx, reportTicker, mockUpdater, metricsCh, 1, options{})
 	assert.Error(t, err)
 	assert.Equal(t, "update failed", err.Error())
 }
@@ -97,7 +100,7 @@ func TestSender_LimitZero(t *testing.T) {
 
 	mockUpdater := NewMockUpdater(ctrl)
 
-	err := sender(context.Background(), time.NewTicker(time.Millisecond*5), mockUpdater, make(chan models.Metrics), 0)
+	err := sender(context.Background(), time.NewTicker(time.Millisecond*5), mockUpdater, make(chan models.Metrics), 0, options{})
 	assert.Error(t, err)
 	assert.Equal(t, "limit must be > 0", err.Error())
 }
@@ -120,3 +123,302 @@ func TestRun_ContextDone(t *testing.T) {
 	err := Run(ctx, mockUpdater, pollTicker, reportTicker, 1)
 	assert.NoError(t, err)
 }
+
+// counterBatch builds a single-metric counter batch for spool tests.
+func counterBatch(id string) []*models.Metrics {
+	delta := int64(1)
+	return []*models.Metrics{{ID: id, MType: models.Counter, Delta: &delta}}
+}
+
+// sendOne feeds a single metric to a channel and closes it.
+func sendOne(id string) <-chan models.Metrics {
+	ch := make(chan models.Metrics, 1)
+	ch <- *counterBatch(id)[0]
+	close(ch)
+	return ch
+}
+
+func TestSender_SpoolsFailedBatch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUpdater := NewMockUpdater(ctrl)
+	spool, err := queue.New(t.TempDir(), 10)
+	require.NoError(t, err)
+
+	mockUpdater.EXPECT().Update(gomock.Any(), counterBatch("a")).Return(errors.New("server down")).Times(1)
+
+	reportTicker := time.NewTicker(time.Hour)
+	defer reportTicker.Stop()
+
+	err = sender(context.Background(), reportTicker, mockUpdater, sendOne("a"), 1, options{spool: spool})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, spool.Len())
+}
+
+func TestSender_ReplaysSpoolBeforeNewBatch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUpdater := NewMockUpdater(ctrl)
+	spool, err := queue.New(t.TempDir(), 10)
+	require.NoError(t, err)
+	require.NoError(t, spool.Push(counterBatch("old")))
+
+	gomock.InOrder(
+		mockUpdater.EXPECT().Update(gomock.Any(), counterBatch("old")).Return(nil),
+		mockUpdater.EXPECT().Update(gomock.Any(), counterBatch("new")).Return(nil),
+	)
+
+	reportTicker := time.NewTicker(time.Hour)
+	defer reportTicker.Stop()
+
+	err = sender(context.Background(), reportTicker, mockUpdater, sendOne("new"), 1, options{spool: spool})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, spool.Len())
+}
+
+func TestReplay_StopsOnFailure(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUpdater := NewMockUpdater(ctrl)
+	spool, err := queue.New(t.TempDir(), 10)
+	require.NoError(t, err)
+	require.NoError(t, spool.Push(counterBatch("a")))
+	require.NoError(t, spool.Push(counterBatch("b")))
+
+	gomock.InOrder(
+		mockUpdater.EXPECT().Update(gomock.Any(), counterBatch("a")).Return(nil),
+		mockUpdater.EXPECT().Update(gomock.Any(), counterBatch("b")).Return(errors.New("server down")),
+	)
+
+	err = replay(context.Background(), mockUpdater, spool, func([]*models.Metrics, error) {
+		t.Fatal("temporary failure rejected")
+	})
+	assert.EqualError(t, err, "server down")
+	assert.Equal(t, 1, spool.Len())
+}
+
+// rejectedError is a failure that would repeat if the batch were resent.
+type rejectedError struct{}
+
+func (rejectedError) Error() string   { return "rejected" }
+func (rejectedError) Permanent() bool { return true }
+
+func TestReplay_DropsRejectedBatch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUpdater := NewMockUpdater(ctrl)
+	spool, err := queue.New(t.TempDir(), 10)
+	require.NoError(t, err)
+	require.NoError(t, spool.Push(counterBatch("a")))
+	require.NoError(t, spool.Push(counterBatch("b")))
+
+	gomock.InOrder(
+		mockUpdater.EXPECT().Update(gomock.Any(), counterBatch("a")).Return(fmt.Errorf("send: %w", rejectedError{})),
+		mockUpdater.EXPECT().Update(gomock.Any(), counterBatch("b")).Return(nil),
+	)
+
+	var rejected [][]*models.Metrics
+	err = replay(context.Background(), mockUpdater, spool, func(batch []*models.Metrics, err error) {
+		rejected = append(rejected, batch)
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, [][]*models.Metrics{counterBatch("a")}, rejected)
+	assert.Equal(t, 0, spool.Len(), "a rejected batch does not block the spool")
+}
+
+func TestSender_DropsRejectedBatch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUpdater := NewMockUpdater(ctrl)
+	spool, err := queue.New(t.TempDir(), 10)
+	require.NoError(t, err)
+
+	mockUpdater.EXPECT().Update(gomock.Any(), counterBatch("a")).Return(rejectedError{})
+
+	reportTicker := time.NewTicker(time.Hour)
+	defer reportTicker.Stop()
+
+	telemetry := NewTelemetry()
+	err = sender(context.Background(), reportTicker, mockUpdater, sendOne("a"), 1, options{spool: spool, telemetry: telemetry})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, spool.Len())
+	assert.Equal(t, int64(1), telemetry.metricsDropped.Load())
+}
+
+func TestSender_ReplaysSpoolWhileIdle(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUpdater := NewMockUpdater(ctrl)
+	spool, err := queue.New(t.TempDir(), 10)
+	require.NoError(t, err)
+	require.NoError(t, spool.Push(counterBatch("old")))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mockUpdater.EXPECT().Update(gomock.Any(), counterBatch("old")).DoAndReturn(
+		func(context.Context, []*models.Metrics) error {
+			cancel()
+			return nil
+		})
+
+	reportTicker := time.NewTicker(10 * time.Millisecond)
+	defer reportTicker.Stop()
+
+	err = sender(ctx, reportTicker, mockUpdater, make(chan models.Metrics), 1, options{spool: spool})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, spool.Len())
+}
+
+func TestPermanent(t *testing.T) {
+	down := errors.New("server down")
+
+	assert.False(t, permanent(down))
+	assert.True(t, permanent(rejectedError{}))
+	assert.True(t, permanent(fmt.Errorf("a: %w", rejectedError{})))
+	assert.True(t, permanent(errors.Join(rejectedError{}, fmt.Errorf("b: %w", rejectedError{}))))
+	assert.False(t, permanent(errors.Join(rejectedError{}, down)), "another server may accept the batch")
+}
+
+func TestSender_SpoolPushError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUpdater := NewMockUpdater(ctrl)
+	mockSpool := NewMockSpool(ctrl)
+
+	mockSpool.EXPECT().Len().Return(0).AnyTimes()
+	mockSpool.EXPECT().Dropped().Return(int64(0)).AnyTimes()
+	mockUpdater.EXPECT().Update(gomock.Any(), gomock.Any()).Return(errors.New("server down"))
+	mockSpool.EXPECT().Push(gomock.Any()).Return(errors.New("disk full"))
+
+	reportTicker := time.NewTicker(time.Hour)
+	defer reportTicker.Stop()
+
+	err := sender(context.Background(), reportTicker, mockUpdater, sendOne("a"), 1, options{spool: mockSpool})
+	assert.EqualError(t, err, "disk full")
+}
+
+func TestAggregator(t *testing.T) {
+	ptrInt64 := func(v int64) *int64 { return &v }
+	ptrFloat64 := func(v float64) *float64 { return &v }
+
+	a := newAggregator()
+	input := []models.Metrics{
+		{ID: "PollCount", MType: models.Counter, Delta: ptrInt64(1)},
+		{ID: "Alloc", MType: models.Gauge, Value: ptrFloat64(10)},
+		{ID: "PollCount", MType: models.Counter, Delta: ptrInt64(1)},
+		{ID: "Alloc", MType: models.Gauge, Value: ptrFloat64(20)},
+		{ID: "PollCount", MType: models.Counter, Delta: ptrInt64(3)},
+		{ID: "Alloc", MType: models.Counter, Delta: ptrInt64(7)},
+	}
+	for _, m := range input {
+		a.add(m)
+	}
+
+	assert.Equal(t, 3, a.len())
+	assert.Equal(t, []*models.Metrics{
+		{ID: "PollCount", MType: models.Counter, Delta: ptrInt64(5)},
+		{ID: "Alloc", MType: models.Gauge, Value: ptrFloat64(20)},
+		{ID: "Alloc", MType: models.Counter, Delta: ptrInt64(7)},
+	}, a.flush())
+
+	// Inputs are not modified by merging.
+	assert.Equal(t, int64(1), *input[0].Delta)
+	assert.Equal(t, 10.0, *input[1].Value)
+
+	assert.Equal(t, 0, a.len())
+	assert.Empty(t, a.flush())
+
+	a.add(models.Metrics{ID: "PollCount", MType: models.Counter, Delta: ptrInt64(1)})
+	assert.Equal(t, []*models.Metrics{
+		{ID: "PollCount", MType: models.Counter, Delta: ptrInt64(1)},
+	}, a.flush())
+}
+
+func TestSender_CoalescesBatch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUpdater := NewMockUpdater(ctrl)
+
+	delta := int64(1)
+	value := 2.5
+	want := []*models.Metrics{
+		{ID: "PollCount", MType: models.Counter, Delta: func() *int64 { v := int64(3); return &v }()},
+		{ID: "Alloc", MType: models.Gauge, Value: &value},
+	}
+	mockUpdater.EXPECT().Update(gomock.Any(), want).Return(nil).Times(1)
+
+	metricsCh := make(chan models.Metrics, 4)
+	for i := 0; i < 3; i++ {
+		metricsCh <- models.Metrics{ID: "PollCount", MType: models.Counter, Delta: &delta}
+	}
+	metricsCh <- models.Metrics{ID: "Alloc", MType: models.Gauge, Value: &value}
+	close(metricsCh)
+
+	reportTicker := time.NewTicker(time.Hour)
+	defer reportTicker.Stop()
+
+	err := sender(context.Background(), reportTicker, mockUpdater, metricsCh, 1, options{})
+	assert.NoError(t, err)
+}
+
+func TestSplitBatch(t *testing.T) {
+	batch := append(append(counterBatch("a"), counterBatch("b")...), counterBatch("c")...)
+	size := metricSize(batch[0])
+
+	assert.Equal(t, [][]*models.Metrics{batch}, splitBatch(batch, 0, 0))
+	assert.Equal(t, [][]*models.Metrics{batch[:2], batch[2:]}, splitBatch(batch, 2, 0))
+	assert.Equal(t, [][]*models.Metrics{batch[:1], batch[1:2], batch[2:]}, splitBatch(batch, 0, 2*size-1))
+	assert.Equal(t, [][]*models.Metrics{batch[:2], batch[2:]}, splitBatch(batch, 3, 2*size))
+	assert.Equal(t, [][]*models.Metrics{batch[:1], batch[1:2], batch[2:]}, splitBatch(batch, 0, 1), "oversized metrics are sent alone")
+}
+
+func TestAggregator_Measure(t *testing.T) {
+	a := newAggregator()
+	a.measure = true
+
+	value := 1.0
+	a.add(models.Metrics{ID: "Alloc", MType: models.Gauge, Value: &value})
+	assert.Equal(t, metricSize(&models.Metrics{ID: "Alloc", MType: models.Gauge, Value: &value}), a.bytes)
+
+	value = 123456.789
+	a.add(models.Metrics{ID: "Alloc", MType: models.Gauge, Value: &value})
+	assert.Equal(t, metricSize(&models.Metrics{ID: "Alloc", MType: models.Gauge, Value: &value}), a.bytes)
+
+	a.flush()
+	assert.Equal(t, 0, a.bytes)
+}
+
+func TestSender_FlushesOnSize(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUpdater := NewMockUpdater(ctrl)
+
+	metricsCh := make(chan models.Metrics)
+	gomock.InOrder(
+		mockUpdater.EXPECT().Update(gomock.Any(), append(counterBatch("a"), counterBatch("b")...)).Return(nil),
+		mockUpdater.EXPECT().Update(gomock.Any(), append(counterBatch("c"), counterBatch("d")...)).Return(nil),
+		mockUpdater.EXPECT().Update(gomock.Any(), counterBatch("e")).Return(nil),
+	)
+
+	go func() {
+		for _, id := range []string{"a", "b", "c", "d", "e"} {
+			metricsCh <- *counterBatch(id)[0]
+		}
+		close(metricsCh)
+	}()
+
+	reportTicker := time.NewTicker(time.Hour)
+	defer reportTicker.Stop()
+
+	err := sender(context.Background(), reportTicker, mockUpdater, metricsCh, 1, options{maxBatchCount: 2})
+	assert.NoError(t, err)
+}
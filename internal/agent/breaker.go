@@ -0,0 +1,207 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/sbilibin2017/gophmetrics/internal/models"
+)
+
+// ErrCircuitOpen is returned by Breaker while it rejects updates.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// BreakerState is the state of a circuit breaker.
+type BreakerState int
+
+// Circuit breaker states.
+const (
+	// BreakerClosed passes updates through, counting consecutive failures.
+	BreakerClosed BreakerState = iota
+	// BreakerOpen rejects updates until the open timeout elapses.
+	BreakerOpen
+	// BreakerHalfOpen lets a single trial update through at a time.
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// Breaker is an Updater decorator implementing a circuit breaker.
+//
+// After FailureThreshold consecutive failures the breaker opens and fails
+// every update with ErrCircuitOpen without calling the server. Once the open
+// timeout elapses it lets trial updates through one at a time: a failure
+// opens it again, SuccessThreshold consecutive successes close it.
+type Breaker struct {
+	updater          Updater
+	failureThreshold int
+	successThreshold int
+	openTimeout      time.Duration
+	now              func() time.Time
+
+	mu        sync.Mutex
+	state     BreakerState
+	failures  int
+	successes int
+	openedAt  time.Time
+	trial     bool // a half-open trial update is in flight
+}
+
+// BreakerOpt configures a Breaker.
+type BreakerOpt func(*Breaker)
+
+// WithFailureThreshold sets the number of consecutive failures opening the breaker.
+func WithFailureThreshold(n int) BreakerOpt {
+	return func(b *Breaker) {
+		b.failureThreshold = n
+	}
+}
+
+// WithSuccessThreshold sets the number of consecutive successful trial
+// updates closing a half-open breaker.
+func WithSuccessThreshold(n int) BreakerOpt {
+	return func(b *Breaker) {
+		b.successThreshold = n
+	}
+}
+
+// WithOpenTimeout sets how long the breaker stays open before trying again.
+func WithOpenTimeout(d time.Duration) BreakerOpt {
+	return func(b *Breaker) {
+		b.openTimeout = d
+	}
+}
+
+// NewBreaker wraps updater in a circuit breaker. By default it opens after 5
+// consecutive failures, stays open for 30 seconds and closes after a single
+// successful trial.
+func NewBreaker(updater Updater, opts ...BreakerOpt) *Breaker {
+	b := &Breaker{
+		updater:          updater,
+		failureThreshold: 5,
+		successThreshold: 1,
+		openTimeout:      30 * time.Second,
+		now:              time.Now,
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// Update sends the batch unless the breaker is open.
+// Failures caused by the cancellation of ctx are not counted, and a batch the
+// server refused for good counts as a success, since the server is up.
+func (b *Breaker) Update(ctx context.Context, metrics []*models.Metrics) error {
+	trial, err := b.allow()
+	if err != nil {
+		return err
+	}
+
+	err = b.updater.Update(ctx, metrics)
+	if err != nil && ctx.Err() != nil {
+		b.release(trial)
+		return err
+	}
+	b.record(trial, err == nil || permanent(err))
+	return err
+}
+
+// State returns the current state of the breaker.
+func (b *Breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerOpen && !b.now().Before(b.openedAt.Add(b.openTimeout)) {
+		return BreakerHalfOpen
+	}
+	return b.state
+}
+
+// allow reports whether an update may be sent now and whether it is a
+// half-open trial.
+func (b *Breaker) allow() (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if b.now().Before(b.openedAt.Add(b.openTimeout)) {
+			return false, ErrCircuitOpen
+		}
+		b.setState(BreakerHalfOpen)
+		b.successes = 0
+		fallthrough
+	case BreakerHalfOpen:
+		if b.trial {
+			return false, ErrCircuitOpen
+		}
+		b.trial = true
+		return true, nil
+	}
+	return false, nil
+}
+
+// release gives up an update without counting its outcome.
+func (b *Breaker) release(trial bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if trial {
+		b.trial = false
+	}
+}
+
+// record counts the outcome of an update. Outcomes of updates started
+// before the breaker opened do not change an open breaker.
+func (b *Breaker) record(trial, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if trial {
+		b.trial = false
+	}
+	switch {
+	case b.state == BreakerOpen:
+	case !trial && b.state == BreakerHalfOpen:
+	case ok && b.state == BreakerHalfOpen:
+		b.successes++
+		if b.successes >= b.successThreshold {
+			b.failures = 0
+			b.setState(BreakerClosed)
+		}
+	case ok:
+		b.failures = 0
+	case b.state == BreakerHalfOpen:
+		b.open()
+	default:
+		b.failures++
+		if b.failures >= b.failureThreshold {
+			b.open()
+		}
+	}
+}
+
+func (b *Breaker) open() {
+	b.openedAt = b.now()
+	b.setState(BreakerOpen)
+}
+
+func (b *Breaker) setState(s BreakerState) {
+	if b.state != s {
+		log.Printf("circuit breaker %s -> %s", b.state, s)
+		b.state = s
+	}
+}
@@ -0,0 +1,142 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sbilibin2017/gophmetrics/internal/queue"
+)
+
+func TestBreaker(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUpdater := NewMockUpdater(ctrl)
+	b := NewBreaker(mockUpdater, WithFailureThreshold(2), WithSuccessThreshold(2), WithOpenTimeout(time.Minute))
+	now := time.Unix(0, 0)
+	b.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	batch := counterBatch("a")
+	down := errors.New("server down")
+
+	// A success resets the failure count.
+	gomock.InOrder(
+		mockUpdater.EXPECT().Update(ctx, batch).Return(down),
+		mockUpdater.EXPECT().Update(ctx, batch).Return(nil),
+		mockUpdater.EXPECT().Update(ctx, batch).Return(down),
+	)
+	assert.ErrorIs(t, b.Update(ctx, batch), down)
+	assert.NoError(t, b.Update(ctx, batch))
+	assert.ErrorIs(t, b.Update(ctx, batch), down)
+	assert.Equal(t, BreakerClosed, b.State())
+
+	// The second consecutive failure opens the breaker.
+	mockUpdater.EXPECT().Update(ctx, batch).Return(down)
+	assert.ErrorIs(t, b.Update(ctx, batch), down)
+	assert.Equal(t, BreakerOpen, b.State())
+	assert.ErrorIs(t, b.Update(ctx, batch), ErrCircuitOpen, "fails fast while open")
+
+	// A failed trial opens it again.
+	now = now.Add(time.Minute)
+	assert.Equal(t, BreakerHalfOpen, b.State())
+	mockUpdater.EXPECT().Update(ctx, batch).Return(down)
+	assert.ErrorIs(t, b.Update(ctx, batch), down)
+	assert.Equal(t, BreakerOpen, b.State())
+
+	// Two successful trials close it.
+	now = now.Add(time.Minute)
+	mockUpdater.EXPECT().Update(ctx, batch).Return(nil).Times(2)
+	assert.NoError(t, b.Update(ctx, batch))
+	assert.Equal(t, BreakerHalfOpen, b.State())
+	assert.NoError(t, b.Update(ctx, batch))
+	assert.Equal(t, BreakerClosed, b.State())
+}
+
+func TestBreaker_SingleTrial(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUpdater := NewMockUpdater(ctrl)
+	b := NewBreaker(mockUpdater, WithFailureThreshold(1))
+	now := time.Unix(0, 0)
+	b.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	mockUpdater.EXPECT().Update(ctx, gomock.Any()).Return(errors.New("server down"))
+	assert.Error(t, b.Update(ctx, counterBatch("a")))
+
+	now = now.Add(30 * time.Second)
+	started := make(chan struct{})
+	finish := make(chan struct{})
+	mockUpdater.EXPECT().Update(ctx, gomock.Any()).DoAndReturn(func(context.Context, interface{}) error {
+		close(started)
+		<-finish
+		return nil
+	})
+
+	done := make(chan error)
+	go func() { done <- b.Update(ctx, counterBatch("trial")) }()
+	<-started
+	assert.ErrorIs(t, b.Update(ctx, counterBatch("b")), ErrCircuitOpen, "only one trial at a time")
+
+	close(finish)
+	require.NoError(t, <-done)
+	assert.Equal(t, BreakerClosed, b.State())
+}
+
+func TestBreaker_CancelledNotCounted(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUpdater := NewMockUpdater(ctrl)
+	b := NewBreaker(mockUpdater, WithFailureThreshold(1))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	mockUpdater.EXPECT().Update(ctx, gomock.Any()).Return(context.Canceled)
+
+	assert.ErrorIs(t, b.Update(ctx, counterBatch("a")), context.Canceled)
+	assert.Equal(t, BreakerClosed, b.State())
+}
+
+func TestBreaker_RejectedNotCounted(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUpdater := NewMockUpdater(ctrl)
+	b := NewBreaker(mockUpdater, WithFailureThreshold(1))
+	ctx := context.Background()
+
+	mockUpdater.EXPECT().Update(ctx, gomock.Any()).Return(rejectedError{})
+	assert.ErrorIs(t, b.Update(ctx, counterBatch("a")), rejectedError{})
+	assert.Equal(t, BreakerClosed, b.State(), "the server that refused the batch is up")
+}
+
+func TestSender_SpoolsWhileCircuitOpen(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUpdater := NewMockUpdater(ctrl)
+	b := NewBreaker(mockUpdater, WithFailureThreshold(1), WithOpenTimeout(time.Hour))
+	spool, err := queue.New(t.TempDir(), 10)
+	require.NoError(t, err)
+
+	// Only the first batch reaches the server; the rest fail fast into the spool.
+	mockUpdater.EXPECT().Update(gomock.Any(), counterBatch("a")).Return(errors.New("server down")).Times(1)
+
+	reportTicker := time.NewTicker(time.Hour)
+	defer reportTicker.Stop()
+
+	for _, id := range []string{"a", "b", "c"} {
+		err = sender(context.Background(), reportTicker, b, sendOne(id), 1, options{spool: spool})
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 3, spool.Len())
+}
@@ -0,0 +1,338 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/sbilibin2017/gophmetrics/internal/models"
+	"github.com/shirou/gopsutil/cpu"
+	"github.com/shirou/gopsutil/mem"
+)
+
+// Names of the built-in collectors.
+const (
+	PollCountCollectorName = "pollcount"
+	RuntimeCollectorName   = "runtime"
+	SystemCollectorName    = "system"
+)
+
+// Registry errors.
+var (
+	ErrDuplicateCollector = errors.New("collector already registered")
+	ErrUnknownCollector   = errors.New("unknown collector")
+)
+
+// Collector gathers a set of metrics on every poll.
+type Collector interface {
+	// Name returns the unique name the collector is enabled by.
+	Name() string
+	// Collect returns the current values of the collector's metrics.
+	// An error skips this poll of the collector only.
+	Collect(ctx context.Context) ([]models.Metrics, error)
+}
+
+// Registry holds the collectors that can be enabled by name.
+type Registry struct {
+	mu         sync.RWMutex
+	collectors map[string]Collector
+}
+
+// NewRegistry creates a registry with the given collectors.
+// It panics if two of them share a name.
+func NewRegistry(collectors ...Collector) *Registry {
+	r := &Registry{collectors: make(map[string]Collector)}
+	for _, c := range collectors {
+		if err := r.Register(c); err != nil {
+			panic(err)
+		}
+	}
+	return r
+}
+
+// DefaultRegistry creates a registry with the built-in collectors, including
+// those not enabled by default.
+func DefaultRegistry() *Registry {
+	return NewRegistry(append(DefaultCollectors(), NewRuntimeCollector(), NewHostCollector())...)
+}
+
+// DefaultCollectors returns the built-in collectors enabled when none are selected.
+// Runtime statistics come from runtime/metrics, which does not stop the world;
+// the runtime collector with the legacy runtime.MemStats names and the host
+// collector, which adds a series per disk and network interface, are opt-in.
+func DefaultCollectors() []Collector {
+	return []Collector{
+		NewPollCountCollector(),
+		NewRuntimeMetricsCollector(),
+		NewSystemCollector(),
+	}
+}
+
+// Register adds c to the registry.
+func (r *Registry) Register(c Collector) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.collectors[c.Name()]; ok {
+		return fmt.Errorf("%w: %s", ErrDuplicateCollector, c.Name())
+	}
+	r.collectors[c.Name()] = c
+	return nil
+}
+
+// Lookup returns the collector registered under name.
+func (r *Registry) Lookup(name string) (Collector, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	c, ok := r.collectors[name]
+	return c, ok
+}
+
+// Names returns the names of all registered collectors in sorted order.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.collectors))
+	for name := range r.collectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Select returns the collectors with the given names in the given order.
+func (r *Registry) Select(names ...string) ([]Collector, error) {
+	collectors := make([]Collector, 0, len(names))
+	for _, name := range names {
+		c, ok := r.Lookup(name)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownCollector, name)
+		}
+		collectors = append(collectors, c)
+	}
+	return collectors, nil
+}
+
+// pollCountCollector counts polls.
+type pollCountCollector struct{}
+
+// NewPollCountCollector creates a collector reporting the PollCount counter,
+// incremented by one on every poll.
+func NewPollCountCollector() Collector {
+	return pollCountCollector{}
+}
+
+func (pollCountCollector) Name() string { return PollCountCollectorName }
+
+func (pollCountCollector) Collect(ctx context.Context) ([]models.Metrics, error) {
+	c := int64(1)
+	return []models.Metrics{{ID: "PollCount", MType: models.Counter, Delta: &c}}, nil
+}
+
+// runtimeCollector reads Go runtime memory statistics.
+type runtimeCollector struct{}
+
+// NewRuntimeCollector creates a collector reporting runtime.MemStats gauges
+// and RandomValue under their legacy names. Reading runtime.MemStats stops
+// the world, so it is not enabled by default.
+func NewRuntimeCollector() Collector {
+	return runtimeCollector{}
+}
+
+func (runtimeCollector) Name() string { return RuntimeCollectorName }
+
+func (runtimeCollector) Collect(ctx context.Context) ([]models.Metrics, error) {
+	float64Ptr := func(v float64) *float64 { return &v }
+
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+
+	return []models.Metrics{
+		{ID: "Alloc", MType: models.Gauge, Value: float64Ptr(float64(ms.Alloc))},
+		{ID: "BuckHashSys", MType: models.Gauge, Value: float64Ptr(float64(ms.BuckHashSys))},
+		{ID: "Frees", MType: models.Gauge, Value: float64Ptr(float64(ms.Frees))},
+		{ID: "GCCPUFraction", MType: models.Gauge, Value: float64Ptr(ms.GCCPUFraction)},
+		{ID: "GCSys", MType: models.Gauge, Value: float64Ptr(float64(ms.GCSys))},
+		{ID: "HeapAlloc", MType: models.Gauge, Value: float64Ptr(float64(ms.HeapAlloc))},
+		{ID: "HeapIdle", MType: models.Gauge, Value: float64Ptr(float64(ms.HeapIdle))},
+		{ID: "HeapInuse", MType: models.Gauge, Value: float64Ptr(float64(ms.HeapInuse))},
+		{ID: "HeapObjects", MType: models.Gauge, Value: float64Ptr(float64(ms.HeapObjects))},
+		{ID: "HeapReleased", MType: models.Gauge, Value: float64Ptr(float64(ms.HeapReleased))},
+		{ID: "HeapSys", MType: models.Gauge, Value: float64Ptr(float64(ms.HeapSys))},
+		{ID: "LastGC", MType: models.Gauge, Value: float64Ptr(float64(ms.LastGC))},
+		{ID: "Lookups", MType: models.Gauge, Value: float64Ptr(float64(ms.Lookups))},
+		{ID: "MCacheInuse", MType: models.Gauge, Value: float64Ptr(float64(ms.MCacheInuse))},
+		{ID: "MCacheSys", MType: models.Gauge, Value: float64Ptr(float64(ms.MCacheSys))},
+		{ID: "MSpanInuse", MType: models.Gauge, Value: float64Ptr(float64(ms.MSpanInuse))},
+		{ID: "MSpanSys", MType: models.Gauge, Value: float64Ptr(float64(ms.MSpanSys))},
+		{ID: "Mallocs", MType: models.Gauge, Value: float64Ptr(float64(ms.Mallocs))},
+		{ID: "NextGC", MType: models.Gauge, Value: float64Ptr(float64(ms.NextGC))},
+		{ID: "NumForcedGC", MType: models.Gauge, Value: float64Ptr(float64(ms.NumForcedGC))},
+		{ID: "NumGC", MType: models.Gauge, Value: float64Ptr(float64(ms.NumGC))},
+		{ID: "OtherSys", MType: models.Gauge, Value: float64Ptr(float64(ms.OtherSys))},
+		{ID: "PauseTotalNs", MType: models.Gauge, Value: float64Ptr(float64(ms.PauseTotalNs))},
+		{ID: "StackInuse", MType: models.Gauge, Value: float64Ptr(float64(ms.StackInuse))},
+		{ID: "StackSys", MType: models.Gauge, Value: float64Ptr(float64(ms.StackSys))},
+		{ID: "Sys", MType: models.Gauge, Value: float64Ptr(float64(ms.Sys))},
+		{ID: "TotalAlloc", MType: models.Gauge, Value: float64Ptr(float64(ms.TotalAlloc))},
+		{ID: "RandomValue", MType: models.Gauge, Value: float64Ptr(rand.Float64())},
+	}, nil
+}
+
+// cpuModes are the CPU time modes reported by WithCPUModes, with the metric
+// name suffixes they are reported under.
+var cpuModes = []struct {
+	suffix string
+	time   func(cpu.TimesStat) float64
+}{
+	{"User", func(t cpu.TimesStat) float64 { return t.User }},
+	{"System", func(t cpu.TimesStat) float64 { return t.System }},
+	{"Iowait", func(t cpu.TimesStat) float64 { return t.Iowait }},
+}
+
+// systemSources are the gopsutil calls used by systemCollector, replaced in tests.
+type systemSources struct {
+	memory func(ctx context.Context) (*mem.VirtualMemoryStat, error)
+	times  func(ctx context.Context, percpu bool) ([]cpu.TimesStat, error)
+}
+
+// systemCollector reads host memory and CPU statistics.
+//
+// CPU utilization is the share of non-idle time since the previous poll, or
+// since boot on the first one, in percent: CPUutilization across all cores
+// and CPUutilization0, CPUutilization1 and so on per core.
+type systemCollector struct {
+	src         systemSources
+	modes       bool
+	legacyNames bool
+
+	mu       sync.Mutex
+	prevAll  cpu.TimesStat
+	prevCore map[string]cpu.TimesStat
+}
+
+// SystemOpt configures the system collector.
+type SystemOpt func(*systemCollector)
+
+// WithCPUModes additionally reports the share of user, system and iowait
+// time, e.g. CPUutilizationUser across all cores and CPUutilization1User per
+// core.
+func WithCPUModes() SystemOpt {
+	return func(c *systemCollector) {
+		c.modes = true
+	}
+}
+
+// WithLegacyCPUNames names per-core utilization the way earlier versions
+// did: the characters after '9' instead of decimal numbers for cores past
+// the tenth, such as CPUutilization: for core 10.
+func WithLegacyCPUNames() SystemOpt {
+	return func(c *systemCollector) {
+		c.legacyNames = true
+	}
+}
+
+// NewSystemCollector creates a collector reporting TotalMemory, FreeMemory
+// and CPU utilization gauges.
+func NewSystemCollector(opts ...SystemOpt) Collector {
+	return newSystemCollector(systemSources{
+		memory: mem.VirtualMemoryWithContext,
+		times:  cpu.TimesWithContext,
+	}, opts...)
+}
+
+func newSystemCollector(src systemSources, opts ...SystemOpt) *systemCollector {
+	c := &systemCollector{src: src, prevCore: make(map[string]cpu.TimesStat)}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func (c *systemCollector) Name() string { return SystemCollectorName }
+
+func (c *systemCollector) Collect(ctx context.Context) ([]models.Metrics, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var metrics []models.Metrics
+
+	vmem, memErr := c.src.memory(ctx)
+	if memErr == nil {
+		metrics = append(metrics,
+			gauge("TotalMemory", float64(vmem.Total)),
+			gauge("FreeMemory", float64(vmem.Free)),
+		)
+	}
+
+	cpuMetrics, cpuErr := c.cpuUtilization(ctx)
+	metrics = append(metrics, cpuMetrics...)
+
+	if memErr != nil && cpuErr != nil {
+		return nil, errors.Join(memErr, cpuErr)
+	}
+	return metrics, nil
+}
+
+func (c *systemCollector) cpuUtilization(ctx context.Context) ([]models.Metrics, error) {
+	total, totalErr := c.src.times(ctx, false)
+	cores, coresErr := c.src.times(ctx, true)
+	if totalErr != nil && coresErr != nil {
+		return nil, errors.Join(totalErr, coresErr)
+	}
+
+	var metrics []models.Metrics
+	if totalErr == nil && len(total) > 0 {
+		metrics = c.appendCPU(metrics, "CPUutilization", c.prevAll, total[0])
+		c.prevAll = total[0]
+	}
+	if coresErr == nil {
+		for i, t := range cores {
+			metrics = c.appendCPU(metrics, c.coreMetricName(i), c.prevCore[t.CPU], t)
+			c.prevCore[t.CPU] = t
+		}
+	}
+	return metrics, nil
+}
+
+// coreMetricName returns the utilization metric name of the i-th core.
+func (c *systemCollector) coreMetricName(i int) string {
+	if c.legacyNames {
+		return "CPUutilization" + string(rune('0'+i))
+	}
+	return "CPUutilization" + strconv.Itoa(i)
+}
+
+// appendCPU appends the utilization between two CPU time samples, and the
+// mode breakdown when enabled.
+func (c *systemCollector) appendCPU(metrics []models.Metrics, name string, prev, cur cpu.TimesStat) []models.Metrics {
+	all := cpuAll(cur) - cpuAll(prev)
+	share := func(d float64) float64 {
+		if all <= 0 {
+			return 0
+		}
+		return math.Min(100, math.Max(0, d/all*100))
+	}
+
+	metrics = append(metrics, gauge(name, share(all-(cur.Idle-prev.Idle))))
+	if c.modes {
+		for _, m := range cpuModes {
+			metrics = append(metrics, gauge(name+m.suffix, share(m.time(cur)-m.time(prev))))
+		}
+	}
+	return metrics
+}
+
+// cpuAll returns the total CPU time, counted the way gopsutil does for
+// utilization: guest time is already part of user time.
+func cpuAll(t cpu.TimesStat) float64 {
+	return t.User + t.System + t.Nice + t.Iowait + t.Irq + t.Softirq + t.Steal + t.Idle
+}
@@ -0,0 +1,65 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: internal/agent/collector.go
+
+// Package agent is a generated GoMock package.
+package agent
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	models "github.com/sbilibin2017/gophmetrics/internal/models"
+)
+
+// MockCollector is a mock of Collector interface.
+type MockCollector struct {
+	ctrl     *gomock.Controller
+	recorder *MockCollectorMockRecorder
+}
+
+// MockCollectorMockRecorder is the mock recorder for MockCollector.
+type MockCollectorMockRecorder struct {
+	mock *MockCollector
+}
+
+// NewMockCollector creates a new mock instance.
+func NewMockCollector(ctrl *gomock.Controller) *MockCollector {
+	mock := &MockCollector{ctrl: ctrl}
+	mock.recorder = &MockCollectorMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockCollector) EXPECT() *MockCollectorMockRecorder {
+	return m.recorder
+}
+
+// Collect mocks base method.
+func (m *MockCollector) Collect(ctx context.Context) ([]models.Metrics, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Collect", ctx)
+	ret0, _ := ret[0].([]models.Metrics)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Collect indicates an expected call of Collect.
+func (mr *MockCollectorMockRecorder) Collect(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Collect", reflect.TypeOf((*MockCollector)(nil).Collect), ctx)
+}
+
+// Name mocks base method.
+func (m *MockCollector) Name() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Name")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// Name indicates an expected call of Name.
+func (mr *MockCollectorMockRecorder) Name() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Name", reflect.TypeOf((*MockCollector)(nil).Name))
+}
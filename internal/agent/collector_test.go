@@ -0,0 +1,220 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/shirou/gopsutil/cpu"
+	"github.com/shirou/gopsutil/mem"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sbilibin2017/gophmetrics/internal/models"
+)
+
+func TestRegistry(t *testing.T) {
+	r := DefaultRegistry()
+	assert.Equal(t, []string{HostCollectorName, PollCountCollectorName, RuntimeCollectorName, RuntimeMetricsCollectorName, SystemCollectorName}, r.Names())
+
+	c, ok := r.Lookup(RuntimeCollectorName)
+	require.True(t, ok)
+	assert.Equal(t, RuntimeCollectorName, c.Name())
+
+	_, ok = r.Lookup("missing")
+	assert.False(t, ok)
+
+	err := r.Register(NewRuntimeCollector())
+	assert.ErrorIs(t, err, ErrDuplicateCollector)
+
+	selected, err := r.Select(SystemCollectorName, PollCountCollectorName)
+	require.NoError(t, err)
+	require.Len(t, selected, 2)
+	assert.Equal(t, SystemCollectorName, selected[0].Name())
+	assert.Equal(t, PollCountCollectorName, selected[1].Name())
+
+	_, err = r.Select("missing")
+	assert.ErrorIs(t, err, ErrUnknownCollector)
+
+	assert.Panics(t, func() { NewRegistry(NewPollCountCollector(), NewPollCountCollector()) })
+}
+
+func TestDefaultCollectors(t *testing.T) {
+	var names []string
+	for _, c := range DefaultCollectors() {
+		names = append(names, c.Name())
+	}
+	assert.Equal(t, []string{PollCountCollectorName, RuntimeMetricsCollectorName, SystemCollectorName}, names)
+}
+
+func TestBuiltinCollectors(t *testing.T) {
+	ctx := context.Background()
+
+	metrics, err := NewPollCountCollector().Collect(ctx)
+	require.NoError(t, err)
+	require.Len(t, metrics, 1)
+	assert.Equal(t, "PollCount", metrics[0].ID)
+	assert.Equal(t, int64(1), *metrics[0].Delta)
+
+	metrics, err = NewRuntimeCollector().Collect(ctx)
+	require.NoError(t, err)
+	assert.Len(t, metrics, 28)
+	for _, m := range metrics {
+		assert.Equal(t, models.Gauge, m.MType)
+		assert.NotNil(t, m.Value)
+	}
+
+	metrics, err = NewSystemCollector().Collect(ctx)
+	require.NoError(t, err)
+	for _, m := range metrics {
+		assert.Equal(t, models.Gauge, m.MType)
+	}
+}
+
+// fakeSystem returns system sources reporting the given CPU times for all
+// cores together and for each of them.
+func fakeSystem(total *cpu.TimesStat, cores []cpu.TimesStat) systemSources {
+	return systemSources{
+		memory: func(ctx context.Context) (*mem.VirtualMemoryStat, error) {
+			return &mem.VirtualMemoryStat{Total: 100, Free: 40}, nil
+		},
+		times: func(ctx context.Context, percpu bool) ([]cpu.TimesStat, error) {
+			if percpu {
+				return cores, nil
+			}
+			return []cpu.TimesStat{*total}, nil
+		},
+	}
+}
+
+func TestSystemCollector_CPU(t *testing.T) {
+	total := cpu.TimesStat{CPU: "cpu-total", User: 30, System: 10, Iowait: 10, Idle: 50}
+	cores := make([]cpu.TimesStat, 12)
+	for i := range cores {
+		cores[i] = cpu.TimesStat{CPU: fmt.Sprintf("cpu%d", i), User: 1, Idle: 3}
+	}
+	c := newSystemCollector(fakeSystem(&total, cores), WithCPUModes())
+
+	metrics, err := c.Collect(context.Background())
+	require.NoError(t, err)
+	byID := metricsByID(metrics)
+	assert.Len(t, metrics, 2+(1+12)*4)
+
+	assert.Equal(t, 100.0, *byID["TotalMemory"].Value)
+	assert.Equal(t, 50.0, *byID["CPUutilization"].Value, "first poll reports utilization since boot")
+	assert.Equal(t, 30.0, *byID["CPUutilizationUser"].Value)
+	assert.Equal(t, 10.0, *byID["CPUutilizationIowait"].Value)
+	assert.Equal(t, 25.0, *byID["CPUutilization0"].Value)
+	assert.Equal(t, 25.0, *byID["CPUutilization11User"].Value)
+	assert.NotContains(t, byID, "CPUutilization12")
+
+	total.User += 10
+	total.Idle += 30
+	metrics, err = c.Collect(context.Background())
+	require.NoError(t, err)
+	byID = metricsByID(metrics)
+	assert.Equal(t, 25.0, *byID["CPUutilization"].Value)
+	assert.Equal(t, 25.0, *byID["CPUutilizationUser"].Value)
+	assert.Equal(t, 0.0, *byID["CPUutilizationSystem"].Value)
+	assert.Equal(t, 0.0, *byID["CPUutilization0"].Value, "no time passed on the core")
+}
+
+func TestSystemCollector_LegacyCPUNames(t *testing.T) {
+	total := cpu.TimesStat{CPU: "cpu-total", User: 1, Idle: 1}
+	cores := make([]cpu.TimesStat, 11)
+	for i := range cores {
+		cores[i] = cpu.TimesStat{CPU: fmt.Sprintf("cpu%d", i), User: 1, Idle: 1}
+	}
+	c := newSystemCollector(fakeSystem(&total, cores), WithLegacyCPUNames())
+
+	metrics, err := c.Collect(context.Background())
+	require.NoError(t, err)
+	byID := metricsByID(metrics)
+	assert.Len(t, metrics, 2+1+11)
+	assert.Contains(t, byID, "CPUutilization0")
+	assert.Contains(t, byID, "CPUutilization9")
+	assert.Contains(t, byID, "CPUutilization:")
+	assert.NotContains(t, byID, "CPUutilization10")
+	assert.NotContains(t, byID, "CPUutilizationUser")
+}
+
+func TestSystemCollector_Errors(t *testing.T) {
+	fail := errors.New("unavailable")
+	c := newSystemCollector(systemSources{
+		memory: func(ctx context.Context) (*mem.VirtualMemoryStat, error) { return nil, fail },
+		times:  func(ctx context.Context, percpu bool) ([]cpu.TimesStat, error) { return nil, fail },
+	})
+
+	_, err := c.Collect(context.Background())
+	assert.ErrorIs(t, err, fail)
+}
+
+func TestRun_WithCollectors(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	first := NewMockCollector(ctrl)
+	second := NewMockCollector(ctrl)
+	mockUpdater := NewMockUpdater(ctrl)
+
+	delta := int64(1)
+	first.EXPECT().Name().Return("first").AnyTimes()
+	first.EXPECT().Collect(gomock.Any()).Return([]models.Metrics{{ID: "First", MType: models.Counter, Delta: &delta}}, nil).MinTimes(1)
+	second.EXPECT().Name().Return("second").AnyTimes()
+	second.EXPECT().Collect(gomock.Any()).Return(nil, errors.New("collect failed")).MinTimes(1)
+
+	var mu sync.Mutex
+	var sent []string
+	mockUpdater.EXPECT().Update(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, metrics []*models.Metrics) error {
+			mu.Lock()
+			defer mu.Unlock()
+			for _, m := range metrics {
+				sent = append(sent, m.ID)
+			}
+			return nil
+		}).AnyTimes()
+
+	pollTicker := time.NewTicker(5 * time.Millisecond)
+	defer pollTicker.Stop()
+	reportTicker := time.NewTicker(20 * time.Millisecond)
+	defer reportTicker.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	err := Run(ctx, mockUpdater, pollTicker, reportTicker, 1, WithCollectors(first, second))
+	assert.NoError(t, err)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Contains(t, sent, "First")
+	assert.NotContains(t, sent, "Alloc")
+}
+
+func TestBroadcast(t *testing.T) {
+	ticker := time.NewTicker(5 * time.Millisecond)
+	defer ticker.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ticks := broadcast(ctx, ticker, 3)
+
+	// Every subscriber receives a tick.
+	for _, ch := range ticks {
+		select {
+		case <-ch:
+		case <-time.After(time.Second):
+			t.Fatal("tick not delivered")
+		}
+	}
+
+	cancel()
+	for _, ch := range ticks {
+		for range ch {
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package agent
+
+import "sync"
+
+// Concurrency limits the number of concurrent outbound requests. Unlike the
+// limit passed to Run, it can be changed while the agent is running: raising
+// it lets queued batches start at once, lowering it lets the requests in
+// flight finish.
+type Concurrency struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	limit  int
+	active int
+}
+
+// NewConcurrency creates a limit of n concurrent requests.
+func NewConcurrency(n int) *Concurrency {
+	c := &Concurrency{limit: n}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+// Set changes the limit to n, which must be greater than 0.
+func (c *Concurrency) Set(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.limit = n
+	c.cond.Broadcast()
+}
+
+// Limit returns the current limit.
+func (c *Concurrency) Limit() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.limit
+}
+
+// acquire waits for a free slot.
+func (c *Concurrency) acquire() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for c.active >= c.limit {
+		c.cond.Wait()
+	}
+	c.active++
+}
+
+// release frees a slot taken by acquire.
+func (c *Concurrency) release() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.active--
+	c.cond.Broadcast()
+}
@@ -0,0 +1,54 @@
+package agent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConcurrency_Set(t *testing.T) {
+	c := NewConcurrency(1)
+	c.acquire()
+
+	acquired := make(chan struct{})
+	go func() {
+		c.acquire()
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("acquired over the limit")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	// Raising the limit lets the waiting request start.
+	c.Set(2)
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("not acquired after raising the limit")
+	}
+	assert.Equal(t, 2, c.Limit())
+
+	// Lowering it below the requests in flight blocks new ones until enough finish.
+	c.Set(1)
+	c.release()
+	acquired = make(chan struct{})
+	go func() {
+		c.acquire()
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("acquired over the lowered limit")
+	case <-time.After(20 * time.Millisecond):
+	}
+	c.release()
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("not acquired after requests finished")
+	}
+}
@@ -0,0 +1,259 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sbilibin2017/gophmetrics/internal/models"
+	"github.com/shirou/gopsutil/disk"
+	"github.com/shirou/gopsutil/host"
+	"github.com/shirou/gopsutil/load"
+	"github.com/shirou/gopsutil/mem"
+	"github.com/shirou/gopsutil/net"
+	"github.com/shirou/gopsutil/process"
+)
+
+// HostCollectorName is the name of the extended host metrics collector.
+const HostCollectorName = "host"
+
+// hostSources are the gopsutil calls used by hostCollector, replaced in tests.
+type hostSources struct {
+	partitions func(ctx context.Context, all bool) ([]disk.PartitionStat, error)
+	usage      func(ctx context.Context, path string) (*disk.UsageStat, error)
+	diskIO     func(ctx context.Context, names ...string) (map[string]disk.IOCountersStat, error)
+	netIO      func(ctx context.Context, pernic bool) ([]net.IOCountersStat, error)
+	loadAvg    func(ctx context.Context) (*load.AvgStat, error)
+	swap       func(ctx context.Context) (*mem.SwapMemoryStat, error)
+	uptime     func(ctx context.Context) (uint64, error)
+	pids       func(ctx context.Context) ([]int32, error)
+}
+
+// hostCollector reports disk, network, load, swap, uptime and process metrics.
+//
+// Metric names are the quantity in CamelCase, suffixed with the sanitized
+// mount point, device or interface where there is one, e.g. DiskUsed_root,
+// DiskReadBytes_sda, NetBytesSent_eth0. Point-in-time values are gauges;
+// cumulative OS counters are reported as counters carrying the increase since
+// the previous poll, so the server-side sum equals the OS counter.
+type hostCollector struct {
+	src hostSources
+
+	mu   sync.Mutex
+	last map[string]uint64 // previous values of cumulative OS counters
+}
+
+// NewHostCollector creates the extended host metrics collector.
+func NewHostCollector() Collector {
+	return newHostCollector(hostSources{
+		partitions: disk.PartitionsWithContext,
+		usage:      disk.UsageWithContext,
+		diskIO:     disk.IOCountersWithContext,
+		netIO:      net.IOCountersWithContext,
+		loadAvg:    load.AvgWithContext,
+		swap:       mem.SwapMemoryWithContext,
+		uptime:     host.UptimeWithContext,
+		pids:       process.PidsWithContext,
+	})
+}
+
+func newHostCollector(src hostSources) *hostCollector {
+	return &hostCollector{src: src, last: make(map[string]uint64)}
+}
+
+func (c *hostCollector) Name() string { return HostCollectorName }
+
+// Collect gathers every group of host metrics. A failing group is skipped;
+// an error is returned only when all of them fail.
+func (c *hostCollector) Collect(ctx context.Context) ([]models.Metrics, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var (
+		metrics []models.Metrics
+		errs    []error
+	)
+	groups := []func(context.Context) ([]models.Metrics, error){
+		c.diskUsage,
+		c.diskCounters,
+		c.netCounters,
+		c.loadAverages,
+		c.swapMemory,
+		c.hostInfo,
+	}
+	for _, group := range groups {
+		m, err := group(ctx)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		metrics = append(metrics, m...)
+	}
+
+	if len(errs) == len(groups) {
+		return nil, errors.Join(errs...)
+	}
+	return metrics, nil
+}
+
+func (c *hostCollector) diskUsage(ctx context.Context) ([]models.Metrics, error) {
+	partitions, err := c.src.partitions(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var metrics []models.Metrics
+	seen := make(map[string]bool)
+	for _, p := range partitions {
+		if seen[p.Mountpoint] {
+			continue
+		}
+		seen[p.Mountpoint] = true
+
+		u, err := c.src.usage(ctx, p.Mountpoint)
+		if err != nil || u.Total == 0 {
+			continue
+		}
+		suffix := "_" + sanitizeMetricSuffix(p.Mountpoint)
+		metrics = append(metrics,
+			gauge("DiskTotal"+suffix, float64(u.Total)),
+			gauge("DiskUsed"+suffix, float64(u.Used)),
+			gauge("DiskFree"+suffix, float64(u.Free)),
+			gauge("DiskUsedPercent"+suffix, u.UsedPercent),
+		)
+	}
+	return metrics, nil
+}
+
+func (c *hostCollector) diskCounters(ctx context.Context) ([]models.Metrics, error) {
+	stats, err := c.src.diskIO(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(stats))
+	for name := range stats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var metrics []models.Metrics
+	for _, name := range names {
+		s := stats[name]
+		suffix := "_" + sanitizeMetricSuffix(name)
+		metrics = c.appendCounter(metrics, "DiskReadBytes"+suffix, s.ReadBytes)
+		metrics = c.appendCounter(metrics, "DiskWriteBytes"+suffix, s.WriteBytes)
+		metrics = c.appendCounter(metrics, "DiskReadCount"+suffix, s.ReadCount)
+		metrics = c.appendCounter(metrics, "DiskWriteCount"+suffix, s.WriteCount)
+	}
+	return metrics, nil
+}
+
+func (c *hostCollector) netCounters(ctx context.Context) ([]models.Metrics, error) {
+	stats, err := c.src.netIO(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var metrics []models.Metrics
+	for _, s := range stats {
+		suffix := "_" + sanitizeMetricSuffix(s.Name)
+		metrics = c.appendCounter(metrics, "NetBytesSent"+suffix, s.BytesSent)
+		metrics = c.appendCounter(metrics, "NetBytesRecv"+suffix, s.BytesRecv)
+		metrics = c.appendCounter(metrics, "NetPacketsSent"+suffix, s.PacketsSent)
+		metrics = c.appendCounter(metrics, "NetPacketsRecv"+suffix, s.PacketsRecv)
+		metrics = c.appendCounter(metrics, "NetErrIn"+suffix, s.Errin)
+		metrics = c.appendCounter(metrics, "NetErrOut"+suffix, s.Errout)
+	}
+	return metrics, nil
+}
+
+func (c *hostCollector) loadAverages(ctx context.Context) ([]models.Metrics, error) {
+	avg, err := c.src.loadAvg(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return []models.Metrics{
+		gauge("Load1", avg.Load1),
+		gauge("Load5", avg.Load5),
+		gauge("Load15", avg.Load15),
+	}, nil
+}
+
+func (c *hostCollector) swapMemory(ctx context.Context) ([]models.Metrics, error) {
+	swap, err := c.src.swap(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return []models.Metrics{
+		gauge("SwapTotal", float64(swap.Total)),
+		gauge("SwapUsed", float64(swap.Used)),
+		gauge("SwapFree", float64(swap.Free)),
+	}, nil
+}
+
+func (c *hostCollector) hostInfo(ctx context.Context) ([]models.Metrics, error) {
+	uptime, uptimeErr := c.src.uptime(ctx)
+	pids, pidsErr := c.src.pids(ctx)
+	if uptimeErr != nil && pidsErr != nil {
+		return nil, errors.Join(uptimeErr, pidsErr)
+	}
+
+	var metrics []models.Metrics
+	if uptimeErr == nil {
+		metrics = append(metrics, gauge("Uptime", float64(uptime)))
+	}
+	if pidsErr == nil {
+		metrics = append(metrics, gauge("ProcessCount", float64(len(pids))))
+	}
+	return metrics, nil
+}
+
+// appendCounter appends the increase of a cumulative OS counter since the
+// previous poll. The first observation only records the baseline; a value
+// lower than the previous one means the counter was reset and is reported
+// as is.
+func (c *hostCollector) appendCounter(metrics []models.Metrics, id string, value uint64) []models.Metrics {
+	prev, ok := c.last[id]
+	c.last[id] = value
+	if !ok {
+		return metrics
+	}
+
+	d := counterDelta(prev, value)
+	return append(metrics, models.Metrics{ID: id, MType: models.Counter, Delta: &d})
+}
+
+// gauge builds a gauge metric.
+func gauge(id string, value float64) models.Metrics {
+	return models.Metrics{ID: id, MType: models.Gauge, Value: &value}
+}
+
+// sanitizeMetricSuffix turns a mount point, device or interface name into a
+// metric name suffix: "/" becomes "root", other characters than letters and
+// digits become underscores, e.g. "/var/lib" becomes "var_lib".
+func sanitizeMetricSuffix(name string) string {
+	if name == "/" {
+		return "root"
+	}
+
+	var sb strings.Builder
+	for _, r := range name {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			sb.WriteRune(r)
+		} else {
+			sb.WriteByte('_')
+		}
+	}
+
+	s := strings.Trim(sb.String(), "_")
+	for strings.Contains(s, "__") {
+		s = strings.ReplaceAll(s, "__", "_")
+	}
+	if s == "" {
+		return "root"
+	}
+	return s
+}
@@ -0,0 +1,135 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/shirou/gopsutil/disk"
+	"github.com/shirou/gopsutil/load"
+	"github.com/shirou/gopsutil/mem"
+	"github.com/shirou/gopsutil/net"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sbilibin2017/gophmetrics/internal/models"
+)
+
+// fakeHost returns host sources with fixed readings; netBytes sets the
+// cumulative bytes sent by eth0.
+func fakeHost(netBytes *uint64) hostSources {
+	return hostSources{
+		partitions: func(ctx context.Context, all bool) ([]disk.PartitionStat, error) {
+			return []disk.PartitionStat{{Mountpoint: "/"}, {Mountpoint: "/var/lib"}, {Mountpoint: "/"}, {Mountpoint: "/proc"}}, nil
+		},
+		usage: func(ctx context.Context, path string) (*disk.UsageStat, error) {
+			if path == "/proc" {
+				return &disk.UsageStat{}, nil
+			}
+			return &disk.UsageStat{Total: 100, Used: 40, Free: 60, UsedPercent: 40}, nil
+		},
+		diskIO: func(ctx context.Context, names ...string) (map[string]disk.IOCountersStat, error) {
+			return nil, errors.New("no disk stats")
+		},
+		netIO: func(ctx context.Context, pernic bool) ([]net.IOCountersStat, error) {
+			return []net.IOCountersStat{{Name: "eth0", BytesSent: *netBytes}}, nil
+		},
+		loadAvg: func(ctx context.Context) (*load.AvgStat, error) {
+			return &load.AvgStat{Load1: 1, Load5: 0.5, Load15: 0.25}, nil
+		},
+		swap: func(ctx context.Context) (*mem.SwapMemoryStat, error) {
+			return &mem.SwapMemoryStat{Total: 10, Used: 4, Free: 6}, nil
+		},
+		uptime: func(ctx context.Context) (uint64, error) { return 3600, nil },
+		pids:   func(ctx context.Context) ([]int32, error) { return []int32{1, 2, 3}, nil },
+	}
+}
+
+func metricsByID(metrics []models.Metrics) map[string]models.Metrics {
+	byID := make(map[string]models.Metrics, len(metrics))
+	for _, m := range metrics {
+		byID[m.ID] = m
+	}
+	return byID
+}
+
+func TestHostCollector_Collect(t *testing.T) {
+	netBytes := uint64(1000)
+	c := newHostCollector(fakeHost(&netBytes))
+	assert.Equal(t, HostCollectorName, c.Name())
+
+	metrics, err := c.Collect(context.Background())
+	require.NoError(t, err)
+	byID := metricsByID(metrics)
+
+	for id, want := range map[string]float64{
+		"DiskTotal_root":          100,
+		"DiskUsed_root":           40,
+		"DiskFree_var_lib":        60,
+		"DiskUsedPercent_var_lib": 40,
+		"Load1":                   1,
+		"Load15":                  0.25,
+		"SwapUsed":                4,
+		"Uptime":                  3600,
+		"ProcessCount":            3,
+	} {
+		m, ok := byID[id]
+		if assert.True(t, ok, id) {
+			assert.Equal(t, models.Gauge, m.MType, id)
+			assert.Equal(t, want, *m.Value, id)
+		}
+	}
+	assert.NotContains(t, byID, "DiskTotal_proc")
+	assert.NotContains(t, byID, "NetBytesSent_eth0", "first poll only records the baseline")
+	assert.Len(t, metrics, 2*4+3+3+2)
+
+	netBytes = 1500
+	metrics, err = c.Collect(context.Background())
+	require.NoError(t, err)
+	m := metricsByID(metrics)["NetBytesSent_eth0"]
+	assert.Equal(t, models.Counter, m.MType)
+	assert.Equal(t, int64(500), *m.Delta)
+
+	netBytes = 200 // counter reset
+	metrics, err = c.Collect(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(200), *metricsByID(metrics)["NetBytesSent_eth0"].Delta)
+}
+
+func TestHostCollector_AllGroupsFail(t *testing.T) {
+	fail := errors.New("unavailable")
+	c := newHostCollector(hostSources{
+		partitions: func(ctx context.Context, all bool) ([]disk.PartitionStat, error) { return nil, fail },
+		diskIO:     func(ctx context.Context, names ...string) (map[string]disk.IOCountersStat, error) { return nil, fail },
+		netIO:      func(ctx context.Context, pernic bool) ([]net.IOCountersStat, error) { return nil, fail },
+		loadAvg:    func(ctx context.Context) (*load.AvgStat, error) { return nil, fail },
+		swap:       func(ctx context.Context) (*mem.SwapMemoryStat, error) { return nil, fail },
+		uptime:     func(ctx context.Context) (uint64, error) { return 0, fail },
+		pids:       func(ctx context.Context) ([]int32, error) { return nil, fail },
+	})
+
+	_, err := c.Collect(context.Background())
+	assert.ErrorIs(t, err, fail)
+}
+
+func TestSanitizeMetricSuffix(t *testing.T) {
+	tests := map[string]string{
+		"/":           "root",
+		"/var/lib":    "var_lib",
+		"eth0":        "eth0",
+		"C:\\":        "C",
+		"/mnt/my--fs": "mnt_my_fs",
+		"---":         "root",
+	}
+	for in, want := range tests {
+		assert.Equal(t, want, sanitizeMetricSuffix(in), in)
+	}
+}
+
+func TestNewHostCollector(t *testing.T) {
+	metrics, err := NewHostCollector().Collect(context.Background())
+	require.NoError(t, err)
+	for _, m := range metrics {
+		assert.NotEmpty(t, m.ID)
+	}
+}
@@ -0,0 +1,246 @@
+package agent
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sbilibin2017/gophmetrics/internal/models"
+	"github.com/shirou/gopsutil/process"
+)
+
+// ProcessCollectorName is the name of the collector watching selected processes.
+const ProcessCollectorName = "process"
+
+// processHandle is the part of *process.Process used by processCollector.
+type processHandle interface {
+	NameWithContext(ctx context.Context) (string, error)
+	PercentWithContext(ctx context.Context, interval time.Duration) (float64, error)
+	MemoryInfoWithContext(ctx context.Context) (*process.MemoryInfoStat, error)
+	NumFDsWithContext(ctx context.Context) (int32, error)
+	NumThreadsWithContext(ctx context.Context) (int32, error)
+	IOCountersWithContext(ctx context.Context) (*process.IOCountersStat, error)
+}
+
+// processSources are the gopsutil calls used by processCollector, replaced in tests.
+type processSources struct {
+	pids func(ctx context.Context) ([]int32, error)
+	open func(ctx context.Context, pid int32) (processHandle, error)
+}
+
+// processTarget is a watched process selected by PID or by name.
+type processTarget struct {
+	label string // metric name suffix
+	pid   int32  // 0 when selected by name
+	name  string
+}
+
+// watchedProcess is a running process seen on a previous poll. The handle is
+// kept between polls because CPU usage is measured against the previous call.
+type watchedProcess struct {
+	handle     processHandle
+	name       string
+	readBytes  uint64
+	writeBytes uint64
+	ioKnown    bool
+}
+
+// processCollector reports resource usage of selected processes.
+//
+// Every target yields ProcessCount, ProcessCPUPercent, ProcessRSS,
+// ProcessOpenFDs and ProcessThreads gauges and ProcessReadBytes and
+// ProcessWriteBytes counters, suffixed with the process name or "pid<N>",
+// e.g. ProcessRSS_nginx. Values of all processes matching a name are summed.
+type processCollector struct {
+	src     processSources
+	targets []processTarget
+
+	mu      sync.Mutex
+	running map[int32]*watchedProcess
+}
+
+// NewProcessCollector creates a collector watching the given processes.
+// A target is either a PID or a process name matching all processes with it.
+func NewProcessCollector(targets ...string) Collector {
+	return newProcessCollector(processSources{
+		pids: process.PidsWithContext,
+		open: func(ctx context.Context, pid int32) (processHandle, error) {
+			return process.NewProcessWithContext(ctx, pid)
+		},
+	}, targets...)
+}
+
+func newProcessCollector(src processSources, targets ...string) *processCollector {
+	c := &processCollector{src: src, running: make(map[int32]*watchedProcess)}
+	for _, t := range targets {
+		t = strings.TrimSpace(t)
+		if t == "" {
+			continue
+		}
+		if pid, err := strconv.ParseInt(t, 10, 32); err == nil && pid > 0 {
+			c.targets = append(c.targets, processTarget{label: "pid" + t, pid: int32(pid)})
+			continue
+		}
+		c.targets = append(c.targets, processTarget{label: sanitizeMetricSuffix(t), name: t})
+	}
+	return c
+}
+
+func (c *processCollector) Name() string { return ProcessCollectorName }
+
+func (c *processCollector) Collect(ctx context.Context) ([]models.Metrics, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.refresh(ctx); err != nil {
+		return nil, err
+	}
+
+	// Sample every watched process once, so a process matched by several
+	// targets is measured once.
+	samples := make(map[int32]processSample)
+	for pid, p := range c.running {
+		if c.matchesAny(pid, p) {
+			samples[pid] = p.sample(ctx)
+		}
+	}
+
+	var metrics []models.Metrics
+	for _, t := range c.targets {
+		var sum processSample
+		for pid, s := range samples {
+			if t.matches(pid, c.running[pid]) {
+				sum.add(s)
+			}
+		}
+
+		suffix := "_" + t.label
+		readBytes, writeBytes := sum.readBytes, sum.writeBytes
+		metrics = append(metrics,
+			gauge("ProcessCount"+suffix, sum.count),
+			gauge("ProcessCPUPercent"+suffix, sum.cpu),
+			gauge("ProcessRSS"+suffix, sum.rss),
+			gauge("ProcessOpenFDs"+suffix, sum.fds),
+			gauge("ProcessThreads"+suffix, sum.threads),
+			models.Metrics{ID: "ProcessReadBytes" + suffix, MType: models.Counter, Delta: &readBytes},
+			models.Metrics{ID: "ProcessWriteBytes" + suffix, MType: models.Counter, Delta: &writeBytes},
+		)
+	}
+	return metrics, nil
+}
+
+// processSample is the resource usage of one or more processes on a poll.
+// IO bytes are the increase since the previous poll.
+type processSample struct {
+	count, cpu, rss, fds, threads float64
+	readBytes, writeBytes         int64
+}
+
+func (s *processSample) add(o processSample) {
+	s.count += o.count
+	s.cpu += o.cpu
+	s.rss += o.rss
+	s.fds += o.fds
+	s.threads += o.threads
+	s.readBytes += o.readBytes
+	s.writeBytes += o.writeBytes
+}
+
+// sample measures the process. Values that cannot be read, e.g. for lack of
+// permissions, are left zero.
+func (p *watchedProcess) sample(ctx context.Context) processSample {
+	s := processSample{count: 1}
+	if v, err := p.handle.PercentWithContext(ctx, 0); err == nil {
+		s.cpu = v
+	}
+	if v, err := p.handle.MemoryInfoWithContext(ctx); err == nil {
+		s.rss = float64(v.RSS)
+	}
+	if v, err := p.handle.NumFDsWithContext(ctx); err == nil {
+		s.fds = float64(v)
+	}
+	if v, err := p.handle.NumThreadsWithContext(ctx); err == nil {
+		s.threads = float64(v)
+	}
+	if v, err := p.handle.IOCountersWithContext(ctx); err == nil {
+		if p.ioKnown {
+			s.readBytes = counterDelta(p.readBytes, v.ReadBytes)
+			s.writeBytes = counterDelta(p.writeBytes, v.WriteBytes)
+		}
+		p.readBytes, p.writeBytes, p.ioKnown = v.ReadBytes, v.WriteBytes, true
+	}
+	return s
+}
+
+// refresh syncs the running processes with the system: processes that exited
+// are forgotten and new ones are opened.
+func (c *processCollector) refresh(ctx context.Context) error {
+	pids, err := c.src.pids(ctx)
+	if err != nil {
+		return err
+	}
+
+	alive := make(map[int32]bool, len(pids))
+	for _, pid := range pids {
+		alive[pid] = true
+		if _, ok := c.running[pid]; ok {
+			continue
+		}
+		if !c.wanted(pid) {
+			continue
+		}
+		handle, err := c.src.open(ctx, pid)
+		if err != nil {
+			continue // exited in the meantime
+		}
+		// Processes not matching any name are kept too, so their names are
+		// not read again on every poll.
+		name, _ := handle.NameWithContext(ctx)
+		c.running[pid] = &watchedProcess{handle: handle, name: name}
+	}
+
+	for pid := range c.running {
+		if !alive[pid] {
+			delete(c.running, pid)
+		}
+	}
+	return nil
+}
+
+// wanted reports whether pid may belong to a target before its name is known.
+func (c *processCollector) wanted(pid int32) bool {
+	for _, t := range c.targets {
+		if t.pid == 0 || t.pid == pid {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesAny reports whether the process belongs to any target.
+func (c *processCollector) matchesAny(pid int32, p *watchedProcess) bool {
+	for _, t := range c.targets {
+		if t.matches(pid, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t processTarget) matches(pid int32, p *watchedProcess) bool {
+	if t.pid != 0 {
+		return t.pid == pid
+	}
+	return p.name == t.name
+}
+
+// counterDelta returns the increase of a cumulative counter, treating a
+// decrease as a reset.
+func counterDelta(prev, cur uint64) int64 {
+	if cur < prev {
+		return int64(cur)
+	}
+	return int64(cur - prev)
+}
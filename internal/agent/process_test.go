@@ -0,0 +1,132 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shirou/gopsutil/process"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeProcess is a processHandle with fixed readings.
+type fakeProcess struct {
+	name      string
+	cpu       float64
+	rss       uint64
+	fds       int32
+	threads   int32
+	readBytes uint64
+	ioErr     error
+}
+
+func (p *fakeProcess) NameWithContext(ctx context.Context) (string, error) { return p.name, nil }
+
+func (p *fakeProcess) PercentWithContext(ctx context.Context, interval time.Duration) (float64, error) {
+	return p.cpu, nil
+}
+
+func (p *fakeProcess) MemoryInfoWithContext(ctx context.Context) (*process.MemoryInfoStat, error) {
+	return &process.MemoryInfoStat{RSS: p.rss}, nil
+}
+
+func (p *fakeProcess) NumFDsWithContext(ctx context.Context) (int32, error) { return p.fds, nil }
+
+func (p *fakeProcess) NumThreadsWithContext(ctx context.Context) (int32, error) {
+	return p.threads, nil
+}
+
+func (p *fakeProcess) IOCountersWithContext(ctx context.Context) (*process.IOCountersStat, error) {
+	if p.ioErr != nil {
+		return nil, p.ioErr
+	}
+	return &process.IOCountersStat{ReadBytes: p.readBytes}, nil
+}
+
+// fakeProcesses returns process sources listing the given processes.
+func fakeProcesses(procs map[int32]*fakeProcess) processSources {
+	return processSources{
+		pids: func(ctx context.Context) ([]int32, error) {
+			pids := make([]int32, 0, len(procs))
+			for pid := range procs {
+				pids = append(pids, pid)
+			}
+			return pids, nil
+		},
+		open: func(ctx context.Context, pid int32) (processHandle, error) {
+			p, ok := procs[pid]
+			if !ok {
+				return nil, errors.New("no such process")
+			}
+			return p, nil
+		},
+	}
+}
+
+func TestNewProcessCollector_Targets(t *testing.T) {
+	c := newProcessCollector(processSources{}, "nginx", " 42 ", "", "my-app", "-1")
+	assert.Equal(t, ProcessCollectorName, c.Name())
+	assert.Equal(t, []processTarget{
+		{label: "nginx", name: "nginx"},
+		{label: "pid42", pid: 42},
+		{label: "my_app", name: "my-app"},
+		{label: "1", name: "-1"},
+	}, c.targets)
+}
+
+func TestProcessCollector_Collect(t *testing.T) {
+	procs := map[int32]*fakeProcess{
+		10: {name: "nginx", cpu: 1.5, rss: 100, fds: 5, threads: 2, readBytes: 1000},
+		11: {name: "nginx", cpu: 2.5, rss: 200, fds: 7, threads: 3, readBytes: 500},
+		20: {name: "postgres", cpu: 10, rss: 1000, fds: 50, threads: 8, ioErr: errors.New("permission denied")},
+		30: {name: "bash"},
+	}
+	c := newProcessCollector(fakeProcesses(procs), "nginx", "20", "redis")
+
+	metrics, err := c.Collect(context.Background())
+	require.NoError(t, err)
+	byID := metricsByID(metrics)
+	assert.Len(t, metrics, 21)
+
+	for id, want := range map[string]float64{
+		"ProcessCount_nginx":      2,
+		"ProcessCPUPercent_nginx": 4,
+		"ProcessRSS_nginx":        300,
+		"ProcessOpenFDs_nginx":    12,
+		"ProcessThreads_nginx":    5,
+		"ProcessCount_pid20":      1,
+		"ProcessRSS_pid20":        1000,
+		"ProcessCount_redis":      0,
+		"ProcessRSS_redis":        0,
+	} {
+		m, ok := byID[id]
+		if assert.True(t, ok, id) {
+			assert.Equal(t, want, *m.Value, id)
+		}
+	}
+	// The first poll only records the IO baseline.
+	assert.Equal(t, int64(0), *byID["ProcessReadBytes_nginx"].Delta)
+
+	procs[10].readBytes += 300
+	procs[11].readBytes += 200
+	delete(procs, 20)
+
+	metrics, err = c.Collect(context.Background())
+	require.NoError(t, err)
+	byID = metricsByID(metrics)
+
+	assert.Equal(t, int64(500), *byID["ProcessReadBytes_nginx"].Delta)
+	assert.Equal(t, 0.0, *byID["ProcessCount_pid20"].Value)
+	assert.NotContains(t, c.running, int32(20))
+}
+
+func TestProcessCollector_PidsError(t *testing.T) {
+	c := newProcessCollector(processSources{
+		pids: func(ctx context.Context) ([]int32, error) { return nil, errors.New("no proc") },
+	}, "nginx")
+
+	_, err := c.Collect(context.Background())
+	assert.EqualError(t, err, "no proc")
+}
@@ -0,0 +1,150 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/sbilibin2017/gophmetrics/internal/models"
+)
+
+// ReceiverCollectorName is the name of the collector reporting metrics pushed
+// to the agent by local applications.
+const ReceiverCollectorName = "receiver"
+
+// receiverMaxPending limits the number of distinct metrics held between polls.
+const receiverMaxPending = 10000
+
+// Receiver errors.
+var (
+	ErrInvalidMetric = errors.New("invalid metric")
+	ErrReceiverFull  = errors.New("too many pending metrics")
+)
+
+// Receiver accepts metrics pushed by applications on the same host and
+// reports them with the agent's regular cycle, so the applications need
+// neither the server address nor its key.
+//
+// Pushed metrics are coalesced like the agent's own: counter deltas are summed
+// and the latest gauge value wins until the next poll takes them.
+type Receiver struct {
+	mu      sync.Mutex
+	pending *aggregator
+	gauges  map[string]float64 // latest gauge values for relative StatsD gauges, at most receiverMaxPending
+}
+
+// NewReceiver creates an empty receiver.
+func NewReceiver() *Receiver {
+	return &Receiver{pending: newAggregator(), gauges: make(map[string]float64)}
+}
+
+func (r *Receiver) Name() string { return ReceiverCollectorName }
+
+// Collect returns the metrics pushed since the previous poll.
+func (r *Receiver) Collect(ctx context.Context) ([]models.Metrics, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	batch := r.pending.flush()
+	metrics := make([]models.Metrics, len(batch))
+	for i, m := range batch {
+		metrics[i] = *m
+	}
+	return metrics, nil
+}
+
+// Update queues a pushed metric. It has the signature of the server's metric
+// updater, so the server's HTTP handlers can be mounted on the agent.
+func (r *Receiver) Update(ctx context.Context, metric *models.Metrics) (*models.Metrics, error) {
+	if err := r.add(*metric, false); err != nil {
+		return nil, err
+	}
+	return metric, nil
+}
+
+// UpdateBatch queues a batch of pushed metrics, all of them or none.
+func (r *Receiver) UpdateBatch(ctx context.Context, metrics []*models.Metrics) ([]*models.Metrics, error) {
+	for _, m := range metrics {
+		if err := validateReceived(*m); err != nil {
+			return nil, err
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var added []models.MetricID
+	for _, m := range metrics {
+		id := models.MetricID{ID: m.ID, MType: m.MType}
+		if _, ok := r.pending.index[id]; !ok && !slices.Contains(added, id) {
+			added = append(added, id)
+		}
+	}
+	if len(added) > 0 && r.pending.len()+len(added) > receiverMaxPending {
+		return nil, ErrReceiverFull
+	}
+	for _, m := range metrics {
+		r.addLocked(*m)
+	}
+	return metrics, nil
+}
+
+// add queues a pushed metric. The value of a relative gauge is added to the
+// latest value received for the gauge.
+func (r *Receiver) add(m models.Metrics, relative bool) error {
+	if err := validateReceived(m); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.pending.index[models.MetricID{ID: m.ID, MType: m.MType}]; !ok && r.pending.len() >= receiverMaxPending {
+		return ErrReceiverFull
+	}
+	if relative && m.MType == models.Gauge {
+		v := r.gauges[m.ID] + *m.Value
+		m.Value = &v
+	}
+	r.addLocked(m)
+	return nil
+}
+
+// addLocked queues a valid metric. r.mu must be held.
+func (r *Receiver) addLocked(m models.Metrics) {
+	if m.MType == models.Gauge {
+		if _, ok := r.gauges[m.ID]; !ok && len(r.gauges) >= receiverMaxPending {
+			r.evictGauges()
+		}
+		r.gauges[m.ID] = *m.Value
+	}
+	r.pending.add(m)
+}
+
+// evictGauges forgets the latest values of the gauges not pending, so that
+// the values kept stay bounded. A relative gauge received later starts from 0
+// again. r.mu must be held.
+func (r *Receiver) evictGauges() {
+	for id := range r.gauges {
+		if _, ok := r.pending.index[models.MetricID{ID: id, MType: models.Gauge}]; !ok {
+			delete(r.gauges, id)
+		}
+	}
+}
+
+// validateReceived returns ErrInvalidMetric if m cannot be reported.
+func validateReceived(m models.Metrics) error {
+	switch {
+	case m.ID == "":
+		return fmt.Errorf("%w: empty id", ErrInvalidMetric)
+	case m.MType == models.Counter && m.Delta == nil:
+		return fmt.Errorf("%w: counter %s without delta", ErrInvalidMetric, m.ID)
+	case m.MType == models.Gauge && m.Value == nil:
+		return fmt.Errorf("%w: gauge %s without value", ErrInvalidMetric, m.ID)
+	case m.MType != models.Counter && m.MType != models.Gauge:
+		return fmt.Errorf("%w: type %q", ErrInvalidMetric, m.MType)
+	}
+	return nil
+}
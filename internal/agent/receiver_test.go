@@ -0,0 +1,128 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sbilibin2017/gophmetrics/internal/models"
+)
+
+func TestReceiver_Collect(t *testing.T) {
+	ctx := context.Background()
+	r := NewReceiver()
+	assert.Equal(t, ReceiverCollectorName, r.Name())
+
+	delta := int64(2)
+	value := 1.5
+	for _, m := range []models.Metrics{
+		{ID: "requests", MType: models.Counter, Delta: &delta},
+		{ID: "requests", MType: models.Counter, Delta: &delta},
+		{ID: "queue", MType: models.Gauge, Value: &value},
+	} {
+		_, err := r.Update(ctx, &m)
+		require.NoError(t, err)
+	}
+
+	metrics, err := r.Collect(ctx)
+	require.NoError(t, err)
+	byID := metricsByID(metrics)
+	require.Len(t, metrics, 2)
+	assert.Equal(t, int64(4), *byID["requests"].Delta)
+	assert.Equal(t, 1.5, *byID["queue"].Value)
+
+	metrics, err = r.Collect(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, metrics, "pushed metrics are reported once")
+}
+
+func TestReceiver_Invalid(t *testing.T) {
+	ctx := context.Background()
+	r := NewReceiver()
+
+	delta := int64(1)
+	for _, m := range []models.Metrics{
+		{MType: models.Counter, Delta: &delta},
+		{ID: "a", MType: models.Counter},
+		{ID: "a", MType: models.Gauge},
+		{ID: "a", MType: "histogram", Delta: &delta},
+	} {
+		_, err := r.Update(ctx, &m)
+		assert.ErrorIs(t, err, ErrInvalidMetric)
+	}
+}
+
+func TestReceiver_Full(t *testing.T) {
+	ctx := context.Background()
+	r := NewReceiver()
+
+	delta := int64(1)
+	for i := 0; i < receiverMaxPending; i++ {
+		_, err := r.Update(ctx, &models.Metrics{ID: fmt.Sprint(i), MType: models.Counter, Delta: &delta})
+		require.NoError(t, err)
+	}
+
+	_, err := r.Update(ctx, &models.Metrics{ID: "new", MType: models.Counter, Delta: &delta})
+	assert.ErrorIs(t, err, ErrReceiverFull)
+	_, err = r.Update(ctx, &models.Metrics{ID: "0", MType: models.Counter, Delta: &delta})
+	assert.NoError(t, err, "pending metrics can still be updated")
+
+	_, err = r.Collect(ctx)
+	require.NoError(t, err)
+	_, err = r.Update(ctx, &models.Metrics{ID: "new", MType: models.Counter, Delta: &delta})
+	assert.NoError(t, err)
+}
+
+func TestReceiver_UpdateBatch(t *testing.T) {
+	ctx := context.Background()
+	r := NewReceiver()
+
+	delta := int64(1)
+	value := 2.5
+	_, err := r.UpdateBatch(ctx, []*models.Metrics{
+		{ID: "requests", MType: models.Counter, Delta: &delta},
+		{ID: "queue", MType: models.Gauge},
+	})
+	assert.ErrorIs(t, err, ErrInvalidMetric)
+	metrics, err := r.Collect(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, metrics, "an invalid batch queues nothing")
+
+	_, err = r.UpdateBatch(ctx, []*models.Metrics{
+		{ID: "requests", MType: models.Counter, Delta: &delta},
+		{ID: "requests", MType: models.Counter, Delta: &delta},
+		{ID: "queue", MType: models.Gauge, Value: &value},
+	})
+	require.NoError(t, err)
+	metrics, err = r.Collect(ctx)
+	require.NoError(t, err)
+	byID := metricsByID(metrics)
+	assert.Equal(t, int64(2), *byID["requests"].Delta)
+	assert.Equal(t, 2.5, *byID["queue"].Value)
+}
+
+func TestReceiver_GaugesBounded(t *testing.T) {
+	ctx := context.Background()
+	r := NewReceiver()
+
+	value := 1.0
+	for i := 0; i < receiverMaxPending; i++ {
+		_, err := r.Update(ctx, &models.Metrics{ID: fmt.Sprint(i), MType: models.Gauge, Value: &value})
+		require.NoError(t, err)
+	}
+	_, err := r.Collect(ctx)
+	require.NoError(t, err)
+
+	_, err = r.Update(ctx, &models.Metrics{ID: "pending", MType: models.Gauge, Value: &value})
+	require.NoError(t, err)
+	_, err = r.Update(ctx, &models.Metrics{ID: "new", MType: models.Gauge, Value: &value})
+	require.NoError(t, err)
+
+	assert.LessOrEqual(t, len(r.gauges), receiverMaxPending)
+	assert.Contains(t, r.gauges, "pending", "pending gauges are kept")
+	assert.Contains(t, r.gauges, "new")
+	assert.NotContains(t, r.gauges, "0", "reported gauges are evicted")
+}
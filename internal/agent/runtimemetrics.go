@@ -0,0 +1,186 @@
+package agent
+
+import (
+	"context"
+	"math"
+	"runtime/metrics"
+	"strings"
+	"sync"
+
+	"github.com/sbilibin2017/gophmetrics/internal/models"
+)
+
+// RuntimeMetricsCollectorName is the name of the runtime/metrics collector.
+const RuntimeMetricsCollectorName = "runtimemetrics"
+
+// histogramQuantiles are the quantiles reported for runtime histograms.
+var histogramQuantiles = []struct {
+	suffix string
+	q      float64
+}{
+	{"P50", 0.5},
+	{"P90", 0.9},
+	{"P99", 0.99},
+}
+
+// runtimeMetricsCollector reads Go runtime samples with runtime/metrics, which
+// unlike runtime.ReadMemStats does not stop the world.
+//
+// Sample names are mapped to CamelCase metric names, e.g.
+// /gc/heap/allocs:bytes becomes GCHeapAllocsBytes and
+// /sched/goroutines:goroutines becomes SchedGoroutines. Cumulative integer
+// samples are reported as counters carrying the increase since the previous
+// poll, other scalar samples as gauges. A histogram yields a Count counter
+// with the number of new observations and P50, P90 and P99 gauges computed
+// over them, e.g. GCPausesSecondsP99.
+type runtimeMetricsCollector struct {
+	mu      sync.Mutex
+	samples []metrics.Sample
+	names   []string // metric names by sample index
+	counter []bool   // whether a sample is reported as a counter
+	last    map[string]uint64
+	buckets map[string][]uint64 // previous histogram bucket counts
+}
+
+// NewRuntimeMetricsCollector creates a collector reporting the runtime/metrics
+// samples whose names start with one of the given prefixes, e.g. "/gc/" or
+// "/sched/". All supported samples are reported when no prefix is given.
+func NewRuntimeMetricsCollector(prefixes ...string) Collector {
+	return newRuntimeMetricsCollector(metrics.All(), prefixes...)
+}
+
+func newRuntimeMetricsCollector(descs []metrics.Description, prefixes ...string) *runtimeMetricsCollector {
+	c := &runtimeMetricsCollector{
+		last:    make(map[string]uint64),
+		buckets: make(map[string][]uint64),
+	}
+	for _, d := range descs {
+		if d.Kind == metrics.KindBad || !hasAnyPrefix(d.Name, prefixes) {
+			continue
+		}
+		c.samples = append(c.samples, metrics.Sample{Name: d.Name})
+		c.names = append(c.names, runtimeMetricName(d.Name))
+		c.counter = append(c.counter, d.Cumulative && d.Kind == metrics.KindUint64)
+	}
+	return c
+}
+
+func (c *runtimeMetricsCollector) Name() string { return RuntimeMetricsCollectorName }
+
+func (c *runtimeMetricsCollector) Collect(ctx context.Context) ([]models.Metrics, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// The samples are reused between polls to avoid allocating on every read.
+	metrics.Read(c.samples)
+
+	var result []models.Metrics
+	for i, s := range c.samples {
+		name := c.names[i]
+		switch s.Value.Kind() {
+		case metrics.KindUint64:
+			v := s.Value.Uint64()
+			if !c.counter[i] {
+				result = append(result, gauge(name, float64(v)))
+				continue
+			}
+			prev, ok := c.last[name]
+			c.last[name] = v
+			if ok {
+				d := counterDelta(prev, v)
+				result = append(result, models.Metrics{ID: name, MType: models.Counter, Delta: &d})
+			}
+		case metrics.KindFloat64:
+			result = append(result, gauge(name, s.Value.Float64()))
+		case metrics.KindFloat64Histogram:
+			result = c.appendHistogram(result, name, s.Value.Float64Histogram())
+		}
+	}
+	return result, nil
+}
+
+// appendHistogram appends the number of observations since the previous poll
+// and quantiles over them. The first observation only records the baseline.
+func (c *runtimeMetricsCollector) appendHistogram(result []models.Metrics, name string, h *metrics.Float64Histogram) []models.Metrics {
+	prev, ok := c.buckets[name]
+	if !ok || len(prev) != len(h.Counts) {
+		c.buckets[name] = append([]uint64(nil), h.Counts...)
+		return result
+	}
+
+	window := make([]uint64, len(h.Counts))
+	var total uint64
+	for i, n := range h.Counts {
+		if n >= prev[i] {
+			window[i] = n - prev[i]
+		}
+		total += window[i]
+		prev[i] = n
+	}
+
+	count := int64(total)
+	result = append(result, models.Metrics{ID: name + "Count", MType: models.Counter, Delta: &count})
+	if total == 0 {
+		return result
+	}
+	for _, q := range histogramQuantiles {
+		result = append(result, gauge(name+q.suffix, histogramQuantile(window, h.Buckets, total, q.q)))
+	}
+	return result
+}
+
+// histogramQuantile estimates quantile q as the upper boundary of the bucket
+// it falls into, or the lower boundary for the unbounded last bucket.
+func histogramQuantile(counts []uint64, buckets []float64, total uint64, q float64) float64 {
+	rank := uint64(math.Ceil(q * float64(total)))
+	var seen uint64
+	for i, n := range counts {
+		seen += n
+		if seen < rank || n == 0 {
+			continue
+		}
+		if upper := buckets[i+1]; !math.IsInf(upper, 1) {
+			return upper
+		}
+		return buckets[i]
+	}
+	return buckets[len(buckets)-1]
+}
+
+// runtimeMetricName maps a runtime/metrics sample name to a metric name.
+// The unit is dropped when it repeats the last path element.
+func runtimeMetricName(sample string) string {
+	path, unit, _ := strings.Cut(sample, ":")
+	elems := strings.Split(strings.Trim(path, "/"), "/")
+	if unit == elems[len(elems)-1] {
+		unit = ""
+	}
+
+	var sb strings.Builder
+	for _, elem := range append(elems, unit) {
+		for _, word := range strings.FieldsFunc(elem, func(r rune) bool {
+			return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+		}) {
+			switch word {
+			case "gc", "cpu", "os":
+				sb.WriteString(strings.ToUpper(word))
+			default:
+				sb.WriteString(strings.ToUpper(word[:1]) + word[1:])
+			}
+		}
+	}
+	return sb.String()
+}
+
+// hasAnyPrefix reports whether s starts with one of prefixes, or prefixes is empty.
+func hasAnyPrefix(s string, prefixes []string) bool {
+	if len(prefixes) == 0 {
+		return true
+	}
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+package agent
+
+import (
+	"context"
+	"runtime"
+	"runtime/metrics"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sbilibin2017/gophmetrics/internal/models"
+)
+
+func TestRuntimeMetricName(t *testing.T) {
+	tests := map[string]string{
+		"/gc/heap/allocs:bytes":             "GCHeapAllocsBytes",
+		"/sched/goroutines:goroutines":      "SchedGoroutines",
+		"/sched/latencies:seconds":          "SchedLatenciesSeconds",
+		"/cpu/classes/gc/total:cpu-seconds": "CPUClassesGCTotalCPUSeconds",
+		"/memory/classes/os-stacks:bytes":   "MemoryClassesOSStacksBytes",
+	}
+	for in, want := range tests {
+		assert.Equal(t, want, runtimeMetricName(in), in)
+	}
+}
+
+func TestRuntimeMetricName_Unique(t *testing.T) {
+	seen := make(map[string]string)
+	for _, d := range metrics.All() {
+		name := runtimeMetricName(d.Name)
+		if prev, ok := seen[name]; ok {
+			t.Errorf("%s and %s both map to %s", prev, d.Name, name)
+		}
+		seen[name] = d.Name
+	}
+}
+
+func TestRuntimeMetricsCollector_Collect(t *testing.T) {
+	c := NewRuntimeMetricsCollector("/gc/", "/sched/")
+	assert.Equal(t, RuntimeMetricsCollectorName, c.Name())
+
+	metrics, err := c.Collect(context.Background())
+	require.NoError(t, err)
+	byID := metricsByID(metrics)
+
+	goroutines, ok := byID["SchedGoroutines"]
+	require.True(t, ok)
+	assert.Equal(t, models.Gauge, goroutines.MType)
+	assert.Positive(t, *goroutines.Value)
+	assert.NotContains(t, byID, "GCCyclesTotalGCCycles", "first poll only records the baseline")
+	assert.NotContains(t, byID, "GCPausesSecondsCount", "first poll only records the baseline")
+	for id := range byID {
+		assert.NotContains(t, id, "Memory", "not selected")
+	}
+
+	runtime.GC()
+	metrics, err = c.Collect(context.Background())
+	require.NoError(t, err)
+	byID = metricsByID(metrics)
+
+	cycles, ok := byID["GCCyclesTotalGCCycles"]
+	require.True(t, ok)
+	assert.Equal(t, models.Counter, cycles.MType)
+	assert.GreaterOrEqual(t, *cycles.Delta, int64(1))
+}
+
+func TestHistogramQuantile(t *testing.T) {
+	buckets := []float64{0, 1, 2, 4, inf()}
+	counts := []uint64{5, 3, 1, 1}
+
+	assert.Equal(t, 1.0, histogramQuantile(counts, buckets, 10, 0.5))
+	assert.Equal(t, 2.0, histogramQuantile(counts, buckets, 10, 0.8))
+	assert.Equal(t, 4.0, histogramQuantile(counts, buckets, 10, 0.99), "unbounded bucket reports its lower boundary")
+}
+
+func TestRuntimeMetricsCollector_Histogram(t *testing.T) {
+	c := newRuntimeMetricsCollector(nil)
+	h := &metrics.Float64Histogram{Buckets: []float64{0, 1, 2}, Counts: []uint64{1, 1}}
+
+	assert.Empty(t, c.appendHistogram(nil, "Lat", h))
+
+	h.Counts = []uint64{1, 5}
+	byID := metricsByID(c.appendHistogram(nil, "Lat", h))
+	assert.Equal(t, int64(4), *byID["LatCount"].Delta)
+	assert.Equal(t, 2.0, *byID["LatP50"].Value)
+
+	byID = metricsByID(c.appendHistogram(nil, "Lat", h))
+	assert.Equal(t, int64(0), *byID["LatCount"].Delta)
+	assert.NotContains(t, byID, "LatP50", "no new observations")
+}
+
+func inf() float64 {
+	var zero float64
+	return 1 / zero
+}
@@ -0,0 +1,184 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sbilibin2017/gophmetrics/internal/models"
+	"github.com/sbilibin2017/gophmetrics/internal/promtext"
+)
+
+// ScrapeCollectorName is the name of the collector scraping Prometheus endpoints.
+const ScrapeCollectorName = "scrape"
+
+// Scrape limits.
+const (
+	scrapeTimeout = 10 * time.Second
+	scrapeMaxBody = 10 << 20
+)
+
+// scrapeTarget is a Prometheus endpoint, optionally named to prefix its metrics.
+type scrapeTarget struct {
+	name string
+	url  string
+}
+
+// scrapeCollector pulls Prometheus text exposition from HTTP endpoints.
+//
+// A sample is reported under its name followed by its label names and values
+// in label name order, e.g. http_requests_total{method="get",code="200"}
+// becomes http_requests_total_code_200_method_get, prefixed with the target
+// name and an underscore when the target is named. Counters and the _count
+// and _bucket samples of histograms and summaries are reported as counters
+// carrying the increase since the previous scrape; all other samples are
+// gauges. Samples that are not finite numbers are skipped.
+type scrapeCollector struct {
+	client  *http.Client
+	targets []scrapeTarget
+
+	mu   sync.Mutex
+	last map[string]float64 // previous values of cumulative samples
+}
+
+// NewScrapeCollector creates a collector scraping the given targets. A target
+// is a URL, or NAME=URL to prefix the metrics of the target with NAME.
+func NewScrapeCollector(targets ...string) Collector {
+	return newScrapeCollector(&http.Client{Timeout: scrapeTimeout}, targets...)
+}
+
+func newScrapeCollector(client *http.Client, targets ...string) *scrapeCollector {
+	c := &scrapeCollector{client: client, last: make(map[string]float64)}
+	for _, t := range targets {
+		t = strings.TrimSpace(t)
+		if t == "" {
+			continue
+		}
+		name, url, ok := strings.Cut(t, "=")
+		if !ok || strings.Contains(name, "/") {
+			name, url = "", t
+		}
+		c.targets = append(c.targets, scrapeTarget{name: name, url: url})
+	}
+	return c
+}
+
+func (c *scrapeCollector) Name() string { return ScrapeCollectorName }
+
+// Collect scrapes every target. A failing target is skipped; an error is
+// returned only when all of them fail.
+func (c *scrapeCollector) Collect(ctx context.Context) ([]models.Metrics, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var (
+		metrics []models.Metrics
+		errs    []error
+	)
+	for _, t := range c.targets {
+		samples, err := c.scrape(ctx, t.url)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("scrape %s: %w", t.url, err))
+			continue
+		}
+		metrics = c.appendSamples(metrics, t.name, samples)
+	}
+
+	if len(errs) > 0 && len(errs) == len(c.targets) {
+		return nil, errors.Join(errs...)
+	}
+	return metrics, nil
+}
+
+func (c *scrapeCollector) scrape(ctx context.Context, url string) ([]promtext.Sample, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/plain;version=0.0.4")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return promtext.Parse(io.LimitReader(resp.Body, scrapeMaxBody))
+}
+
+func (c *scrapeCollector) appendSamples(metrics []models.Metrics, prefix string, samples []promtext.Sample) []models.Metrics {
+	for _, s := range samples {
+		if math.IsNaN(s.Value) || math.IsInf(s.Value, 0) {
+			continue
+		}
+		id := scrapeMetricID(prefix, s)
+		if !cumulative(s) {
+			metrics = append(metrics, gauge(id, s.Value))
+			continue
+		}
+
+		prev, ok := c.last[id]
+		c.last[id] = s.Value
+		if !ok {
+			continue
+		}
+		// Deltas are taken between whole parts, so fractional increases are
+		// not lost over several scrapes.
+		d := int64(math.Floor(s.Value) - math.Floor(prev))
+		if s.Value < prev {
+			d = int64(math.Floor(s.Value)) // counter reset
+		}
+		metrics = append(metrics, models.Metrics{ID: id, MType: models.Counter, Delta: &d})
+	}
+	return metrics
+}
+
+// cumulative reports whether the sample only ever grows until a reset.
+func cumulative(s promtext.Sample) bool {
+	switch s.Type {
+	case promtext.TypeCounter:
+		return true
+	case promtext.TypeHistogram, promtext.TypeSummary:
+		return strings.HasSuffix(s.Name, "_count") || strings.HasSuffix(s.Name, "_bucket")
+	}
+	return false
+}
+
+// scrapeMetricID builds the metric name of a sample.
+func scrapeMetricID(prefix string, s promtext.Sample) string {
+	labels := append([]promtext.Label(nil), s.Labels...)
+	sort.Slice(labels, func(i, j int) bool { return labels[i].Name < labels[j].Name })
+
+	var sb strings.Builder
+	if prefix != "" {
+		sb.WriteString(prefix)
+		sb.WriteByte('_')
+	}
+	sb.WriteString(s.Name)
+	for _, l := range labels {
+		sb.WriteByte('_')
+		sb.WriteString(l.Name)
+		sb.WriteByte('_')
+		sb.WriteString(sanitizeLabelValue(l.Value))
+	}
+	return sb.String()
+}
+
+// sanitizeLabelValue turns a label value into a part of a metric name,
+// keeping "+Inf" of histogram buckets readable.
+func sanitizeLabelValue(v string) string {
+	if v == "+Inf" {
+		return "inf"
+	}
+	return sanitizeMetricSuffix(v)
+}
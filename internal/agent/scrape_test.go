@@ -0,0 +1,94 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sbilibin2017/gophmetrics/internal/models"
+)
+
+func TestNewScrapeCollector_Targets(t *testing.T) {
+	c := newScrapeCollector(http.DefaultClient, "http://a/metrics", " app=http://b/metrics ", "", "http://c/metrics?x=1")
+	assert.Equal(t, ScrapeCollectorName, c.Name())
+	assert.Equal(t, []scrapeTarget{
+		{url: "http://a/metrics"},
+		{name: "app", url: "http://b/metrics"},
+		{url: "http://c/metrics?x=1"},
+	}, c.targets)
+}
+
+func TestScrapeCollector_Collect(t *testing.T) {
+	requests := 0.0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `# TYPE http_requests_total counter
+http_requests_total{method="get",code="200"} %g
+# TYPE temperature gauge
+temperature 21.5
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="0.1"} %g
+latency_seconds_bucket{le="+Inf"} %g
+latency_seconds_sum 0.5
+latency_seconds_count %g
+broken NaN
+`, requests, requests, requests, requests)
+	}))
+	defer srv.Close()
+
+	c := newScrapeCollector(srv.Client(), "app="+srv.URL)
+
+	requests = 10.5
+	metrics, err := c.Collect(context.Background())
+	require.NoError(t, err)
+	byID := metricsByID(metrics)
+	assert.Len(t, metrics, 2, "first scrape only records counter baselines")
+	assert.Equal(t, 21.5, *byID["app_temperature"].Value)
+	assert.Equal(t, 0.5, *byID["app_latency_seconds_sum"].Value)
+
+	requests = 12.2
+	metrics, err = c.Collect(context.Background())
+	require.NoError(t, err)
+	byID = metricsByID(metrics)
+
+	m := byID["app_http_requests_total_code_200_method_get"]
+	assert.Equal(t, models.Counter, m.MType)
+	assert.Equal(t, int64(2), *m.Delta)
+	assert.Equal(t, int64(2), *byID["app_latency_seconds_bucket_le_0_1"].Delta)
+	assert.Equal(t, int64(2), *byID["app_latency_seconds_bucket_le_inf"].Delta)
+	assert.Equal(t, int64(2), *byID["app_latency_seconds_count"].Delta)
+	assert.NotContains(t, byID, "app_broken")
+
+	requests = 3 // restarted
+	metrics, err = c.Collect(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), *metricsByID(metrics)["app_latency_seconds_count"].Delta)
+}
+
+func TestScrapeCollector_Errors(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+	malformed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "up")
+	}))
+	defer malformed.Close()
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "up 1")
+	}))
+	defer ok.Close()
+
+	_, err := newScrapeCollector(http.DefaultClient, failing.URL, malformed.URL).Collect(context.Background())
+	assert.ErrorContains(t, err, "503")
+	assert.ErrorContains(t, err, "line 1")
+
+	metrics, err := newScrapeCollector(http.DefaultClient, failing.URL, ok.URL).Collect(context.Background())
+	require.NoError(t, err)
+	require.Len(t, metrics, 1)
+	assert.Equal(t, "up", metrics[0].ID)
+}
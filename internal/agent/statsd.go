@@ -0,0 +1,101 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/sbilibin2017/gophmetrics/internal/models"
+)
+
+// statsdMaxPacket is the largest StatsD datagram read.
+const statsdMaxPacket = 64 * 1024
+
+// ServeStatsD reads StatsD datagrams from conn until ctx is done.
+//
+// Every line of a datagram is name:value|type[|@rate][|#tags]. Counters (c)
+// are scaled by the sample rate and rounded. Gauges (g) are set, or adjusted
+// when the value has an explicit sign. Timers (ms), histograms (h) and
+// distributions (d) are reported as gauges holding the latest value. Sets
+// and malformed lines are logged and skipped.
+func (r *Receiver) ServeStatsD(ctx context.Context, conn net.PacketConn) error {
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	buf := make([]byte, statsdMaxPacket)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		for _, line := range strings.Split(string(buf[:n]), "\n") {
+			if line = strings.TrimSpace(line); line == "" {
+				continue
+			}
+			if err := r.addStatsD(line); err != nil {
+				log.Printf("statsd: %q: %v", line, err)
+			}
+		}
+	}
+}
+
+func (r *Receiver) addStatsD(line string) error {
+	m, relative, err := parseStatsD(line)
+	if err != nil {
+		return err
+	}
+	return r.add(m, relative)
+}
+
+// parseStatsD parses a StatsD line. relative is set for gauges with an
+// explicit sign, whose value is a change of the current one.
+func parseStatsD(line string) (m models.Metrics, relative bool, err error) {
+	name, rest, ok := strings.Cut(line, ":")
+	if !ok || name == "" {
+		return m, false, fmt.Errorf("%w: missing name", ErrInvalidMetric)
+	}
+	fields := strings.Split(rest, "|")
+	if len(fields) < 2 {
+		return m, false, fmt.Errorf("%w: missing type", ErrInvalidMetric)
+	}
+
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return m, false, fmt.Errorf("%w: value %q", ErrInvalidMetric, fields[0])
+	}
+
+	rate := 1.0
+	for _, f := range fields[2:] {
+		if s, ok := strings.CutPrefix(f, "@"); ok {
+			rate, err = strconv.ParseFloat(s, 64)
+			if err != nil || rate <= 0 || rate > 1 {
+				return m, false, fmt.Errorf("%w: sample rate %q", ErrInvalidMetric, s)
+			}
+		}
+	}
+
+	m.ID = name
+	switch fields[1] {
+	case "c":
+		d := int64(math.Round(value / rate))
+		m.MType, m.Delta = models.Counter, &d
+	case "g":
+		relative = fields[0][0] == '+' || fields[0][0] == '-'
+		m.MType, m.Value = models.Gauge, &value
+	case "ms", "h", "d":
+		m.MType, m.Value = models.Gauge, &value
+	default:
+		return m, false, fmt.Errorf("%w: unsupported type %q", ErrInvalidMetric, fields[1])
+	}
+	return m, relative, nil
+}
@@ -0,0 +1,98 @@
+package agent
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sbilibin2017/gophmetrics/internal/models"
+)
+
+func TestParseStatsD(t *testing.T) {
+	ptrInt64 := func(v int64) *int64 { return &v }
+	ptrFloat64 := func(v float64) *float64 { return &v }
+
+	tests := []struct {
+		line     string
+		want     models.Metrics
+		relative bool
+	}{
+		{"hits:3|c", models.Metrics{ID: "hits", MType: models.Counter, Delta: ptrInt64(3)}, false},
+		{"hits:1|c|@0.1|#env:prod", models.Metrics{ID: "hits", MType: models.Counter, Delta: ptrInt64(10)}, false},
+		{"temp:21.5|g", models.Metrics{ID: "temp", MType: models.Gauge, Value: ptrFloat64(21.5)}, false},
+		{"temp:-2|g", models.Metrics{ID: "temp", MType: models.Gauge, Value: ptrFloat64(-2)}, true},
+		{"latency:320|ms", models.Metrics{ID: "latency", MType: models.Gauge, Value: ptrFloat64(320)}, false},
+	}
+	for _, tt := range tests {
+		m, relative, err := parseStatsD(tt.line)
+		require.NoError(t, err, tt.line)
+		assert.Equal(t, tt.want, m, tt.line)
+		assert.Equal(t, tt.relative, relative, tt.line)
+	}
+
+	for _, line := range []string{"hits", ":1|c", "hits:1", "hits:x|c", "hits:NaN|g", "users:42|s", "hits:1|c|@2"} {
+		_, _, err := parseStatsD(line)
+		assert.ErrorIs(t, err, ErrInvalidMetric, line)
+	}
+}
+
+func TestReceiver_ServeStatsD(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	r := NewReceiver()
+	done := make(chan error, 1)
+	go func() { done <- r.ServeStatsD(ctx, conn) }()
+
+	client, err := net.Dial("udp", conn.LocalAddr().String())
+	require.NoError(t, err)
+	defer client.Close()
+	_, err = client.Write([]byte("hits:2|c\nhits:3|c\ntemp:20|g\nbad line\ntemp:+1.5|g"))
+	require.NoError(t, err)
+
+	var metrics []models.Metrics
+	require.Eventually(t, func() bool {
+		m, _ := r.Collect(ctx)
+		metrics = append(metrics, m...)
+		return len(metrics) == 2
+	}, time.Second, 10*time.Millisecond)
+
+	byID := metricsByID(metrics)
+	assert.Equal(t, int64(5), *byID["hits"].Delta)
+	assert.Equal(t, 21.5, *byID["temp"].Value)
+
+	cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("ServeStatsD did not stop")
+	}
+}
+
+func TestReceiver_RelativeGaugesConcurrent(t *testing.T) {
+	r := NewReceiver()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, r.addStatsD("queue:+1|g"))
+		}()
+	}
+	wg.Wait()
+
+	metrics, err := r.Collect(context.Background())
+	require.NoError(t, err)
+	require.Len(t, metrics, 1)
+	assert.Equal(t, 50.0, *metrics[0].Value, "no adjustment is lost")
+}
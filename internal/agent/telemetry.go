@@ -0,0 +1,177 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sbilibin2017/gophmetrics/internal/models"
+)
+
+// TelemetryCollectorName is the name of the collector reporting the agent's own metrics.
+const TelemetryCollectorName = "telemetry"
+
+// Telemetry counts what the agent itself does: batches sent, failed and
+// rejected by the circuit breaker, metrics sent and dropped, send latency,
+// collector errors, and the state of the spool and the breaker.
+//
+// As a collector it reports counters carrying the increase since the previous
+// poll and gauges, e.g. agent_batches_sent_total and
+// agent_send_duration_seconds, the mean latency of the sends since the
+// previous poll. As an http.Handler it serves the cumulative values in the
+// Prometheus text format.
+type Telemetry struct {
+	batchesSent     atomic.Int64
+	batchesFailed   atomic.Int64
+	batchesRejected atomic.Int64
+	metricsSent     atomic.Int64
+	metricsDropped  atomic.Int64
+	collectErrors   atomic.Int64
+	sendNanos       atomic.Int64
+	sends           atomic.Int64
+
+	mu      sync.Mutex
+	spool   Spool
+	breaker interface{ State() BreakerState }
+	last    map[string]int64 // cumulative values at the previous poll
+}
+
+// telemetryCounter is a cumulative counter reported by Telemetry.
+type telemetryCounter struct {
+	name  string
+	value *atomic.Int64
+}
+
+// NewTelemetry creates empty agent telemetry.
+func NewTelemetry() *Telemetry {
+	return &Telemetry{last: make(map[string]int64)}
+}
+
+func (t *Telemetry) Name() string { return TelemetryCollectorName }
+
+func (t *Telemetry) counters() []telemetryCounter {
+	return []telemetryCounter{
+		{"agent_batches_sent_total", &t.batchesSent},
+		{"agent_batches_failed_total", &t.batchesFailed},
+		{"agent_batches_rejected_total", &t.batchesRejected},
+		{"agent_metrics_sent_total", &t.metricsSent},
+		{"agent_metrics_dropped_total", &t.metricsDropped},
+		{"agent_collect_errors_total", &t.collectErrors},
+	}
+}
+
+// Collect returns the increase of the counters since the previous poll and
+// the current gauges.
+func (t *Telemetry) Collect(ctx context.Context) ([]models.Metrics, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var metrics []models.Metrics
+	delta := func(name string, cur int64) int64 {
+		d := cur - t.last[name]
+		t.last[name] = cur
+		return d
+	}
+	for _, c := range t.counters() {
+		d := delta(c.name, c.value.Load())
+		metrics = append(metrics, models.Metrics{ID: c.name, MType: models.Counter, Delta: &d})
+	}
+
+	nanos := delta("send_nanos", t.sendNanos.Load())
+	if sends := delta("sends", t.sends.Load()); sends > 0 {
+		metrics = append(metrics, gauge("agent_send_duration_seconds", time.Duration(nanos/sends).Seconds()))
+	}
+
+	if t.spool != nil {
+		d := delta("agent_spool_dropped_batches_total", t.spool.Dropped())
+		metrics = append(metrics,
+			gauge("agent_spool_batches", float64(t.spool.Len())),
+			models.Metrics{ID: "agent_spool_dropped_batches_total", MType: models.Counter, Delta: &d},
+		)
+	}
+	if t.breaker != nil {
+		metrics = append(metrics, gauge("agent_breaker_state", float64(t.breaker.State())))
+	}
+	return metrics, nil
+}
+
+// ServeHTTP writes the cumulative telemetry in the Prometheus text format.
+func (t *Telemetry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	for _, c := range t.counters() {
+		fmt.Fprintf(w, "# TYPE %s counter\n%s %d\n", c.name, c.name, c.value.Load())
+	}
+	fmt.Fprintf(w, "# TYPE agent_send_duration_seconds summary\n")
+	fmt.Fprintf(w, "agent_send_duration_seconds_sum %g\n", time.Duration(t.sendNanos.Load()).Seconds())
+	fmt.Fprintf(w, "agent_send_duration_seconds_count %d\n", t.sends.Load())
+
+	t.mu.Lock()
+	spool, breaker := t.spool, t.breaker
+	t.mu.Unlock()
+	if spool != nil {
+		fmt.Fprintf(w, "# TYPE agent_spool_batches gauge\nagent_spool_batches %d\n", spool.Len())
+		fmt.Fprintf(w, "# TYPE agent_spool_dropped_batches_total counter\nagent_spool_dropped_batches_total %d\n", spool.Dropped())
+	}
+	if breaker != nil {
+		fmt.Fprintf(w, "# HELP agent_breaker_state Circuit breaker state: 0 closed, 1 open, 2 half-open.\n")
+		fmt.Fprintf(w, "# TYPE agent_breaker_state gauge\nagent_breaker_state %d\n", breaker.State())
+	}
+}
+
+// observe makes the telemetry report the state of the spool and, when
+// updater is a circuit breaker, of the breaker.
+func (t *Telemetry) observe(spool Spool, updater Updater) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.spool = spool
+	if b, ok := updater.(interface{ State() BreakerState }); ok {
+		t.breaker = b
+	}
+}
+
+// metricsDroppedBy counts metrics lost with a batch that could not be sent or
+// spooled, or that the server refused.
+func (t *Telemetry) metricsDroppedBy(n int) {
+	if t != nil {
+		t.metricsDropped.Add(int64(n))
+	}
+}
+
+// collectFailed counts a failed collection.
+func (t *Telemetry) collectFailed() {
+	if t != nil {
+		t.collectErrors.Add(1)
+	}
+}
+
+// telemetryUpdater counts the updates passing through it.
+type telemetryUpdater struct {
+	Updater
+	telemetry *Telemetry
+}
+
+func (u telemetryUpdater) Update(ctx context.Context, metrics []*models.Metrics) error {
+	start := time.Now()
+	err := u.Updater.Update(ctx, metrics)
+
+	t := u.telemetry
+	switch {
+	case errors.Is(err, ErrCircuitOpen):
+		t.batchesRejected.Add(1)
+		return err
+	case err != nil:
+		t.batchesFailed.Add(1)
+	default:
+		t.batchesSent.Add(1)
+		t.metricsSent.Add(int64(len(metrics)))
+	}
+	t.sendNanos.Add(int64(time.Since(start)))
+	t.sends.Add(1)
+	return err
+}
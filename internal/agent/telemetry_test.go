@@ -0,0 +1,101 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sbilibin2017/gophmetrics/internal/queue"
+)
+
+func TestTelemetry_Collect(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUpdater := NewMockUpdater(ctrl)
+	b := NewBreaker(mockUpdater, WithFailureThreshold(1), WithOpenTimeout(time.Hour))
+	spool, err := queue.New(t.TempDir(), 10)
+	require.NoError(t, err)
+
+	tel := NewTelemetry()
+	tel.observe(spool, b)
+	u := telemetryUpdater{Updater: b, telemetry: tel}
+	ctx := context.Background()
+
+	mockUpdater.EXPECT().Update(ctx, gomock.Any()).Return(nil)
+	mockUpdater.EXPECT().Update(ctx, gomock.Any()).Return(errors.New("server down"))
+	require.NoError(t, u.Update(ctx, append(counterBatch("a"), counterBatch("b")...)))
+	require.Error(t, u.Update(ctx, counterBatch("c")))
+	require.ErrorIs(t, u.Update(ctx, counterBatch("d")), ErrCircuitOpen)
+	require.NoError(t, spool.Push(counterBatch("d")))
+	tel.metricsDroppedBy(3)
+	tel.collectFailed()
+
+	metrics, err := tel.Collect(ctx)
+	require.NoError(t, err)
+	byID := metricsByID(metrics)
+
+	for id, want := range map[string]int64{
+		"agent_batches_sent_total":          1,
+		"agent_batches_failed_total":        1,
+		"agent_batches_rejected_total":      1,
+		"agent_metrics_sent_total":          2,
+		"agent_metrics_dropped_total":       3,
+		"agent_collect_errors_total":        1,
+		"agent_spool_dropped_batches_total": 0,
+	} {
+		if assert.Contains(t, byID, id) {
+			assert.Equal(t, want, *byID[id].Delta, id)
+		}
+	}
+	assert.Contains(t, byID, "agent_send_duration_seconds")
+	assert.Equal(t, 1.0, *byID["agent_spool_batches"].Value)
+	assert.Equal(t, float64(BreakerOpen), *byID["agent_breaker_state"].Value)
+
+	// Counters report the increase since the previous poll.
+	metrics, err = tel.Collect(ctx)
+	require.NoError(t, err)
+	byID = metricsByID(metrics)
+	assert.Equal(t, int64(0), *byID["agent_batches_sent_total"].Delta)
+	assert.NotContains(t, byID, "agent_send_duration_seconds", "no sends since the previous poll")
+}
+
+func TestTelemetry_ServeHTTP(t *testing.T) {
+	tel := NewTelemetry()
+	tel.batchesSent.Add(3)
+	tel.sends.Add(3)
+	tel.sendNanos.Add(int64(1500 * time.Millisecond))
+
+	rec := httptest.NewRecorder()
+	tel.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	body := rec.Body.String()
+	assert.Contains(t, body, "# TYPE agent_batches_sent_total counter\nagent_batches_sent_total 3\n")
+	assert.Contains(t, body, "agent_send_duration_seconds_sum 1.5\n")
+	assert.Contains(t, body, "agent_send_duration_seconds_count 3\n")
+	assert.NotContains(t, body, "agent_spool_batches")
+}
+
+func TestSender_CountsDroppedMetrics(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUpdater := NewMockUpdater(ctrl)
+	mockUpdater.EXPECT().Update(gomock.Any(), gomock.Any()).Return(errors.New("server down"))
+
+	reportTicker := time.NewTicker(time.Hour)
+	defer reportTicker.Stop()
+
+	tel := NewTelemetry()
+	err := sender(context.Background(), reportTicker, mockUpdater, sendOne("a"), 1, options{telemetry: tel})
+	assert.Error(t, err)
+	assert.Equal(t, int64(1), tel.metricsDropped.Load())
+}
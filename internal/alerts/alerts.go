@@ -0,0 +1,341 @@
+package alerts
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sbilibin2017/gophmetrics/internal/models"
+)
+
+// Lister lists all metrics.
+type Lister interface {
+	List(ctx context.Context) ([]*models.Metrics, error)
+}
+
+// Notifier delivers alert state changes to a webhook URL.
+type Notifier interface {
+	Notify(ctx context.Context, url string, alert models.Alert) error
+}
+
+// Supported comparison operators.
+const (
+	OpLess         = "<"
+	OpLessEqual    = "<="
+	OpGreater      = ">"
+	OpGreaterEqual = ">="
+	OpEqual        = "=="
+	OpNotEqual     = "!="
+)
+
+// ErrInvalidRule is returned when a rule in the rules file cannot be used.
+var ErrInvalidRule = errors.New("invalid alert rule")
+
+// Duration is a time.Duration that is read from JSON as a string like "2m".
+type Duration time.Duration
+
+// UnmarshalJSON parses a duration string such as "90s" or "2m".
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Rule describes a threshold on a single metric.
+//
+// A rule may be given either with separate fields or with a compact
+// expression such as "FreeMemory < 1e9 for 2m".
+type Rule struct {
+	Name      string   `json:"name"`
+	Expr      string   `json:"expr,omitempty"`
+	Metric    string   `json:"metric,omitempty"`
+	MType     string   `json:"type,omitempty"`
+	Op        string   `json:"op,omitempty"`
+	Threshold float64  `json:"threshold,omitempty"`
+	For       Duration `json:"for,omitempty"`
+	Webhooks  []string `json:"webhooks,omitempty"`
+}
+
+// Condition returns a human-readable representation of the rule condition.
+func (r Rule) Condition() string {
+	cond := fmt.Sprintf("%s %s %s", r.Metric, r.Op, strconv.FormatFloat(r.Threshold, 'g', -1, 64))
+	if r.For > 0 {
+		cond += " for " + time.Duration(r.For).String()
+	}
+	return cond
+}
+
+// Config is the content of an alert rules file.
+type Config struct {
+	// Webhooks receive notifications for every rule.
+	Webhooks []string `json:"webhooks,omitempty"`
+	// Rules is the list of alerting rules.
+	Rules []Rule `json:"rules"`
+}
+
+// LoadConfig reads and validates an alert rules JSON file.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading alert rules file: %w", err)
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("error parsing alert rules JSON: %w", err)
+	}
+
+	names := make(map[string]struct{}, len(cfg.Rules))
+	for i := range cfg.Rules {
+		if err := normalizeRule(&cfg.Rules[i]); err != nil {
+			return nil, err
+		}
+		if _, ok := names[cfg.Rules[i].Name]; ok {
+			return nil, fmt.Errorf("%w: duplicate rule name %q", ErrInvalidRule, cfg.Rules[i].Name)
+		}
+		names[cfg.Rules[i].Name] = struct{}{}
+	}
+
+	return &cfg, nil
+}
+
+// normalizeRule parses the rule expression if present, applies defaults and validates the rule.
+func normalizeRule(r *Rule) error {
+	if r.Expr != "" {
+		if err := parseExpr(r); err != nil {
+			return err
+		}
+	}
+	if r.MType == "" {
+		r.MType = models.Gauge
+	}
+	if r.Name == "" {
+		r.Name = r.Metric
+	}
+
+	switch {
+	case strings.TrimSpace(r.Metric) == "":
+		return fmt.Errorf("%w: metric is required", ErrInvalidRule)
+	case r.MType != models.Gauge && r.MType != models.Counter:
+		return fmt.Errorf("%w %q: invalid metric type %q", ErrInvalidRule, r.Name, r.MType)
+	case !validOp(r.Op):
+		return fmt.Errorf("%w %q: invalid operator %q", ErrInvalidRule, r.Name, r.Op)
+	case r.For < 0:
+		return fmt.Errorf("%w %q: negative duration", ErrInvalidRule, r.Name)
+	}
+	return nil
+}
+
+// parseExpr parses an expression of the form "<metric> <op> <threshold> [for <duration>]".
+func parseExpr(r *Rule) error {
+	fields := strings.Fields(r.Expr)
+	if len(fields) != 3 && len(fields) != 5 {
+		return fmt.Errorf("%w: cannot parse expression %q", ErrInvalidRule, r.Expr)
+	}
+
+	threshold, err := strconv.ParseFloat(fields[2], 64)
+	if err != nil {
+		return fmt.Errorf("%w: invalid threshold in %q", ErrInvalidRule, r.Expr)
+	}
+
+	if len(fields) == 5 {
+		if fields[3] != "for" {
+			return fmt.Errorf("%w: cannot parse expression %q", ErrInvalidRule, r.Expr)
+		}
+		d, err := time.ParseDuration(fields[4])
+		if err != nil {
+			return fmt.Errorf("%w: invalid duration in %q", ErrInvalidRule, r.Expr)
+		}
+		r.For = Duration(d)
+	}
+
+	r.Metric = fields[0]
+	r.Op = fields[1]
+	r.Threshold = threshold
+	return nil
+}
+
+func validOp(op string) bool {
+	switch op {
+	case OpLess, OpLessEqual, OpGreater, OpGreaterEqual, OpEqual, OpNotEqual:
+		return true
+	}
+	return false
+}
+
+// matches reports whether value satisfies the rule condition.
+func (r Rule) matches(value float64) bool {
+	switch r.Op {
+	case OpLess:
+		return value < r.Threshold
+	case OpLessEqual:
+		return value <= r.Threshold
+	case OpGreater:
+		return value > r.Threshold
+	case OpGreaterEqual:
+		return value >= r.Threshold
+	case OpEqual:
+		return value == r.Threshold
+	case OpNotEqual:
+		return value != r.Threshold
+	}
+	return false
+}
+
+// Engine evaluates alerting rules against stored metrics and tracks alert state.
+type Engine struct {
+	rules    []Rule
+	webhooks []string
+	lister   Lister
+	notifier Notifier
+	now      func() time.Time
+
+	mu     sync.RWMutex
+	alerts map[string]*models.Alert
+}
+
+// NewEngine creates a new Engine for the rules in cfg.
+func NewEngine(cfg *Config, lister Lister, notifier Notifier) *Engine {
+	e := &Engine{
+		rules:    cfg.Rules,
+		webhooks: cfg.Webhooks,
+		lister:   lister,
+		notifier: notifier,
+		now:      time.Now,
+		alerts:   make(map[string]*models.Alert, len(cfg.Rules)),
+	}
+	for _, r := range cfg.Rules {
+		e.alerts[r.Name] = &models.Alert{
+			Name:      r.Name,
+			State:     models.AlertInactive,
+			Condition: r.Condition(),
+		}
+	}
+	return e
+}
+
+// Run evaluates the rules on every tick until the context is cancelled.
+// Evaluation errors are logged and do not stop the engine.
+func (e *Engine) Run(ctx context.Context, ticker *time.Ticker) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := e.Evaluate(ctx); err != nil {
+				log.Printf("alerts: %v", err)
+			}
+		}
+	}
+}
+
+// Evaluate checks every rule once, updates alert states and sends
+// notifications for alerts that started firing or were resolved.
+func (e *Engine) Evaluate(ctx context.Context) error {
+	metrics, err := e.lister.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	values := make(map[models.MetricID]float64, len(metrics))
+	for _, m := range metrics {
+		id := models.MetricID{ID: m.ID, MType: m.MType}
+		switch {
+		case m.MType == models.Gauge && m.Value != nil:
+			values[id] = *m.Value
+		case m.MType == models.Counter && m.Delta != nil:
+			values[id] = float64(*m.Delta)
+		}
+	}
+
+	now := e.now()
+	var notifications []notification
+
+	e.mu.Lock()
+	for _, r := range e.rules {
+		a := e.alerts[r.Name]
+		a.EvaluatedAt = now
+
+		value, ok := values[models.MetricID{ID: r.Metric, MType: r.MType}]
+		if ok {
+			a.Value = &value
+		} else {
+			a.Value = nil
+		}
+
+		if ok && r.matches(value) {
+			if a.ActiveSince == nil {
+				a.ActiveSince = &now
+				a.State = models.AlertPending
+			}
+			if a.State == models.AlertPending && now.Sub(*a.ActiveSince) >= time.Duration(r.For) {
+				a.State = models.AlertFiring
+				a.FiredAt = &now
+				a.ResolvedAt = nil
+				notifications = append(notifications, notification{rule: r, alert: *a})
+			}
+			continue
+		}
+
+		a.ActiveSince = nil
+		switch a.State {
+		case models.AlertFiring:
+			a.State = models.AlertResolved
+			a.ResolvedAt = &now
+			notifications = append(notifications, notification{rule: r, alert: *a})
+		case models.AlertPending:
+			a.State = models.AlertInactive
+		}
+	}
+	e.mu.Unlock()
+
+	var errs []error
+	for _, n := range notifications {
+		for _, url := range append(append([]string{}, e.webhooks...), n.rule.Webhooks...) {
+			if err := e.notifier.Notify(ctx, url, n.alert); err != nil {
+				errs = append(errs, fmt.Errorf("notify %s about %q: %w", url, n.alert.Name, err))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// notification is an alert state change waiting to be delivered.
+type notification struct {
+	rule  Rule
+	alert models.Alert
+}
+
+// List returns the current state of all alerts sorted by name.
+func (e *Engine) List(ctx context.Context) ([]*models.Alert, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	result := make([]*models.Alert, 0, len(e.alerts))
+	for _, a := range e.alerts {
+		alert := *a
+		result = append(result, &alert)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+
+	return result, nil
+}
@@ -0,0 +1,219 @@
+package alerts
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/sbilibin2017/gophmetrics/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeRulesFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "alerts.json")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []Rule
+		wantErr bool
+	}{
+		{
+			name:    "expression rule",
+			content: `{"rules":[{"name":"LowMemory","expr":"FreeMemory < 1e9 for 2m"}]}`,
+			want: []Rule{{
+				Name:      "LowMemory",
+				Expr:      "FreeMemory < 1e9 for 2m",
+				Metric:    "FreeMemory",
+				MType:     models.Gauge,
+				Op:        OpLess,
+				Threshold: 1e9,
+				For:       Duration(2 * time.Minute),
+			}},
+		},
+		{
+			name:    "structured rule with defaults",
+			content: `{"rules":[{"metric":"PollCount","type":"counter","op":">=","threshold":10}]}`,
+			want: []Rule{{
+				Name:      "PollCount",
+				Metric:    "PollCount",
+				MType:     models.Counter,
+				Op:        OpGreaterEqual,
+				Threshold: 10,
+			}},
+		},
+		{
+			name:    "invalid operator",
+			content: `{"rules":[{"metric":"Alloc","op":"~","threshold":1}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid expression",
+			content: `{"rules":[{"expr":"Alloc > lots"}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "duplicate names",
+			content: `{"rules":[{"expr":"Alloc > 1"},{"expr":"Alloc < 0"}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "malformed JSON",
+			content: `{"rules":`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := LoadConfig(writeRulesFile(t, tt.content))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, cfg.Rules)
+		})
+	}
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := LoadConfig(filepath.Join(t.TempDir(), "missing.json"))
+		assert.Error(t, err)
+	})
+}
+
+func TestEngine_Evaluate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockLister := NewMockLister(ctrl)
+	mockNotifier := NewMockNotifier(ctrl)
+
+	cfg := &Config{
+		Webhooks: []string{"http://global"},
+		Rules: []Rule{{
+			Name:      "LowMemory",
+			Metric:    "FreeMemory",
+			MType:     models.Gauge,
+			Op:        OpLess,
+			Threshold: 100,
+			For:       Duration(2 * time.Minute),
+			Webhooks:  []string{"http://rule"},
+		}},
+	}
+
+	engine := NewEngine(cfg, mockLister, mockNotifier)
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	engine.now = func() time.Time { return now }
+
+	gauge := func(v float64) []*models.Metrics {
+		return []*models.Metrics{{ID: "FreeMemory", MType: models.Gauge, Value: &v}}
+	}
+	state := func() string {
+		list, err := engine.List(context.Background())
+		require.NoError(t, err)
+		require.Len(t, list, 1)
+		return list[0].State
+	}
+
+	ctx := context.Background()
+
+	// Condition matches: alert becomes pending without notifications.
+	mockLister.EXPECT().List(ctx).Return(gauge(50), nil)
+	require.NoError(t, engine.Evaluate(ctx))
+	assert.Equal(t, models.AlertPending, state())
+
+	// Condition held for the required duration: alert fires and notifies both webhooks.
+	now = now.Add(2 * time.Minute)
+	mockLister.EXPECT().List(ctx).Return(gauge(40), nil)
+	mockNotifier.EXPECT().Notify(ctx, "http://global", gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ string, a models.Alert) error {
+			assert.Equal(t, models.AlertFiring, a.State)
+			return nil
+		})
+	mockNotifier.EXPECT().Notify(ctx, "http://rule", gomock.Any()).Return(nil)
+	require.NoError(t, engine.Evaluate(ctx))
+	assert.Equal(t, models.AlertFiring, state())
+
+	// Still firing: no repeated notifications.
+	now = now.Add(time.Minute)
+	mockLister.EXPECT().List(ctx).Return(gauge(30), nil)
+	require.NoError(t, engine.Evaluate(ctx))
+	assert.Equal(t, models.AlertFiring, state())
+
+	// Condition cleared: alert resolves, notification errors are reported.
+	now = now.Add(time.Minute)
+	mockLister.EXPECT().List(ctx).Return(gauge(500), nil)
+	mockNotifier.EXPECT().Notify(ctx, "http://global", gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ string, a models.Alert) error {
+			assert.Equal(t, models.AlertResolved, a.State)
+			return nil
+		})
+	mockNotifier.EXPECT().Notify(ctx, "http://rule", gomock.Any()).Return(errors.New("unreachable"))
+	assert.Error(t, engine.Evaluate(ctx))
+	assert.Equal(t, models.AlertResolved, state())
+
+	// Missing metric does not match the condition.
+	mockLister.EXPECT().List(ctx).Return(nil, nil)
+	require.NoError(t, engine.Evaluate(ctx))
+	assert.Equal(t, models.AlertResolved, state())
+
+	// Lister errors are returned.
+	mockLister.EXPECT().List(ctx).Return(nil, errors.New("db down"))
+	assert.Error(t, engine.Evaluate(ctx))
+}
+
+func TestEngine_PendingResetsToInactive(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockLister := NewMockLister(ctrl)
+	mockNotifier := NewMockNotifier(ctrl)
+
+	delta := int64(20)
+	cfg := &Config{Rules: []Rule{{
+		Name: "TooManyPolls", Metric: "PollCount", MType: models.Counter,
+		Op: OpGreater, Threshold: 10, For: Duration(time.Hour),
+	}}}
+	engine := NewEngine(cfg, mockLister, mockNotifier)
+
+	ctx := context.Background()
+	mockLister.EXPECT().List(ctx).Return([]*models.Metrics{{ID: "PollCount", MType: models.Counter, Delta: &delta}}, nil)
+	require.NoError(t, engine.Evaluate(ctx))
+
+	mockLister.EXPECT().List(ctx).Return(nil, nil)
+	require.NoError(t, engine.Evaluate(ctx))
+
+	list, err := engine.List(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, models.AlertInactive, list[0].State)
+	assert.Nil(t, list[0].ActiveSince)
+}
+
+func TestEngine_Run(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockLister := NewMockLister(ctrl)
+	mockLister.EXPECT().List(gomock.Any()).Return(nil, errors.New("fail")).AnyTimes()
+
+	engine := NewEngine(&Config{Rules: []Rule{{Name: "r", Metric: "m", MType: models.Gauge, Op: OpLess}}}, mockLister, NewMockNotifier(ctrl))
+
+	ticker := time.NewTicker(5 * time.Millisecond)
+	defer ticker.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+
+	assert.NoError(t, engine.Run(ctx, ticker))
+}
@@ -0,0 +1,156 @@
+// Package auth checks the bearer tokens of server clients.
+//
+// Tokens are configured by their SHA-256 hash and scopes, so the config file
+// does not hold usable secrets:
+//
+//	9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08:read
+//	60303ae22b998861bce3b28f33eec1be758a213c86c93c076dbe9f558c11c752:read+write
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Scope is a permission granted to a token.
+type Scope string
+
+const (
+	// ScopeRead allows reading metrics, agents and alerts.
+	ScopeRead Scope = "read"
+	// ScopeWrite allows updating metrics.
+	ScopeWrite Scope = "write"
+	// ScopeAdmin allows everything.
+	ScopeAdmin Scope = "admin"
+)
+
+var (
+	// ErrUnauthenticated is returned for a missing or unknown token.
+	ErrUnauthenticated = errors.New("missing or unknown token")
+	// ErrForbidden is returned for a token without the required scope.
+	ErrForbidden = errors.New("token lacks the required scope")
+)
+
+// Tokens holds the configured tokens by hash. It can be replaced while the
+// server runs.
+type Tokens struct {
+	mu     sync.RWMutex
+	scopes map[string][]Scope
+}
+
+// NewTokens creates the tokens from entries of the form HASH:SCOPE[+SCOPE...],
+// where HASH is the hex-encoded SHA-256 of the token.
+// With no entries, authentication is disabled.
+func NewTokens(entries []string) (*Tokens, error) {
+	t := &Tokens{}
+	if err := t.Set(entries); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Set replaces the tokens with entries. On error the tokens are unchanged.
+func (t *Tokens) Set(entries []string) error {
+	scopes := make(map[string][]Scope, len(entries))
+	var errs []error
+	for _, entry := range entries {
+		hash, s, err := parseEntry(entry)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		scopes[hash] = s
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.scopes = scopes
+	return nil
+}
+
+// parseEntry parses a HASH:SCOPE[+SCOPE...] entry.
+func parseEntry(entry string) (string, []Scope, error) {
+	hash, list, ok := strings.Cut(strings.TrimSpace(entry), ":")
+	hash = strings.ToLower(hash)
+	if b, err := hex.DecodeString(hash); !ok || err != nil || len(b) != sha256.Size {
+		return "", nil, fmt.Errorf("invalid token %q, must be SHA256-HEX:SCOPES", shorten(entry))
+	}
+
+	var scopes []Scope
+	for _, s := range strings.Split(list, "+") {
+		switch scope := Scope(strings.TrimSpace(s)); scope {
+		case ScopeRead, ScopeWrite, ScopeAdmin:
+			scopes = append(scopes, scope)
+		default:
+			return "", nil, fmt.Errorf("invalid scope %q of token %s, must be read, write or admin", s, shorten(hash))
+		}
+	}
+	return hash, scopes, nil
+}
+
+// shorten returns the start of s, enough to find it in the config file.
+func shorten(s string) string {
+	if len(s) > 12 {
+		return s[:12] + "..."
+	}
+	return s
+}
+
+// Authorize checks that token grants scope and returns the identity of the
+// token for per-client limits. The admin scope grants all others. If no
+// tokens are configured, every request is authorized and the identity is "".
+func (t *Tokens) Authorize(token string, scope Scope) (string, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if len(t.scopes) == 0 {
+		return "", nil
+	}
+	if token == "" {
+		return "", ErrUnauthenticated
+	}
+	hash := HashToken(token)
+	granted, ok := t.scopes[hash]
+	if !ok {
+		return "", ErrUnauthenticated
+	}
+	for _, s := range granted {
+		if s == scope || s == ScopeAdmin {
+			return "token:" + hash[:16], nil
+		}
+	}
+	return "", ErrForbidden
+}
+
+// BearerToken returns the token of an Authorization header or metadata value
+// of the form "Bearer TOKEN", or "" for any other value.
+func BearerToken(value string) string {
+	scheme, token, ok := strings.Cut(value, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// HashToken returns the hex-encoded SHA-256 of token, as it is configured.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateToken returns a new random token.
+func GenerateToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
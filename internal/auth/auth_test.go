@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokens_Authorize(t *testing.T) {
+	tokens, err := NewTokens([]string{
+		HashToken("reader") + ":read",
+		HashToken("writer") + ":write",
+		HashToken("both") + ":read+write",
+		HashToken("admin") + ":admin",
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		token string
+		scope Scope
+		want  error
+	}{
+		{"reader", ScopeRead, nil},
+		{"reader", ScopeWrite, ErrForbidden},
+		{"writer", ScopeWrite, nil},
+		{"writer", ScopeRead, ErrForbidden},
+		{"both", ScopeRead, nil},
+		{"both", ScopeWrite, nil},
+		{"both", ScopeAdmin, ErrForbidden},
+		{"admin", ScopeRead, nil},
+		{"admin", ScopeWrite, nil},
+		{"admin", ScopeAdmin, nil},
+		{"unknown", ScopeRead, ErrUnauthenticated},
+		{"", ScopeRead, ErrUnauthenticated},
+	}
+	for _, tt := range tests {
+		client, err := tokens.Authorize(tt.token, tt.scope)
+		assert.Equal(t, tt.want, err, "%s/%s", tt.token, tt.scope)
+		if err == nil {
+			assert.Equal(t, "token:"+HashToken(tt.token)[:16], client)
+		}
+	}
+}
+
+func TestTokens_Disabled(t *testing.T) {
+	tokens, err := NewTokens(nil)
+	require.NoError(t, err)
+	client, err := tokens.Authorize("any", ScopeAdmin)
+	assert.NoError(t, err)
+	assert.Empty(t, client, "tokens are not identities without authentication")
+
+	require.NoError(t, tokens.Set([]string{HashToken("t") + ":read"}))
+	_, err = tokens.Authorize("", ScopeRead)
+	assert.ErrorIs(t, err, ErrUnauthenticated)
+}
+
+func TestTokens_SetInvalid(t *testing.T) {
+	tokens, err := NewTokens([]string{HashToken("t") + ":read"})
+	require.NoError(t, err)
+
+	err = tokens.Set([]string{"abc:read", HashToken("u") + ":delete", HashToken("v")})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid token "abc:read"`)
+	assert.Contains(t, err.Error(), `invalid scope "delete"`)
+	assert.Contains(t, err.Error(), "must be SHA256-HEX:SCOPES")
+
+	_, err = tokens.Authorize("t", ScopeRead)
+	assert.NoError(t, err, "tokens unchanged on error")
+}
+
+func TestBearerToken(t *testing.T) {
+	assert.Equal(t, "abc", BearerToken("Bearer abc"))
+	assert.Equal(t, "abc", BearerToken("bearer  abc "), "scheme is case-insensitive")
+	assert.Empty(t, BearerToken("Basic abc"))
+	assert.Empty(t, BearerToken("abc"))
+	assert.Empty(t, BearerToken(""))
+}
+
+func TestHashToken(t *testing.T) {
+	assert.Equal(t, "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08", HashToken("test"))
+
+	tokens, err := NewTokens([]string{"9F86D081884C7D659A2FEAA0C55AD015A3BF4F1B2B0B822CD15D6C15B0F00A08:read"})
+	require.NoError(t, err)
+	_, err = tokens.Authorize("test", ScopeRead)
+	assert.NoError(t, err, "hash is case-insensitive")
+}
+
+func TestGenerateToken(t *testing.T) {
+	a, err := GenerateToken()
+	require.NoError(t, err)
+	b, err := GenerateToken()
+	require.NoError(t, err)
+
+	assert.Len(t, a, 64)
+	assert.NotEqual(t, a, b)
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sbilibin2017/gophmetrics/internal/configs/address"
+)
+
+// Agent is the configuration of the agent.
+type Agent struct {
+	Address          []string      `json:"address" env:"ADDRESS" flag:"address,a" usage:"server URL, or comma-separated URLs of several servers"`
+	AddressPolicy    string        `json:"address_policy" env:"ADDRESS_POLICY" flag:"address-policy" usage:"how batches are sent to several servers: failover or replicate"`
+	BreakerThreshold int           `json:"breaker_threshold" env:"BREAKER_THRESHOLD" flag:"breaker-threshold" usage:"consecutive failed requests after which sending pauses (0 = no circuit breaker)"`
+	BreakerTimeout   time.Duration `json:"breaker_timeout" env:"BREAKER_TIMEOUT" flag:"breaker-timeout" usage:"time sending stays paused before a trial request"`
+	PollInterval     time.Duration `json:"poll_interval" env:"POLL_INTERVAL" flag:"poll-interval,p" usage:"poll interval, e.g. 2s (a plain number is seconds)"`
+	ReportInterval   time.Duration `json:"report_interval" env:"REPORT_INTERVAL" flag:"report-interval,r" usage:"report interval, e.g. 10s (a plain number is seconds)"`
+	Key              string        `json:"key" section:"security" env:"KEY" flag:"key,k" config:"secret" usage:"key for SHA256 hashing"`
+	KeyID            string        `json:"key_id" section:"security" env:"KEY_ID" flag:"key-id" usage:"ID of the key in the server's keyring, sent in the HashSHA256-KeyID header (empty = shared key)"`
+	AuthToken        string        `json:"token" section:"security" env:"AUTH_TOKEN" flag:"auth-token" config:"secret" usage:"API token sent as a bearer token, must have the write scope"`
+	Limit            int           `json:"limit" env:"RATE_LIMIT" flag:"limit,l" usage:"max number of concurrent outbound requests"`
+	CryptoKey        string        `json:"crypto_key" section:"security" env:"CRYPTO_KEY" flag:"crypto-key" usage:"path to PEM file with public key"`
+	TLSCA            string        `json:"ca_file" section:"tls" env:"TLS_CA" flag:"tls-ca" usage:"path to PEM CA certificate to verify https:// servers with (empty = system roots)"`
+	Config           string        `json:"-" env:"CONFIG" flag:"config,c" config:"path" usage:"path to config file: JSON, YAML (.yaml) or TOML (.toml)"`
+	ConfigWatch      time.Duration `json:"config_watch" env:"CONFIG_WATCH" flag:"config-watch" usage:"interval to check the config file for changes (0 = reload only on SIGHUP)"`
+	AgentID          string        `json:"agent_id" env:"AGENT_ID" flag:"agent-id" usage:"agent identifier reported to the server (defaults to the host IP)"`
+	SpoolDir         string        `json:"spool_dir" section:"storage" env:"SPOOL_DIR" flag:"spool-dir" usage:"directory to spool batches that failed to send (empty = disabled)"`
+	SpoolMax         int           `json:"spool_max" section:"storage" env:"SPOOL_MAX" flag:"spool-max" usage:"max number of spooled batches, the oldest are dropped when full"`
+	Collectors       []string      `json:"collectors" env:"COLLECTORS" flag:"collectors" usage:"comma-separated list of enabled collectors"`
+	WatchProcess     []string      `json:"watch_process" env:"WATCH_PROCESS" flag:"watch-process" usage:"comma-separated process names or PIDs to report resource usage of"`
+	RuntimeMetrics   []string      `json:"runtime_metrics" env:"RUNTIME_METRICS" flag:"runtime-metrics" usage:"comma-separated runtime/metrics name prefixes reported by the runtimemetrics collector, e.g. /gc/,/sched/ (empty = all)"`
+	ScrapeTargets    []string      `json:"scrape_targets" env:"SCRAPE_TARGETS" flag:"scrape-targets" usage:"comma-separated Prometheus endpoints to scrape, as URL or NAME=URL to prefix their metrics"`
+	ReceiverAddress  string        `json:"receiver_address" env:"RECEIVER_ADDRESS" flag:"receiver-address" usage:"local HTTP address applications push metrics to in the server's format, e.g. localhost:9100 (empty = disabled)"`
+	StatsDAddress    string        `json:"statsd_address" env:"STATSD_ADDRESS" flag:"statsd-address" usage:"local UDP address to receive StatsD metrics on, e.g. localhost:8125 (empty = disabled)"`
+	MetricsAddress   string        `json:"metrics_address" env:"METRICS_ADDRESS" flag:"metrics-address" usage:"local HTTP address serving the agent's own metrics on /metrics, e.g. localhost:9101 (empty = disabled)"`
+	CPUModes         bool          `json:"cpu_modes" env:"CPU_MODES" flag:"cpu-modes" usage:"report user, system and iowait CPU time shares"`
+	LegacyCPUNames   bool          `json:"legacy_cpu_names" env:"LEGACY_CPU_NAMES" flag:"legacy-cpu-names" usage:"name per-core CPU utilization past the tenth core like earlier versions, such as CPUutilization: for core 10"`
+	MaxBatchCount    int           `json:"max_batch_count" env:"MAX_BATCH_COUNT" flag:"max-batch-count" usage:"max number of metrics per request, larger batches are split (0 = unlimited)"`
+	MaxBatchBytes    int           `json:"max_batch_bytes" env:"MAX_BATCH_BYTES" flag:"max-batch-bytes" usage:"max size of a request's uncompressed JSON in bytes, larger batches are split (0 = unlimited)"`
+	PrintConfig      bool          `json:"-" flag:"print-config" usage:"print the effective configuration as JSON and exit"`
+}
+
+// NewAgent returns the default agent configuration.
+func NewAgent() Agent {
+	return Agent{
+		Address:          []string{"http://localhost:8080"},
+		AddressPolicy:    "failover",
+		BreakerThreshold: 5,
+		BreakerTimeout:   30 * time.Second,
+		PollInterval:     2 * time.Second,
+		ReportInterval:   10 * time.Second,
+		Limit:            5,
+		SpoolMax:         1000,
+		MaxBatchBytes:    1 << 20,
+	}
+}
+
+// Validate checks the agent configuration and lists every invalid field.
+func (c *Agent) Validate() error {
+	var errs []error
+	positive := func(name string, ok bool) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s must be greater than 0", name))
+		}
+	}
+	nonNegative := func(name string, ok bool) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s must not be negative", name))
+		}
+	}
+
+	if len(c.Address) == 0 {
+		errs = append(errs, errors.New("address must not be empty"))
+	}
+	for _, a := range c.Address {
+		switch address.New(a).Scheme {
+		case address.SchemeHTTP, address.SchemeHTTPS, address.SchemeGRPC:
+		default:
+			errs = append(errs, fmt.Errorf("invalid address %q: %w", a, address.ErrUnsupportedScheme))
+		}
+	}
+	positive("poll_interval", c.PollInterval > 0)
+	positive("report_interval", c.ReportInterval > 0)
+	positive("limit", c.Limit > 0)
+	positive("spool_max", c.SpoolMax > 0)
+	positive("breaker_timeout", c.BreakerTimeout > 0)
+	nonNegative("breaker_threshold", c.BreakerThreshold >= 0)
+	nonNegative("config_watch", c.ConfigWatch >= 0)
+	nonNegative("max_batch_count", c.MaxBatchCount >= 0)
+	nonNegative("max_batch_bytes", c.MaxBatchBytes >= 0)
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,427 @@
+// Package config loads the typed configuration of the agent and the server.
+//
+// A configuration is a struct whose fields are tagged with their sources:
+//
+//	PollInterval time.Duration `json:"poll_interval" env:"POLL_INTERVAL" flag:"poll-interval,p" usage:"poll interval"`
+//
+// Load starts from the values the struct already holds, the defaults, and
+// overrides them with the config file, then the environment variables, then
+// the command-line flags. A field without a tag is not read from that source.
+// Supported field types are string, bool, int, int64, time.Duration and
+// []string.
+//
+// The config file is YAML (.yaml, .yml), TOML (.toml) or otherwise JSON.
+// A field tagged with a section, like `section:"storage"`, may be given
+// either at the top level of the file or inside that section:
+//
+//	storage:
+//	  store_file: /var/lib/metrics.json
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrUnknownArgs is returned for positional command-line arguments.
+var ErrUnknownArgs = errors.New("unknown flags or arguments are provided")
+
+// Validator is implemented by configurations checking their values after loading.
+type Validator interface {
+	// Validate returns an error listing every invalid field.
+	Validate() error
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// field is a configuration struct field with its sources.
+type field struct {
+	value   reflect.Value
+	json    string
+	section string
+	env     string
+	flag    string
+	short   string
+	usage   string
+	path    bool // the field holds the path of the config file
+	secret  bool // the field is masked by Print
+}
+
+// fields returns the tagged fields of the struct cfg points to.
+func fields(cfg any) []field {
+	v := reflect.ValueOf(cfg).Elem()
+	t := v.Type()
+
+	var result []field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		f := field{
+			value:   v.Field(i),
+			section: sf.Tag.Get("section"),
+			env:     sf.Tag.Get("env"),
+			usage:   sf.Tag.Get("usage"),
+		}
+		if name := strings.Split(sf.Tag.Get("json"), ",")[0]; name != "-" {
+			f.json = name
+		}
+		f.flag, f.short, _ = strings.Cut(sf.Tag.Get("flag"), ",")
+		for _, opt := range strings.Split(sf.Tag.Get("config"), ",") {
+			switch opt {
+			case "path":
+				f.path = true
+			case "secret":
+				f.secret = true
+			}
+		}
+		result = append(result, f)
+	}
+	return result
+}
+
+// Load fills cfg, a pointer to a configuration struct holding the defaults,
+// from the config file, the environment and the command-line args, each
+// overriding the previous. The config file is named by the field tagged
+// `config:"path"`, from its flag or environment variable.
+//
+// The returned error lists every invalid value, including those reported by
+// cfg's Validate method. It wraps pflag.ErrHelp if help was requested.
+func Load(cfg any, args []string) error {
+	fs := pflag.NewFlagSet(os.Args[0], pflag.ContinueOnError)
+	fs.SortFlags = false
+
+	flagValues := make(map[string]*flagValue)
+	all := fields(cfg)
+	for _, f := range all {
+		if f.flag == "" {
+			continue
+		}
+		v := &flagValue{typ: typeName(f.value)}
+		if !f.value.IsZero() {
+			v.value = format(f.value)
+		}
+		flagValues[f.flag] = v
+		fl := fs.VarPF(v, f.flag, f.short, f.usage)
+		if f.value.Kind() == reflect.Bool {
+			fl.NoOptDefVal = "true"
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return ErrUnknownArgs
+	}
+
+	var errs []error
+
+	// The config file path itself comes from the flag or the environment.
+	for _, f := range all {
+		if !f.path {
+			continue
+		}
+		if v := os.Getenv(f.env); f.env != "" && v != "" {
+			f.value.SetString(v)
+		}
+		if fs.Changed(f.flag) {
+			f.value.SetString(flagValues[f.flag].value)
+		}
+		if path := f.value.String(); path != "" {
+			errs = append(errs, loadFile(path, all)...)
+		}
+	}
+
+	for _, f := range all {
+		if v := os.Getenv(f.env); f.env != "" && v != "" && !f.path {
+			if err := set(f.value, v); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", f.env, err))
+			}
+		}
+	}
+
+	for _, f := range all {
+		if f.flag != "" && fs.Changed(f.flag) && !f.path {
+			if err := set(f.value, flagValues[f.flag].value); err != nil {
+				errs = append(errs, fmt.Errorf("--%s: %w", f.flag, err))
+			}
+		}
+	}
+
+	if v, ok := cfg.(Validator); ok {
+		if err := v.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// flagValue holds a flag as given on the command line; it is parsed with the
+// other sources so all errors are reported together.
+type flagValue struct {
+	value string
+	typ   string
+}
+
+func (v *flagValue) String() string     { return v.value }
+func (v *flagValue) Set(s string) error { v.value = s; return nil }
+func (v *flagValue) Type() string       { return v.typ }
+
+// typeName returns the type of v shown in the usage message.
+func typeName(v reflect.Value) string {
+	if v.Type() == durationType {
+		return "duration"
+	}
+	switch v.Kind() {
+	case reflect.Slice:
+		return "list"
+	case reflect.Int, reflect.Int64:
+		return "int"
+	case reflect.Bool:
+		// pflag shows no type for boolean flags.
+		return "bool"
+	default:
+		return "string"
+	}
+}
+
+// loadFile applies the config file at path to fields. The format is chosen
+// by the extension: .yaml or .yml, .toml, and JSON for any other.
+func loadFile(path string, all []field) []error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return []error{fmt.Errorf("error reading config file: %w", err)}
+	}
+
+	values := make(map[string]any)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	case ".toml":
+		err = toml.Unmarshal(data, &values)
+	default:
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		err = dec.Decode(&values)
+	}
+	if err != nil {
+		return []error{fmt.Errorf("error parsing config file: %w", err)}
+	}
+	values = flatten(values)
+
+	var errs []error
+	for _, f := range all {
+		if f.json == "" {
+			continue
+		}
+		key := f.json
+		if f.section != "" {
+			nested := f.section + "." + f.json
+			if _, ok := values[nested]; ok {
+				if _, ok := values[key]; ok {
+					errs = append(errs, fmt.Errorf("%s (config file): also set as %s", key, nested))
+					delete(values, key)
+				}
+				key = nested
+			}
+		}
+		value, ok := values[key]
+		if !ok {
+			continue
+		}
+		delete(values, key)
+		if err := setValue(f.value, value); err != nil {
+			errs = append(errs, fmt.Errorf("%s (config file): %w", key, err))
+		}
+	}
+	for name := range values {
+		errs = append(errs, fmt.Errorf("%s (config file): unknown field", name))
+	}
+	return errs
+}
+
+// flatten moves the keys of top-level sections to "section.key".
+func flatten(values map[string]any) map[string]any {
+	flat := make(map[string]any, len(values))
+	for key, value := range values {
+		section, ok := value.(map[string]any)
+		if !ok {
+			flat[key] = value
+			continue
+		}
+		for name, value := range section {
+			flat[key+"."+name] = value
+		}
+	}
+	return flat
+}
+
+// setValue sets v from a decoded config file value. Strings, numbers and
+// booleans are parsed like environment variables; a list may also be given
+// as an array of strings.
+func setValue(v reflect.Value, value any) error {
+	switch value := value.(type) {
+	case nil:
+		return nil
+	case string:
+		return set(v, value)
+	case bool:
+		return set(v, strconv.FormatBool(value))
+	case json.Number:
+		return set(v, value.String())
+	case int:
+		return set(v, strconv.Itoa(value))
+	case int64:
+		return set(v, strconv.FormatInt(value, 10))
+	case uint64:
+		return set(v, strconv.FormatUint(value, 10))
+	case float64:
+		return set(v, strconv.FormatFloat(value, 'f', -1, 64))
+	case []any:
+		if v.Kind() != reflect.Slice {
+			return errors.New("must be a single value, not a list")
+		}
+		items := make([]string, 0, len(value))
+		for _, item := range value {
+			s, ok := item.(string)
+			if !ok {
+				return errors.New("must be a list of strings")
+			}
+			items = append(items, s)
+		}
+		v.Set(reflect.ValueOf(items))
+		return nil
+	case map[string]any:
+		return errors.New("must be a value, not a section")
+	case []map[string]any:
+		return errors.New("must be a value, not a list of sections")
+	default:
+		return fmt.Errorf("unsupported value %v", value)
+	}
+}
+
+// set parses s into v.
+func set(v reflect.Value, s string) error {
+	if v.Type() == durationType {
+		d, err := ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("invalid value %q, must be true or false", s)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid value %q, must be an integer", s)
+		}
+		v.SetInt(i)
+	case reflect.Slice:
+		v.Set(reflect.ValueOf(SplitList(s)))
+	default:
+		panic("config: unsupported field type " + v.Type().String())
+	}
+	return nil
+}
+
+// format returns v as it is given on the command line.
+func format(v reflect.Value) string {
+	if v.Type() == durationType {
+		return time.Duration(v.Int()).String()
+	}
+	if v.Kind() == reflect.Slice {
+		return strings.Join(v.Interface().([]string), ",")
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// ParseDuration parses a duration like "10s" or "1m30s". A plain integer is
+// taken as seconds, as in earlier versions.
+func ParseDuration(s string) (time.Duration, error) {
+	if i, err := strconv.Atoi(s); err == nil {
+		return time.Duration(i) * time.Second, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q, must be like 10s or 1m", s)
+	}
+	return d, nil
+}
+
+// SplitList splits a comma-separated value, skipping empty items.
+func SplitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Print writes the configuration cfg points to as a JSON object with the
+// config file keys, masking secrets. Durations are written like "10s".
+// Fields with a section are written in a nested object after the others.
+func Print(w io.Writer, cfg any) error {
+	var (
+		top      []string
+		sections []string
+		nested   = make(map[string][]string)
+	)
+	for _, f := range fields(cfg) {
+		if f.json == "" {
+			continue
+		}
+
+		var value any = f.value.Interface()
+		switch {
+		case f.secret && !f.value.IsZero():
+			value = "***"
+		case f.value.Type() == durationType:
+			value = format(f.value)
+		case f.value.Kind() == reflect.Slice && f.value.IsNil():
+			value = []string{}
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+
+		entry := fmt.Sprintf("%q: %s", f.json, data)
+		if f.section == "" {
+			top = append(top, entry)
+			continue
+		}
+		if _, ok := nested[f.section]; !ok {
+			sections = append(sections, f.section)
+		}
+		nested[f.section] = append(nested[f.section], entry)
+	}
+
+	for _, section := range sections {
+		top = append(top, fmt.Sprintf("%q: {\n    %s\n  }", section, strings.Join(nested[section], ",\n    ")))
+	}
+	_, err := fmt.Fprintf(w, "{\n  %s\n}\n", strings.Join(top, ",\n  "))
+	return err
+}
@@ -0,0 +1,280 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeConfig writes a JSON config file and returns its path.
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	return writeConfigFile(t, "config.json", content)
+}
+
+// writeConfigFile writes a config file with the given name and returns its path.
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	path := writeConfig(t, `{
+		"poll_interval": "5s",
+		"report_interval": 20,
+		"limit": "3",
+		"key": "file-key",
+		"address": ["http://a:8080", "http://b:8080"],
+		"address_policy": "replicate",
+		"cpu_modes": true
+	}`)
+	t.Setenv("CONFIG", path)
+	t.Setenv("REPORT_INTERVAL", "1m")
+	t.Setenv("KEY", "env-key")
+
+	cfg := NewAgent()
+	err := Load(&cfg, []string{"-k", "flag-key", "--legacy-cpu-names"})
+	require.NoError(t, err)
+
+	assert.Equal(t, 5*time.Second, cfg.PollInterval, "file over default")
+	assert.Equal(t, time.Minute, cfg.ReportInterval, "env over file")
+	assert.Equal(t, "flag-key", cfg.Key, "flag over env")
+	assert.Equal(t, 3, cfg.Limit)
+	assert.Equal(t, []string{"http://a:8080", "http://b:8080"}, cfg.Address)
+	assert.Equal(t, "replicate", cfg.AddressPolicy)
+	assert.True(t, cfg.CPUModes)
+	assert.True(t, cfg.LegacyCPUNames, "bare boolean flag")
+	assert.Equal(t, 1000, cfg.SpoolMax, "default kept")
+	assert.Equal(t, path, cfg.Config)
+}
+
+func TestLoad_FlagOverridesConfigPath(t *testing.T) {
+	t.Setenv("CONFIG", writeConfig(t, `{"address": "localhost:1"}`))
+	path := writeConfig(t, `{"address": "localhost:2", "store_interval": 0, "restore": "true"}`)
+
+	cfg := NewServer()
+	require.NoError(t, Load(&cfg, []string{"--config", path}))
+	assert.Equal(t, "localhost:2", cfg.Address)
+	assert.Equal(t, time.Duration(0), cfg.StoreInterval)
+	assert.True(t, cfg.Restore)
+}
+
+func TestLoad_ListsEveryError(t *testing.T) {
+	path := writeConfig(t, `{"poll_interval": "soon", "limt": 3, "cpu_modes": "maybe"}`)
+	t.Setenv("RATE_LIMIT", "many")
+
+	cfg := NewAgent()
+	err := Load(&cfg, []string{"-c", path, "--spool-max", "0", "-a", ","})
+	require.Error(t, err)
+
+	for _, want := range []string{
+		`poll_interval (config file): invalid duration "soon"`,
+		`limt (config file): unknown field`,
+		`cpu_modes (config file): invalid value "maybe", must be true or false`,
+		`RATE_LIMIT: invalid value "many", must be an integer`,
+		`spool_max must be greater than 0`,
+		`address must not be empty`,
+	} {
+		assert.Contains(t, err.Error(), want)
+	}
+}
+
+func TestLoad_Formats(t *testing.T) {
+	files := map[string]string{
+		"config.json": `{
+			"address": "localhost:9090",
+			"log_level": "debug",
+			"storage": {"store_interval": 10, "store_file": "/tmp/m.json", "restore": true},
+			"security": {"key": "secret", "trusted_subnet": "10.0.0.0/8", "replay_window": "30s"},
+			"tls": {"cert_file": "cert.pem", "key_file": "key.pem"}
+		}`,
+		"config.yaml": `
+address: localhost:9090
+log_level: debug
+storage:
+  store_interval: 10s
+  store_file: /tmp/m.json
+  restore: true
+security:
+  key: secret
+  trusted_subnet: 10.0.0.0/8
+  replay_window: 30s
+tls:
+  cert_file: cert.pem
+  key_file: key.pem
+`,
+		"config.toml": `
+address = "localhost:9090"
+log_level = "debug" # comment
+tls = { cert_file = "cert.pem", key_file = "key.pem" }
+
+[storage]
+store_interval = 10
+store_file = "/tmp/m.json"
+restore = true
+
+[security]
+key = 'secret'
+trusted_subnet = "10.0.0.0/8"
+replay_window = "30s"
+`,
+	}
+
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			cfg := NewServer()
+			require.NoError(t, Load(&cfg, []string{"-c", writeConfigFile(t, name, content)}))
+
+			assert.Equal(t, "localhost:9090", cfg.Address)
+			assert.Equal(t, "debug", cfg.LogLevel)
+			assert.Equal(t, 10*time.Second, cfg.StoreInterval)
+			assert.Equal(t, "/tmp/m.json", cfg.FileStoragePath)
+			assert.True(t, cfg.Restore)
+			assert.Equal(t, "secret", cfg.Key)
+			assert.Equal(t, "10.0.0.0/8", cfg.TrustedSubnet)
+			assert.Equal(t, 30*time.Second, cfg.ReplayWindow)
+			assert.Equal(t, 100000, cfg.ReplayCache)
+			assert.Equal(t, "cert.pem", cfg.TLSCert)
+			assert.Equal(t, "key.pem", cfg.TLSKey)
+		})
+	}
+}
+
+func TestLoad_YAMLList(t *testing.T) {
+	path := writeConfigFile(t, "agent.yml", `
+address:
+  - http://a:8080
+  - http://b:8080
+collectors: [runtime, memory]
+security:
+  key: secret
+storage:
+  spool_dir: /tmp/spool
+`)
+
+	cfg := NewAgent()
+	require.NoError(t, Load(&cfg, []string{"-c", path}))
+	assert.Equal(t, []string{"http://a:8080", "http://b:8080"}, cfg.Address)
+	assert.Equal(t, []string{"runtime", "memory"}, cfg.Collectors)
+	assert.Equal(t, "secret", cfg.Key)
+	assert.Equal(t, "/tmp/spool", cfg.SpoolDir)
+}
+
+func TestLoad_TOMLList(t *testing.T) {
+	path := writeConfigFile(t, "agent.toml", `
+address = [
+  "http://a:8080",
+  "http://b:8080",
+]
+collectors = ["runtime", "memory"]
+security = { key = "secret" }
+
+[storage]
+spool_dir = "/tmp/spool"
+`)
+
+	cfg := NewAgent()
+	require.NoError(t, Load(&cfg, []string{"-c", path}))
+	assert.Equal(t, []string{"http://a:8080", "http://b:8080"}, cfg.Address)
+	assert.Equal(t, []string{"runtime", "memory"}, cfg.Collectors)
+	assert.Equal(t, "secret", cfg.Key)
+	assert.Equal(t, "/tmp/spool", cfg.SpoolDir)
+
+	path = writeConfigFile(t, "agent.toml", `
+[[address]]
+url = "http://a:8080"
+`)
+	err := Load(&cfg, []string{"-c", path})
+	assert.ErrorContains(t, err, "address (config file): must be a value, not a list of sections")
+}
+
+func TestLoad_SectionErrors(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+key: flat
+security:
+  key: nested
+  password: x
+storage: {store_file: {path: x}}
+address: [a, b]
+`)
+
+	cfg := NewServer()
+	err := Load(&cfg, []string{"-c", path})
+	require.Error(t, err)
+	for _, want := range []string{
+		`key (config file): also set as security.key`,
+		`security.password (config file): unknown field`,
+		`storage.store_file (config file): must be a value, not a section`,
+		`address (config file): must be a single value, not a list`,
+	} {
+		assert.Contains(t, err.Error(), want)
+	}
+
+	err = Load(&cfg, []string{"-c", writeConfigFile(t, "config.toml", "address = \n")})
+	assert.ErrorContains(t, err, "error parsing config file: toml: line 1")
+}
+
+func TestLoad_Errors(t *testing.T) {
+	cfg := NewServer()
+	assert.ErrorIs(t, Load(&cfg, []string{"extra"}), ErrUnknownArgs)
+	assert.ErrorIs(t, Load(&cfg, []string{"--help"}), pflag.ErrHelp)
+
+	err := Load(&cfg, []string{"-c", filepath.Join(t.TempDir(), "missing.json")})
+	assert.ErrorContains(t, err, "error reading config file")
+
+	err = Load(&cfg, []string{"-c", writeConfig(t, `{`)})
+	assert.ErrorContains(t, err, "error parsing config file")
+
+	cfg = NewServer()
+	err = Load(&cfg, []string{"-t", "10.0.0.0", "--log-level", "loud", "--replay-cache", "0"})
+	assert.ErrorContains(t, err, "invalid trusted_subnet value")
+	assert.ErrorContains(t, err, "replay_cache must be greater than 0")
+	assert.ErrorContains(t, err, "invalid log_level value")
+}
+
+func TestParseDuration(t *testing.T) {
+	for s, want := range map[string]time.Duration{
+		"10":    10 * time.Second,
+		"0":     0,
+		"1m30s": 90 * time.Second,
+		"250ms": 250 * time.Millisecond,
+	} {
+		d, err := ParseDuration(s)
+		require.NoError(t, err, s)
+		assert.Equal(t, want, d, s)
+	}
+
+	_, err := ParseDuration("ten")
+	assert.Error(t, err)
+}
+
+func TestPrint(t *testing.T) {
+	cfg := NewServer()
+	cfg.DatabaseDSN = "postgres://user:pass@db/metrics"
+
+	var buf bytes.Buffer
+	require.NoError(t, Print(&buf, &cfg))
+
+	out := buf.String()
+	assert.Contains(t, out, `"address": "localhost:8080",`)
+	assert.Contains(t, out, `"store_interval": "5m0s",`)
+	assert.Contains(t, out, `"storage": {`)
+	assert.Contains(t, out, `"database_dsn": "***"`)
+	assert.Contains(t, out, `"key": "",`)
+	assert.Contains(t, out, `"max_body_size": 10485760,`)
+	assert.NotContains(t, out, "print")
+	assert.NotContains(t, out, "pass")
+
+	// The printed configuration is a valid config file.
+	loaded := NewServer()
+	require.NoError(t, Load(&loaded, []string{"-c", writeConfig(t, out)}))
+	assert.Equal(t, cfg.StoreInterval, loaded.StoreInterval)
+}
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Server is the configuration of the server.
+type Server struct {
+	Address         string        `json:"address" env:"ADDRESS" flag:"address,a" usage:"server URL"`
+	StoreInterval   time.Duration `json:"store_interval" section:"storage" env:"STORE_INTERVAL" flag:"interval,i" usage:"interval to save metrics, e.g. 300s (0 = sync save, a plain number is seconds)"`
+	FileStoragePath string        `json:"store_file" section:"storage" env:"FILE_STORAGE_PATH" flag:"file,f" usage:"file path to store metrics"`
+	Restore         bool          `json:"restore" section:"storage" env:"RESTORE" flag:"restore,r" usage:"restore metrics from file on startup"`
+	DatabaseDSN     string        `json:"database_dsn" section:"storage" env:"DATABASE_DSN" flag:"database-dsn,d" config:"secret" usage:"PostgreSQL DSN connection string"`
+	Key             string        `json:"key" section:"security" env:"KEY" flag:"key,k" config:"secret" usage:"key for SHA256 hashing"`
+	Keys            []string      `json:"keys" section:"security" env:"KEYS" flag:"keys" config:"secret" usage:"comma-separated per-agent signing keys as ID=SECRET, used for requests naming the key in the HashSHA256-KeyID header"`
+	CryptoKey       string        `json:"crypto_key" section:"security" env:"CRYPTO_KEY" flag:"crypto-key" usage:"path to file with private key for hashing"`
+	ReplayWindow    time.Duration `json:"replay_window" section:"security" env:"REPLAY_WINDOW" flag:"replay-window" usage:"max clock skew of signed requests carrying HashSHA256-Timestamp and HashSHA256-Nonce; older ones and repeated nonces get 400 (0 = no replay protection)"`
+	ReplayRequired  bool          `json:"replay_required" section:"security" env:"REPLAY_REQUIRED" flag:"replay-required" usage:"reject signed requests without HashSHA256-Timestamp and HashSHA256-Nonce, as sent by earlier agents"`
+	ReplayCache     int           `json:"replay_cache" section:"security" env:"REPLAY_CACHE" flag:"replay-cache" usage:"max nonces remembered to detect replayed requests"`
+	AuthTokens      []string      `json:"tokens" section:"security" env:"AUTH_TOKENS" flag:"auth-tokens" usage:"comma-separated API tokens as SHA256-HEX:SCOPES, scopes read, write and admin joined by + (empty = no authentication)"`
+	TLSCert         string        `json:"cert_file" section:"tls" env:"TLS_CERT" flag:"tls-cert" usage:"path to PEM certificate to serve HTTPS and gRPC over TLS"`
+	TLSKey          string        `json:"key_file" section:"tls" env:"TLS_KEY" flag:"tls-key" usage:"path to PEM private key of the TLS certificate"`
+	Config          string        `json:"-" env:"CONFIG" flag:"config,c" config:"path" usage:"path to config file: JSON, YAML (.yaml) or TOML (.toml)"`
+	ConfigWatch     time.Duration `json:"config_watch" env:"CONFIG_WATCH" flag:"config-watch" usage:"interval to check the config file for changes (0 = reload only on SIGHUP)"`
+	TrustedSubnet   string        `json:"trusted_subnet" section:"security" env:"TRUSTED_SUBNET" flag:"trusted-subnet,t" usage:"trusted subnet in CIDR notation"`
+	TrustedProxies  []string      `json:"trusted_proxies" section:"security" env:"TRUSTED_PROXIES" flag:"trusted-proxies" usage:"comma-separated CIDRs of reverse proxies whose X-Real-IP header identifies clients for rate limits (empty = the connection address)"`
+	AlertRules      string        `json:"alert_rules" env:"ALERT_RULES" flag:"alert-rules" usage:"path to JSON file with alerting rules"`
+	AlertInterval   time.Duration `json:"alert_interval" env:"ALERT_INTERVAL" flag:"alert-interval" usage:"interval to evaluate alerting rules"`
+	AgentStaleAfter time.Duration `json:"agent_stale_after" env:"AGENT_STALE_AFTER" flag:"agent-stale-after" usage:"time without reports after which an agent and its metrics are stale"`
+	AgentDeadAfter  time.Duration `json:"agent_dead_after" env:"AGENT_DEAD_AFTER" flag:"agent-dead-after" usage:"time without reports after which an agent is dead"`
+	AgentRetention  time.Duration `json:"agent_retention" env:"AGENT_RETENTION" flag:"agent-retention" usage:"time without reports after which an agent is removed from the list (0 = never)"`
+	HistorySize     int           `json:"history_size" env:"HISTORY_SIZE" flag:"history-size" usage:"number of recent values per metric kept for dashboard sparklines (0 = disabled)"`
+	MaxBodySize     int64         `json:"max_body_size" env:"MAX_BODY_SIZE" flag:"max-body-size" usage:"max request body size in bytes, larger requests are rejected with 413 (0 = unlimited)"`
+	RequestRate     int           `json:"request_rate" env:"REQUEST_RATE" flag:"request-rate" usage:"max requests per second of each client address, see trusted_proxies; more get 429 (0 = unlimited)"`
+	RequestBurst    int           `json:"request_burst" env:"REQUEST_BURST" flag:"request-burst" usage:"max requests of each client at once (0 = request_rate)"`
+	MetricQuota     int           `json:"metric_quota" env:"METRIC_QUOTA" flag:"metric-quota" usage:"max distinct metrics each API token, or client address without authentication, may report; updates of new metrics beyond it get 429 (0 = unlimited)"`
+	LogLevel        string        `json:"log_level" env:"LOG_LEVEL" flag:"log-level" usage:"request log level: debug, info, warn or error"`
+	PrintConfig     bool          `json:"-" flag:"print-config" usage:"print the effective configuration as JSON and exit"`
+}
+
+// NewServer returns the default server configuration.
+func NewServer() Server {
+	return Server{
+		Address:         "localhost:8080",
+		StoreInterval:   300 * time.Second,
+		FileStoragePath: "metrics.json",
+		ReplayWindow:    5 * time.Minute,
+		ReplayCache:     100000,
+		AlertInterval:   10 * time.Second,
+		AgentStaleAfter: time.Minute,
+		AgentDeadAfter:  5 * time.Minute,
+		AgentRetention:  time.Hour,
+		HistorySize:     60,
+		MaxBodySize:     10 << 20,
+		LogLevel:        "info",
+	}
+}
+
+// Validate checks the server configuration and lists every invalid field.
+func (c *Server) Validate() error {
+	var errs []error
+	nonNegative := func(name string, ok bool) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s must not be negative", name))
+		}
+	}
+
+	if c.Address == "" {
+		errs = append(errs, errors.New("address must not be empty"))
+	}
+	nonNegative("store_interval", c.StoreInterval >= 0)
+	nonNegative("config_watch", c.ConfigWatch >= 0)
+	if c.AlertInterval <= 0 {
+		errs = append(errs, errors.New("alert_interval must be greater than 0"))
+	}
+	nonNegative("agent_stale_after", c.AgentStaleAfter >= 0)
+	nonNegative("agent_dead_after", c.AgentDeadAfter >= 0)
+	nonNegative("agent_retention", c.AgentRetention >= 0)
+	if c.AgentRetention > 0 && c.AgentRetention < c.AgentDeadAfter {
+		errs = append(errs, errors.New("agent_retention must not be less than agent_dead_after"))
+	}
+	nonNegative("history_size", c.HistorySize >= 0)
+	nonNegative("max_body_size", c.MaxBodySize >= 0)
+	nonNegative("request_rate", c.RequestRate >= 0)
+	nonNegative("request_burst", c.RequestBurst >= 0)
+	nonNegative("metric_quota", c.MetricQuota >= 0)
+	if (c.TLSCert == "") != (c.TLSKey == "") {
+		errs = append(errs, errors.New("tls cert_file and key_file must be set together"))
+	}
+	nonNegative("replay_window", c.ReplayWindow >= 0)
+	if c.ReplayWindow > 0 && c.ReplayCache <= 0 {
+		errs = append(errs, errors.New("replay_cache must be greater than 0 with replay_window set"))
+	}
+	if c.TrustedSubnet != "" {
+		if _, _, err := net.ParseCIDR(c.TrustedSubnet); err != nil {
+			errs = append(errs, fmt.Errorf("invalid trusted_subnet value: %w", err))
+		}
+	}
+	if _, err := zapcore.ParseLevel(c.LogLevel); err != nil {
+		errs = append(errs, fmt.Errorf("invalid log_level value: %w", err))
+	}
+
+	return errors.Join(errs...)
+}
@@ -4,11 +4,16 @@ import (
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
 )
 
 // Hasher computes HMAC-SHA256 hashes using a secret key.
 type Hasher struct {
 	key string
+	id  string // ID of the key in the server's keyring, if any
 }
 
 // New creates a Hasher with the given key.
@@ -23,3 +28,99 @@ func (h *Hasher) Hash(data []byte) string {
 	mac.Write(data)
 	return hex.EncodeToString(mac.Sum(nil))
 }
+
+// Message returns the signed material of a request body sent at timestamp,
+// in Unix seconds, with nonce. Signing them with the body keeps a captured
+// request from being replayed later or with another nonce.
+func Message(timestamp, nonce string, body []byte) []byte {
+	msg := make([]byte, 0, len(timestamp)+len(nonce)+2+len(body))
+	msg = append(msg, timestamp...)
+	msg = append(msg, '\n')
+	msg = append(msg, nonce...)
+	msg = append(msg, '\n')
+	return append(msg, body...)
+}
+
+// Reloadable computes HMAC-SHA256 hashes with a key that can be replaced at
+// runtime. While the key is empty it returns an empty hash, so no hash header
+// is sent.
+type Reloadable struct {
+	current atomic.Pointer[Hasher]
+}
+
+// NewReloadable creates a Reloadable hasher with the given key.
+func NewReloadable(key string) *Reloadable {
+	r := &Reloadable{}
+	r.SetKey(key)
+	return r
+}
+
+// SetKey replaces the key used for subsequent hashes.
+func (r *Reloadable) SetKey(key string) {
+	r.current.Store(New(key))
+}
+
+// SetSigningKey replaces the key used for subsequent hashes and the ID it
+// has in the server's keyring.
+func (r *Reloadable) SetSigningKey(id, key string) {
+	r.current.Store(&Hasher{key: key, id: id})
+}
+
+// Hash computes the HMAC-SHA256 hash of data with the current key,
+// or returns "" if the key is empty.
+func (r *Reloadable) Hash(data []byte) string {
+	h := r.current.Load()
+	if h.key == "" {
+		return ""
+	}
+	return h.Hash(data)
+}
+
+// Sign computes the hash of data like Hash and returns it with the ID of the
+// key used, which is "" for a key without an ID.
+func (r *Reloadable) Sign(data []byte) (hash, keyID string) {
+	h := r.current.Load()
+	if h.key == "" {
+		return "", ""
+	}
+	return h.Hash(data), h.id
+}
+
+// Keyring holds signing keys by ID, so each agent can sign with its own key
+// and keys can be rotated by adding the new key before removing the old one.
+type Keyring struct {
+	keys map[string]*Hasher
+}
+
+// NewKeyring creates a keyring from entries of the form ID=SECRET.
+func NewKeyring(entries []string) (*Keyring, error) {
+	k := &Keyring{keys: make(map[string]*Hasher, len(entries))}
+	var errs []error
+	for i, entry := range entries {
+		id, key, ok := strings.Cut(entry, "=")
+		id = strings.TrimSpace(id)
+		switch {
+		case !ok || id == "" || key == "":
+			// The entry is not quoted as it may be a bare secret.
+			errs = append(errs, fmt.Errorf("invalid key #%d, must be ID=SECRET", i+1))
+		case k.keys[id] != nil:
+			errs = append(errs, fmt.Errorf("duplicate key ID %q", id))
+		default:
+			k.keys[id] = &Hasher{key: key, id: id}
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return k, nil
+}
+
+// HashWithKey computes the HMAC-SHA256 hash of data with the key keyID.
+// It reports false if the keyring has no such key.
+func (k *Keyring) HashWithKey(keyID string, data []byte) (string, bool) {
+	h, ok := k.keys[keyID]
+	if !ok {
+		return "", false
+	}
+	return h.Hash(data), true
+}
@@ -36,3 +36,72 @@ func TestHasher_Hash_EmptyKey(t *testing.T) {
 	expected := expectedHasher.Hash(data)
 	require.Equal(t, expected, result)
 }
+
+func TestReloadable_Hash(t *testing.T) {
+	data := []byte("test data")
+	r := NewReloadable("")
+	require.Empty(t, r.Hash(data))
+
+	r.SetKey("secret")
+	require.Equal(t, New("secret").Hash(data), r.Hash(data))
+
+	r.SetKey("other")
+	require.Equal(t, New("other").Hash(data), r.Hash(data))
+}
+
+func TestReloadable_Sign(t *testing.T) {
+	data := []byte("test data")
+	r := NewReloadable("")
+
+	hash, keyID := r.Sign(data)
+	require.Empty(t, hash)
+	require.Empty(t, keyID)
+
+	r.SetSigningKey("agent-1", "secret")
+	hash, keyID = r.Sign(data)
+	require.Equal(t, New("secret").Hash(data), hash)
+	require.Equal(t, "agent-1", keyID)
+	require.Equal(t, hash, r.Hash(data))
+
+	r.SetKey("shared")
+	hash, keyID = r.Sign(data)
+	require.Equal(t, New("shared").Hash(data), hash)
+	require.Empty(t, keyID)
+}
+
+func TestKeyring(t *testing.T) {
+	data := []byte("test data")
+	k, err := NewKeyring([]string{"agent-1=old", "agent-1-next=new", " agent-2 =a=b"})
+	require.NoError(t, err)
+
+	hash, ok := k.HashWithKey("agent-1", data)
+	require.True(t, ok)
+	require.Equal(t, New("old").Hash(data), hash)
+
+	hash, ok = k.HashWithKey("agent-1-next", data)
+	require.True(t, ok)
+	require.Equal(t, New("new").Hash(data), hash)
+
+	hash, ok = k.HashWithKey("agent-2", data)
+	require.True(t, ok)
+	require.Equal(t, New("a=b").Hash(data), hash, "secret may contain =")
+
+	_, ok = k.HashWithKey("agent-3", data)
+	require.False(t, ok)
+}
+
+func TestNewKeyring_Errors(t *testing.T) {
+	_, err := NewKeyring([]string{"bare-secret", "a=1", "a=2", "b="})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "invalid key #1, must be ID=SECRET")
+	require.Contains(t, err.Error(), `duplicate key ID "a"`)
+	require.Contains(t, err.Error(), "invalid key #4")
+	require.NotContains(t, err.Error(), "bare-secret")
+}
+
+func TestMessage(t *testing.T) {
+	require.Equal(t, []byte("1700000000\nabc\n{}"), Message("1700000000", "abc", []byte("{}")))
+
+	// The separators keep the fields from shifting into each other.
+	require.NotEqual(t, Message("1", "23", nil), Message("12", "3", nil))
+}
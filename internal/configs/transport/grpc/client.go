@@ -1,11 +1,13 @@
 package grpc
 
 import (
+	"context"
 	"fmt"
 	"time"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
 )
 
 // Opt is a function type that returns a grpc.DialOption and an error.
@@ -71,3 +73,26 @@ func WithRetryPolicy(rp RetryPolicy) Opt {
 		return grpc.WithDefaultServiceConfig(cfg), nil
 	}
 }
+
+// WithMetadata returns an Opt that attaches the given key/value pair
+// to the outgoing metadata of every unary call.
+// If the value is empty, no metadata is attached.
+func WithMetadata(key, value string) Opt {
+	return func() (grpc.DialOption, error) {
+		if value == "" {
+			return nil, nil
+		}
+
+		return grpc.WithChainUnaryInterceptor(func(
+			ctx context.Context,
+			method string,
+			req, reply any,
+			cc *grpc.ClientConn,
+			invoker grpc.UnaryInvoker,
+			opts ...grpc.CallOption,
+		) error {
+			ctx = metadata.AppendToOutgoingContext(ctx, key, value)
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}), nil
+	}
+}
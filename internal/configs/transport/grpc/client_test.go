@@ -73,3 +73,13 @@ func TestNew_ErrorInOption(t *testing.T) {
 	require.Error(t, err)
 	assert.Nil(t, conn)
 }
+
+func TestWithMetadata(t *testing.T) {
+	dialOpt, err := WithMetadata("x-agent-id", "agent-1")()
+	require.NoError(t, err)
+	assert.NotNil(t, dialOpt)
+
+	dialOpt, err = WithMetadata("x-agent-id", "")()
+	require.NoError(t, err)
+	assert.Nil(t, dialOpt)
+}
@@ -58,3 +58,24 @@ func WithRetryPolicy(policies ...RetryPolicy) Opt {
 		c.SetRetryMaxWaitTime(0)
 	}
 }
+
+// WithHeader returns an Opt that sets a header sent with every request.
+// If the value is empty, the client remains unchanged.
+func WithHeader(name, value string) Opt {
+	return func(c *resty.Client) {
+		if value != "" {
+			c.SetHeader(name, value)
+		}
+	}
+}
+
+// WithRootCertificate returns an Opt that verifies servers with the PEM
+// encoded CA certificates instead of the system roots.
+// If pem is empty, the client remains unchanged.
+func WithRootCertificate(pem []byte) Opt {
+	return func(c *resty.Client) {
+		if len(pem) > 0 {
+			c.SetRootCertificateFromString(string(pem))
+		}
+	}
+}
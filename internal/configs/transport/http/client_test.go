@@ -119,3 +119,27 @@ func TestWithRetryPolicy(t *testing.T) {
 		})
 	}
 }
+
+func TestWithHeader(t *testing.T) {
+	client := resty.New()
+
+	WithHeader("X-Agent-ID", "agent-1")(client)
+	WithHeader("X-Empty", "")(client)
+
+	assert.Equal(t, "agent-1", client.Header.Get("X-Agent-ID"))
+	assert.Empty(t, client.Header.Values("X-Empty"))
+}
+
+func TestWithRootCertificate(t *testing.T) {
+	client := New("https://api.test", WithRootCertificate(nil))
+	transport, err := client.Transport()
+	assert.NoError(t, err)
+	assert.Nil(t, transport.TLSClientConfig)
+
+	client = New("https://api.test", WithRootCertificate([]byte("not a certificate")))
+	transport, err = client.Transport()
+	assert.NoError(t, err)
+	if assert.NotNil(t, transport.TLSClientConfig) {
+		assert.NotNil(t, transport.TLSClientConfig.RootCAs)
+	}
+}
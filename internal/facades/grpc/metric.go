@@ -4,6 +4,9 @@ import (
 	"context"
 
 	"github.com/sbilibin2017/gophmetrics/internal/models"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/timestamppb"
 	"google.golang.org/protobuf/types/known/wrapperspb"
 
@@ -33,7 +36,9 @@ func NewMetricGRPCFacade(
 // to the protobuf message format, wrapping optional fields appropriately,
 // and invokes the Update RPC.
 //
-// Returns an error if any of the RPC calls fail.
+// Returns an error if any of the RPC calls fail. The error of a call the
+// server refused for good, such as an invalid metric, reports that with
+// Permanent.
 func (f *MetricGRPCFacade) Update(ctx context.Context, metrics []*models.Metrics) error {
 	for _, metric := range metrics {
 		var delta *wrapperspb.Int64Value
@@ -59,9 +64,37 @@ func (f *MetricGRPCFacade) Update(ctx context.Context, metrics []*models.Metrics
 
 		_, err := f.client.Update(ctx, req)
 		if err != nil {
-			return err
+			return &statusError{err: err}
 		}
 	}
 
 	return nil
 }
+
+// statusError is the error of a failed RPC.
+type statusError struct {
+	err error
+}
+
+func (e *statusError) Error() string { return e.err.Error() }
+
+func (e *statusError) Unwrap() error { return e.err }
+
+// Permanent reports whether sending the same metric again would fail too.
+// ResourceExhausted is only temporary with RetryInfo, as sent by the rate
+// limit; without it the client is over its metric quota.
+func (e *statusError) Permanent() bool {
+	st := status.Convert(e.err)
+	switch st.Code() {
+	case codes.InvalidArgument, codes.Unauthenticated, codes.PermissionDenied, codes.Unimplemented:
+		return true
+	case codes.ResourceExhausted:
+		for _, detail := range st.Details() {
+			if _, ok := detail.(*errdetails.RetryInfo); ok {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
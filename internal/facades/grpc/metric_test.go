@@ -11,7 +11,11 @@ import (
 
 	"github.com/sbilibin2017/gophmetrics/internal/models"
 	pb "github.com/sbilibin2017/gophmetrics/pkg/grpc"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
 )
 
 // mockMetricWriteClient mocks pb.MetricWriteServiceClient for tests.
@@ -101,3 +105,34 @@ func TestMetricGRPCFacade_Update_Error(t *testing.T) {
 	require.Error(t, err)
 	assert.EqualError(t, err, "rpc error")
 }
+
+func TestMetricGRPCFacade_Update_Permanent(t *testing.T) {
+	rateLimited, err := status.New(codes.ResourceExhausted, "rate limit exceeded").
+		WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(time.Second)})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		err       error
+		permanent bool
+	}{
+		{name: "unavailable", err: status.Error(codes.Unavailable, "connection refused")},
+		{name: "rate limit", err: rateLimited.Err()},
+		{name: "metric quota", err: status.Error(codes.ResourceExhausted, "metric quota exceeded"), permanent: true},
+		{name: "invalid metric", err: status.Error(codes.InvalidArgument, "invalid type"), permanent: true},
+		{name: "unauthenticated", err: status.Error(codes.Unauthenticated, "missing token"), permanent: true},
+		{name: "permission denied", err: status.Error(codes.PermissionDenied, "read-only token"), permanent: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			facade := NewMetricGRPCFacade(&mockMetricWriteClient{UpdateErr: tt.err})
+			err := facade.Update(context.Background(), []*models.Metrics{{ID: "m", MType: models.Gauge}})
+
+			var p interface{ Permanent() bool }
+			require.ErrorAs(t, err, &p)
+			assert.Equal(t, tt.permanent, p.Permanent())
+			assert.Equal(t, status.Code(tt.err), status.Code(err), "the status is kept")
+		})
+	}
+}
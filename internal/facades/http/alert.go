@@ -0,0 +1,37 @@
+package http
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/sbilibin2017/gophmetrics/internal/models"
+)
+
+// AlertWebhookFacade delivers alert notifications to webhook URLs.
+type AlertWebhookFacade struct {
+	client *resty.Client
+}
+
+// NewAlertWebhookFacade creates a new AlertWebhookFacade with the given REST client.
+func NewAlertWebhookFacade(client *resty.Client) *AlertWebhookFacade {
+	return &AlertWebhookFacade{client: client}
+}
+
+// Notify posts the alert as JSON to the given webhook URL.
+func (f *AlertWebhookFacade) Notify(ctx context.Context, url string, alert models.Alert) error {
+	resp, err := f.client.R().
+		SetContext(ctx).
+		SetHeader("Content-Type", "application/json").
+		SetBody(alert).
+		Post(url)
+	if err != nil {
+		return err
+	}
+
+	if resp.IsError() {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode())
+	}
+
+	return nil
+}
@@ -0,0 +1,51 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/sbilibin2017/gophmetrics/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlertWebhookFacade_Notify(t *testing.T) {
+	alert := models.Alert{Name: "LowMemory", State: models.AlertFiring, Condition: "FreeMemory < 1e+09"}
+
+	t.Run("posts alert JSON", func(t *testing.T) {
+		var received models.Alert
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodPost, r.Method)
+			assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer srv.Close()
+
+		f := NewAlertWebhookFacade(resty.New())
+		require.NoError(t, f.Notify(context.Background(), srv.URL, alert))
+		assert.Equal(t, alert.Name, received.Name)
+		assert.Equal(t, alert.State, received.State)
+	})
+
+	t.Run("error status", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+		}))
+		defer srv.Close()
+
+		f := NewAlertWebhookFacade(resty.New())
+		err := f.Notify(context.Background(), srv.URL, alert)
+		assert.EqualError(t, err, "webhook responded with status 502")
+	})
+
+	t.Run("transport error", func(t *testing.T) {
+		f := NewAlertWebhookFacade(resty.New())
+		err := f.Notify(context.Background(), "http://127.0.0.1:0", alert)
+		assert.Error(t, err)
+	})
+}
@@ -2,10 +2,16 @@ package http
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/go-resty/resty/v2"
+	hashers "github.com/sbilibin2017/gophmetrics/internal/configs/hasher"
 	"github.com/sbilibin2017/gophmetrics/internal/models"
 )
 
@@ -17,6 +23,14 @@ type Hasher interface {
 	Hash(data []byte) string
 }
 
+// KeySigner is implemented by hashers that sign with a key from the server's
+// keyring. The key ID is sent in the hash header suffixed with "-KeyID".
+type KeySigner interface {
+	// Sign returns the hash of data and the ID of the key used, or "" for a
+	// key without an ID.
+	Sign(data []byte) (hash, keyID string)
+}
+
 type Cryptor interface {
 	Encrypt(data []byte) ([]byte, error)
 }
@@ -29,20 +43,19 @@ type MetricHTTPFacade struct {
 	cryptor    Cryptor
 	header     string
 	endpoint   string
-	ip         string // добавлено поле для IP
+	ip         string // agent IP sent in X-Real-IP
 }
 
 // NewMetricHTTPFacade creates a new MetricHTTPFacade with the given REST client,
-// compressor, hasher, cryptor, and optional key/header for hash header.
+// compressor, hasher, cryptor, and optional header for the hash.
 func NewMetricHTTPFacade(
 	client *resty.Client,
 	compressor Compressor,
 	hasher Hasher,
 	cryptor Cryptor,
-	key string,
 	header string,
 	endpoint string,
-	ip string, // IP агента передается сюда
+	ip string, // sent in X-Real-IP unless empty
 ) *MetricHTTPFacade {
 	return &MetricHTTPFacade{
 		client:     client,
@@ -51,12 +64,13 @@ func NewMetricHTTPFacade(
 		cryptor:    cryptor,
 		header:     header,
 		endpoint:   endpoint,
-		ip:         ip, // сохранили IP в структуре
+		ip:         ip,
 	}
 }
 
 // Update sends metric updates using JSON marshaling, gzip compression,
-// optional encryption, and adds hash header computed on raw JSON.
+// optional encryption, and adds hash header computed on raw JSON signed with
+// a timestamp and nonce.
 func (f *MetricHTTPFacade) Update(ctx context.Context, metrics []*models.Metrics) error {
 	jsonData, err := json.Marshal(metrics)
 	if err != nil {
@@ -86,8 +100,30 @@ func (f *MetricHTTPFacade) Update(ctx context.Context, metrics []*models.Metrics
 	}
 
 	if f.header != "" && f.hasher != nil {
-		hash := f.hasher.Hash(jsonData)
-		req.SetHeader(f.header, hash)
+		// The timestamp and nonce are signed with the body so the server
+		// can reject stale and replayed requests.
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		nonce, err := newNonce()
+		if err != nil {
+			return err
+		}
+		signed := hashers.Message(timestamp, nonce, jsonData)
+
+		var hash, keyID string
+		if signer, ok := f.hasher.(KeySigner); ok {
+			hash, keyID = signer.Sign(signed)
+		} else {
+			hash = f.hasher.Hash(signed)
+		}
+		// An empty hash means hashing is currently disabled.
+		if hash != "" {
+			req.SetHeader(f.header, hash)
+			req.SetHeader(f.header+"-Timestamp", timestamp)
+			req.SetHeader(f.header+"-Nonce", nonce)
+			if keyID != "" {
+				req.SetHeader(f.header+"-KeyID", keyID)
+			}
+		}
 	}
 
 	resp, err := req.Post(f.endpoint)
@@ -96,8 +132,103 @@ func (f *MetricHTTPFacade) Update(ctx context.Context, metrics []*models.Metrics
 	}
 
 	if resp.IsError() {
-		return fmt.Errorf("server responded with status %d", resp.StatusCode())
+		return &StatusError{
+			Code:       resp.StatusCode(),
+			RetryAfter: resp.Header().Get("Retry-After") != "",
+		}
 	}
 
 	return nil
 }
+
+// StatusError is returned by Update when the server answers with an error
+// status.
+type StatusError struct {
+	Code int
+	// RetryAfter is set if the server told when to retry.
+	RetryAfter bool
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("server responded with status %d", e.Code)
+}
+
+// Permanent reports whether sending the same batch again would fail too:
+// the server refused the request itself with a 4xx status. A 429 is only
+// temporary with Retry-After, as sent by the rate limit; without it the
+// client is over its metric quota.
+func (e *StatusError) Permanent() bool {
+	switch e.Code {
+	case http.StatusRequestTimeout:
+		return false
+	case http.StatusTooManyRequests:
+		return !e.RetryAfter
+	}
+	return e.Code >= 400 && e.Code < 500
+}
+
+// newNonce returns a random hex-encoded nonce.
+func newNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// MetricListHTTPFacade reads all metrics from a running server.
+type MetricListHTTPFacade struct {
+	client   *resty.Client
+	endpoint string
+}
+
+// NewMetricListHTTPFacade creates a new MetricListHTTPFacade requesting the
+// metric list from endpoint.
+func NewMetricListHTTPFacade(client *resty.Client, endpoint string) *MetricListHTTPFacade {
+	return &MetricListHTTPFacade{client: client, endpoint: endpoint}
+}
+
+// Walk fetches the metric list and calls fn for each metric as it is decoded
+// from the JSON array in the response body, without holding the whole list
+// in memory.
+func (f *MetricListHTTPFacade) Walk(ctx context.Context, fn func(*models.Metrics) error) error {
+	resp, err := f.client.R().
+		SetContext(ctx).
+		SetHeader("Accept", "application/json").
+		SetDoNotParseResponse(true).
+		Get(f.endpoint)
+	if err != nil {
+		return err
+	}
+	body := resp.RawBody()
+	defer body.Close()
+
+	if resp.IsError() {
+		return fmt.Errorf("server responded with status %d", resp.StatusCode())
+	}
+
+	dec := json.NewDecoder(body)
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok == nil {
+		return nil // null for no metrics
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return fmt.Errorf("unexpected %v, expected a JSON array of metrics", tok)
+	}
+
+	for dec.More() {
+		var metric models.Metrics
+		if err := dec.Decode(&metric); err != nil {
+			return err
+		}
+		if err := fn(&metric); err != nil {
+			return err
+		}
+	}
+
+	_, err = dec.Token()
+	return err
+}
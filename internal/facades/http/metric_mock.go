@@ -1,5 +1,5 @@
 // Code generated by MockGen. DO NOT EDIT.
-// Source: /home/sergey/Github/gophmetrics/internal/facades/http/metric.go
+// Source: internal/facades/http/metric.go
 
 // Package http is a generated GoMock package.
 package http
@@ -85,6 +85,44 @@ func (mr *MockHasherMockRecorder) Hash(data interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Hash", reflect.TypeOf((*MockHasher)(nil).Hash), data)
 }
 
+// MockKeySigner is a mock of KeySigner interface.
+type MockKeySigner struct {
+	ctrl     *gomock.Controller
+	recorder *MockKeySignerMockRecorder
+}
+
+// MockKeySignerMockRecorder is the mock recorder for MockKeySigner.
+type MockKeySignerMockRecorder struct {
+	mock *MockKeySigner
+}
+
+// NewMockKeySigner creates a new mock instance.
+func NewMockKeySigner(ctrl *gomock.Controller) *MockKeySigner {
+	mock := &MockKeySigner{ctrl: ctrl}
+	mock.recorder = &MockKeySignerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockKeySigner) EXPECT() *MockKeySignerMockRecorder {
+	return m.recorder
+}
+
+// Sign mocks base method.
+func (m *MockKeySigner) Sign(data []byte) (string, string) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Sign", data)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(string)
+	return ret0, ret1
+}
+
+// Sign indicates an expected call of Sign.
+func (mr *MockKeySignerMockRecorder) Sign(data interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Sign", reflect.TypeOf((*MockKeySigner)(nil).Sign), data)
+}
+
 // MockCryptor is a mock of Cryptor interface.
 type MockCryptor struct {
 	ctrl     *gomock.Controller
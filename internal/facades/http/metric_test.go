@@ -2,15 +2,19 @@ package http
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net"
 	"net/http"
+	"net/http/httptest"
+	"strconv"
 	"testing"
 	"time"
 
 	"github.com/go-resty/resty/v2"
 	"github.com/golang/mock/gomock"
+	"github.com/sbilibin2017/gophmetrics/internal/configs/hasher"
 	"github.com/sbilibin2017/gophmetrics/internal/models"
 	"github.com/stretchr/testify/assert"
 )
@@ -43,6 +47,11 @@ func (h *headerCheckRoundTripper) RoundTrip(req *http.Request) (*http.Response,
 	}, nil
 }
 
+// noopCompressor sends the data as is.
+type noopCompressor struct{}
+
+func (noopCompressor) Compress(data []byte) ([]byte, error) { return data, nil }
+
 // badMetric forces JSON marshal error.
 type badMetric struct{}
 
@@ -108,6 +117,14 @@ func TestMetricHTTPFacade_Update(t *testing.T) {
 			expectError:    false,
 			metricsInput:   metrics,
 		},
+		{
+			name:           "success with hashing disabled by empty hash",
+			useHasher:      true,
+			hashReturn:     "",
+			httpStatusCode: 200,
+			expectError:    false,
+			metricsInput:   metrics,
+		},
 		{
 			name:         "compress error",
 			compressErr:  errors.New("compress failed"),
@@ -169,7 +186,6 @@ func TestMetricHTTPFacade_Update(t *testing.T) {
 				mockCompressor,
 				hasher,
 				cryptor,
-				"key",       // dummy key
 				"X-Hash",    // header name for hash
 				"/update/",  // endpoint
 				"127.0.0.1", // IP адрес агента
@@ -220,11 +236,193 @@ func TestMetricHTTPFacade_Update_PostError(t *testing.T) {
 		nil, // no hasher
 		nil, // no cryptor
 		"",
-		"",
 		"/update",
 		"127.0.0.1", // IP адрес агента
 	)
 
 	err = facade.Update(context.Background(), metrics)
 	assert.Error(t, err)
+	var statusErr *StatusError
+	assert.False(t, errors.As(err, &statusErr), "connection errors are temporary")
+}
+
+func TestMetricHTTPFacade_Update_StatusError(t *testing.T) {
+	tests := []struct {
+		name       string
+		code       int
+		retryAfter string
+		permanent  bool
+	}{
+		{name: "server error", code: http.StatusServiceUnavailable},
+		{name: "timeout", code: http.StatusRequestTimeout},
+		{name: "rate limit", code: http.StatusTooManyRequests, retryAfter: "1"},
+		{name: "metric quota", code: http.StatusTooManyRequests, permanent: true},
+		{name: "too large", code: http.StatusRequestEntityTooLarge, permanent: true},
+		{name: "unauthorized", code: http.StatusUnauthorized, permanent: true},
+		{name: "forbidden", code: http.StatusForbidden, permanent: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(tt.code)
+			}))
+			defer srv.Close()
+
+			facade := NewMetricHTTPFacade(resty.New().SetBaseURL(srv.URL), noopCompressor{}, nil, nil, "", "/updates/", "")
+			err := facade.Update(context.Background(), []*models.Metrics{})
+
+			var statusErr *StatusError
+			if assert.ErrorAs(t, err, &statusErr) {
+				assert.Equal(t, tt.code, statusErr.Code)
+				assert.Equal(t, tt.permanent, statusErr.Permanent())
+			}
+		})
+	}
+}
+
+func TestMetricListHTTPFacade_Walk(t *testing.T) {
+	walk := func(url string) ([]*models.Metrics, error) {
+		var metrics []*models.Metrics
+		facade := NewMetricListHTTPFacade(resty.New().SetBaseURL(url), "/")
+		err := facade.Walk(context.Background(), func(m *models.Metrics) error {
+			metrics = append(metrics, m)
+			return nil
+		})
+		return metrics, err
+	}
+
+	t.Run("success", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodGet, r.Method)
+			assert.Equal(t, "/", r.URL.Path)
+			assert.Equal(t, "application/json", r.Header.Get("Accept"))
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`[{"id":"Alloc","type":"gauge","value":1.5},{"id":"PollCount","type":"counter","delta":3}]`))
+		}))
+		defer srv.Close()
+
+		metrics, err := walk(srv.URL)
+		assert.NoError(t, err)
+		if assert.Len(t, metrics, 2) {
+			assert.Equal(t, "Alloc", metrics[0].ID)
+			assert.Equal(t, 1.5, *metrics[0].Value)
+			assert.Equal(t, int64(3), *metrics[1].Delta)
+		}
+	})
+
+	t.Run("no metrics", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`null`))
+		}))
+		defer srv.Close()
+
+		metrics, err := walk(srv.URL)
+		assert.NoError(t, err)
+		assert.Empty(t, metrics)
+	})
+
+	t.Run("not an array", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"id":"Alloc"}`))
+		}))
+		defer srv.Close()
+
+		_, err := walk(srv.URL)
+		assert.EqualError(t, err, "unexpected {, expected a JSON array of metrics")
+	})
+
+	t.Run("truncated body", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`[{"id":"Alloc","type":"gauge","value":1.5},{"id":`))
+		}))
+		defer srv.Close()
+
+		metrics, err := walk(srv.URL)
+		assert.Error(t, err)
+		assert.Len(t, metrics, 1)
+	})
+
+	t.Run("error status", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer srv.Close()
+
+		_, err := walk(srv.URL)
+		assert.EqualError(t, err, "server responded with status 500")
+	})
+}
+
+func TestMetricHTTPFacade_Update_KeyID(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockCompressor := NewMockCompressor(ctrl)
+	mockCompressor.EXPECT().Compress(gomock.Any()).Return([]byte("compressed"), nil).Times(2)
+
+	var headers http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	signer := hasher.NewReloadable("")
+	signer.SetSigningKey("agent-1", "secret")
+	facade := NewMetricHTTPFacade(resty.New().SetBaseURL(srv.URL), mockCompressor, signer, nil, "HashSHA256", "/updates/", "")
+
+	delta := int64(1)
+	metrics := []*models.Metrics{{ID: "c", MType: models.Counter, Delta: &delta}}
+	body, err := json.Marshal(metrics)
+	assert.NoError(t, err)
+
+	signed := func() []byte {
+		return hasher.Message(headers.Get("HashSHA256-Timestamp"), headers.Get("HashSHA256-Nonce"), body)
+	}
+
+	assert.NoError(t, facade.Update(context.Background(), metrics))
+	assert.Equal(t, hasher.New("secret").Hash(signed()), headers.Get("HashSHA256"))
+	assert.Equal(t, "agent-1", headers.Get("HashSHA256-KeyID"))
+
+	signer.SetKey("shared")
+	assert.NoError(t, facade.Update(context.Background(), metrics))
+	assert.Equal(t, hasher.New("shared").Hash(signed()), headers.Get("HashSHA256"))
+	assert.Empty(t, headers.Get("HashSHA256-KeyID"), "shared key has no ID")
+}
+
+func TestMetricHTTPFacade_Update_TimestampNonce(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockCompressor := NewMockCompressor(ctrl)
+	mockCompressor.EXPECT().Compress(gomock.Any()).Return([]byte("compressed"), nil).Times(2)
+
+	var nonces []string
+	var timestamp string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nonces = append(nonces, r.Header.Get("HashSHA256-Nonce"))
+		timestamp = r.Header.Get("HashSHA256-Timestamp")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	facade := NewMetricHTTPFacade(resty.New().SetBaseURL(srv.URL), mockCompressor, hasher.New("secret"), nil, "HashSHA256", "/updates/", "")
+
+	delta := int64(1)
+	metrics := []*models.Metrics{{ID: "c", MType: models.Counter, Delta: &delta}}
+	before := time.Now().Unix()
+	assert.NoError(t, facade.Update(context.Background(), metrics))
+	assert.NoError(t, facade.Update(context.Background(), metrics))
+
+	sec, err := strconv.ParseInt(timestamp, 10, 64)
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, sec, before)
+	if assert.Len(t, nonces, 2) {
+		assert.Len(t, nonces[0], 32)
+		assert.NotEqual(t, nonces[0], nonces[1], "each request gets a new nonce")
+	}
 }
@@ -0,0 +1,208 @@
+package multi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sbilibin2017/gophmetrics/internal/models"
+)
+
+// Policy selects how batches are distributed between endpoints.
+type Policy string
+
+// Supported policies.
+const (
+	// PolicyFailover sends every batch to the first endpoint that accepts it,
+	// in the order the endpoints were given.
+	PolicyFailover Policy = "failover"
+	// PolicyReplicate sends every batch to all endpoints concurrently.
+	PolicyReplicate Policy = "replicate"
+)
+
+// Backoff of failing endpoints.
+const (
+	minBackoff = time.Second
+	maxBackoff = time.Minute
+)
+
+// Errors returned by the facade.
+var (
+	ErrUnknownPolicy = errors.New("unknown address policy")
+	ErrNoEndpoints   = errors.New("no endpoints")
+)
+
+// Updater sends a batch of metrics to a single server.
+type Updater interface {
+	Update(ctx context.Context, metrics []*models.Metrics) error
+}
+
+// Endpoint is a named server updater.
+type Endpoint struct {
+	Name    string
+	Updater Updater
+}
+
+// EndpointHealth is the health of an endpoint as seen by the facade.
+type EndpointHealth struct {
+	Name      string
+	Healthy   bool
+	Failures  int // consecutive failed updates
+	LastError error
+}
+
+// endpointState tracks the health of an endpoint. An endpoint that failed is
+// skipped until its backoff elapses, doubling with every further failure.
+type endpointState struct {
+	Endpoint
+
+	failures  int
+	lastErr   error
+	downUntil time.Time
+}
+
+// MetricMultiFacade sends metrics to several servers according to a policy.
+type MetricMultiFacade struct {
+	policy    Policy
+	endpoints []*endpointState
+	now       func() time.Time
+
+	mu sync.Mutex
+}
+
+// NewMetricMultiFacade creates a facade distributing batches between endpoints.
+func NewMetricMultiFacade(policy Policy, endpoints ...Endpoint) (*MetricMultiFacade, error) {
+	if policy != PolicyFailover && policy != PolicyReplicate {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownPolicy, policy)
+	}
+	if len(endpoints) == 0 {
+		return nil, ErrNoEndpoints
+	}
+
+	f := &MetricMultiFacade{policy: policy, now: time.Now}
+	for _, e := range endpoints {
+		f.endpoints = append(f.endpoints, &endpointState{Endpoint: e})
+	}
+	return f, nil
+}
+
+// Update sends the batch according to the policy.
+//
+// With PolicyFailover, healthy endpoints are tried first in order, then those
+// backing off; an error is returned when none accepts the batch.
+//
+// With PolicyReplicate, the batch is sent to the healthy endpoints, or to all
+// of them if none is healthy. An error is returned only when all of those
+// fail, so a batch is not resent to the servers that already accepted it;
+// the failing and backing off servers miss the batch.
+func (f *MetricMultiFacade) Update(ctx context.Context, metrics []*models.Metrics) error {
+	if f.policy == PolicyReplicate {
+		return f.replicate(ctx, metrics)
+	}
+	return f.failover(ctx, metrics)
+}
+
+func (f *MetricMultiFacade) failover(ctx context.Context, metrics []*models.Metrics) error {
+	var errs []error
+	for _, e := range f.candidates() {
+		err := e.Updater.Update(ctx, metrics)
+		f.record(e, err)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", e.Name, err))
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (f *MetricMultiFacade) replicate(ctx context.Context, metrics []*models.Metrics) error {
+	targets, _ := f.split()
+	if len(targets) == 0 {
+		targets = f.endpoints
+	}
+	errs := make([]error, len(targets))
+
+	var wg sync.WaitGroup
+	for i, e := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := e.Updater.Update(ctx, metrics)
+			f.record(e, err)
+			if err != nil {
+				errs[i] = fmt.Errorf("%s: %w", e.Name, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err == nil {
+			return nil
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// candidates returns the endpoints in the order to try them: healthy ones
+// first, then those backing off.
+func (f *MetricMultiFacade) candidates() []*endpointState {
+	healthy, backingOff := f.split()
+	return append(healthy, backingOff...)
+}
+
+// split returns the healthy endpoints and those backing off, in the order
+// they were given.
+func (f *MetricMultiFacade) split() (healthy, backingOff []*endpointState) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := f.now()
+	for _, e := range f.endpoints {
+		if now.Before(e.downUntil) {
+			backingOff = append(backingOff, e)
+		} else {
+			healthy = append(healthy, e)
+		}
+	}
+	return healthy, backingOff
+}
+
+// record updates the health of e after an update.
+func (f *MetricMultiFacade) record(e *endpointState, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err == nil {
+		e.failures, e.lastErr, e.downUntil = 0, nil, time.Time{}
+		return
+	}
+
+	e.failures++
+	e.lastErr = err
+	backoff := minBackoff << min(e.failures-1, 6)
+	e.downUntil = f.now().Add(min(backoff, maxBackoff))
+}
+
+// Health returns the health of every endpoint in the order they were given.
+func (f *MetricMultiFacade) Health() []EndpointHealth {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := f.now()
+	health := make([]EndpointHealth, len(f.endpoints))
+	for i, e := range f.endpoints {
+		health[i] = EndpointHealth{
+			Name:      e.Name,
+			Healthy:   !now.Before(e.downUntil),
+			Failures:  e.failures,
+			LastError: e.lastErr,
+		}
+	}
+	return health
+}
@@ -0,0 +1,142 @@
+package multi
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sbilibin2017/gophmetrics/internal/models"
+)
+
+func newBatch() []*models.Metrics {
+	delta := int64(1)
+	return []*models.Metrics{{ID: "PollCount", MType: models.Counter, Delta: &delta}}
+}
+
+func TestNewMetricMultiFacade(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	_, err := NewMetricMultiFacade("roundrobin", Endpoint{Name: "a", Updater: NewMockUpdater(ctrl)})
+	assert.ErrorIs(t, err, ErrUnknownPolicy)
+
+	_, err = NewMetricMultiFacade(PolicyFailover)
+	assert.ErrorIs(t, err, ErrNoEndpoints)
+}
+
+func TestMetricMultiFacade_Failover(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	primary := NewMockUpdater(ctrl)
+	secondary := NewMockUpdater(ctrl)
+	f, err := NewMetricMultiFacade(PolicyFailover,
+		Endpoint{Name: "primary", Updater: primary},
+		Endpoint{Name: "secondary", Updater: secondary},
+	)
+	require.NoError(t, err)
+
+	now := time.Unix(0, 0)
+	f.now = func() time.Time { return now }
+	ctx := context.Background()
+	batch := newBatch()
+
+	// The primary fails, the secondary takes the batch.
+	gomock.InOrder(
+		primary.EXPECT().Update(ctx, batch).Return(errors.New("down")),
+		secondary.EXPECT().Update(ctx, batch).Return(nil),
+	)
+	require.NoError(t, f.Update(ctx, batch))
+
+	health := f.Health()
+	assert.False(t, health[0].Healthy)
+	assert.Equal(t, 1, health[0].Failures)
+	assert.EqualError(t, health[0].LastError, "down")
+	assert.True(t, health[1].Healthy)
+
+	// While the primary backs off, the secondary is tried first.
+	secondary.EXPECT().Update(ctx, batch).Return(nil)
+	require.NoError(t, f.Update(ctx, batch))
+
+	// After the backoff the primary is preferred again.
+	now = now.Add(minBackoff)
+	primary.EXPECT().Update(ctx, batch).Return(nil)
+	require.NoError(t, f.Update(ctx, batch))
+	assert.Equal(t, 0, f.Health()[0].Failures)
+
+	// All endpoints fail.
+	gomock.InOrder(
+		primary.EXPECT().Update(ctx, batch).Return(errors.New("down")),
+		secondary.EXPECT().Update(ctx, batch).Return(errors.New("unreachable")),
+	)
+	err = f.Update(ctx, batch)
+	assert.ErrorContains(t, err, "primary: down")
+	assert.ErrorContains(t, err, "secondary: unreachable")
+}
+
+func TestMetricMultiFacade_Backoff(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	u := NewMockUpdater(ctrl)
+	f, err := NewMetricMultiFacade(PolicyFailover, Endpoint{Name: "a", Updater: u})
+	require.NoError(t, err)
+
+	now := time.Unix(0, 0)
+	f.now = func() time.Time { return now }
+
+	u.EXPECT().Update(gomock.Any(), gomock.Any()).Return(errors.New("down")).Times(10)
+	for i := 0; i < 10; i++ {
+		assert.Error(t, f.Update(context.Background(), newBatch()), "an endpoint backing off is still tried as a last resort")
+	}
+
+	now = now.Add(maxBackoff - time.Nanosecond)
+	assert.False(t, f.Health()[0].Healthy)
+	now = now.Add(time.Nanosecond)
+	assert.True(t, f.Health()[0].Healthy, "backoff is capped")
+}
+
+func TestMetricMultiFacade_Replicate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	a := NewMockUpdater(ctrl)
+	b := NewMockUpdater(ctrl)
+	f, err := NewMetricMultiFacade(PolicyReplicate,
+		Endpoint{Name: "a", Updater: a},
+		Endpoint{Name: "b", Updater: b},
+	)
+	require.NoError(t, err)
+
+	now := time.Unix(0, 0)
+	f.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	batch := newBatch()
+
+	a.EXPECT().Update(ctx, batch).Return(nil)
+	b.EXPECT().Update(ctx, batch).Return(nil)
+	require.NoError(t, f.Update(ctx, batch))
+
+	a.EXPECT().Update(ctx, batch).Return(nil)
+	b.EXPECT().Update(ctx, batch).Return(errors.New("down"))
+	require.NoError(t, f.Update(ctx, batch), "accepted by one endpoint")
+	assert.False(t, f.Health()[1].Healthy)
+
+	a.EXPECT().Update(ctx, batch).Return(nil)
+	require.NoError(t, f.Update(ctx, batch), "an endpoint backing off is skipped")
+
+	now = now.Add(minBackoff)
+	a.EXPECT().Update(ctx, batch).Return(errors.New("down"))
+	b.EXPECT().Update(ctx, batch).Return(errors.New("down"))
+	assert.Error(t, f.Update(ctx, batch), "retried once its backoff elapses")
+
+	a.EXPECT().Update(ctx, batch).Return(nil)
+	b.EXPECT().Update(ctx, batch).Return(errors.New("down"))
+	require.NoError(t, f.Update(ctx, batch), "all endpoints are tried when none is healthy")
+}
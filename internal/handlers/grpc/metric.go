@@ -2,9 +2,11 @@ package grpc
 
 import (
 	"context"
+	"errors"
 	"strings"
 
 	"github.com/sbilibin2017/gophmetrics/internal/models"
+	"github.com/sbilibin2017/gophmetrics/internal/ratelimit"
 	pb "github.com/sbilibin2017/gophmetrics/pkg/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -76,6 +78,9 @@ func (s *MetricWriteHandler) Update(ctx context.Context, req *pb.UpdateMetricReq
 	}
 
 	updated, err := s.Updater.Update(ctx, metric)
+	if errors.Is(err, ratelimit.ErrQuotaExceeded) {
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -8,8 +8,11 @@ import (
 
 	"github.com/golang/mock/gomock"
 	"github.com/sbilibin2017/gophmetrics/internal/models"
+	"github.com/sbilibin2017/gophmetrics/internal/ratelimit"
 	pb "github.com/sbilibin2017/gophmetrics/pkg/grpc"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/emptypb"
 	"google.golang.org/protobuf/types/known/wrapperspb"
 )
@@ -81,6 +84,24 @@ func TestMetricWriteHandler_Update(t *testing.T) {
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "invalid metric type")
 	})
+
+	t.Run("fail on metric quota", func(t *testing.T) {
+		req := &pb.UpdateMetricRequest{
+			Metric: &pb.Metrics{
+				Id:    "metric2",
+				Mtype: models.Gauge,
+				Value: wrapperspb.Double(1),
+			},
+		}
+
+		mockUpdater.EXPECT().
+			Update(ctx, gomock.Any()).
+			Return(nil, ratelimit.ErrQuotaExceeded)
+
+		resp, err := handler.Update(ctx, req)
+		assert.Nil(t, resp)
+		assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	})
 }
 
 func TestMetricReadHandler_Get(t *testing.T) {
@@ -0,0 +1,38 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/sbilibin2017/gophmetrics/internal/models"
+)
+
+// AgentLister lists all known reporting agents.
+type AgentLister interface {
+	List(ctx context.Context) ([]*models.Agent, error)
+}
+
+// NewAgentListHandler lists all reporting agents with their status.
+//
+// @Summary List agents
+// @Description Returns every reporting source with its last-seen time and status (live, stale or dead)
+// @Tags agents
+// @Accept plain
+// @Produce json
+// @Success 200 {array} models.Agent "Agents"
+// @Failure 500 "Internal Server Error"
+// @Router /agents [get]
+func NewAgentListHandler(lister AgentLister) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		agents, err := lister.List(r.Context())
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(agents)
+	}
+}
@@ -0,0 +1,51 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: internal/handlers/http/agent.go
+
+// Package http is a generated GoMock package.
+package http
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	models "github.com/sbilibin2017/gophmetrics/internal/models"
+)
+
+// MockAgentLister is a mock of AgentLister interface.
+type MockAgentLister struct {
+	ctrl     *gomock.Controller
+	recorder *MockAgentListerMockRecorder
+}
+
+// MockAgentListerMockRecorder is the mock recorder for MockAgentLister.
+type MockAgentListerMockRecorder struct {
+	mock *MockAgentLister
+}
+
+// NewMockAgentLister creates a new mock instance.
+func NewMockAgentLister(ctrl *gomock.Controller) *MockAgentLister {
+	mock := &MockAgentLister{ctrl: ctrl}
+	mock.recorder = &MockAgentListerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAgentLister) EXPECT() *MockAgentListerMockRecorder {
+	return m.recorder
+}
+
+// List mocks base method.
+func (m *MockAgentLister) List(ctx context.Context) ([]*models.Agent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", ctx)
+	ret0, _ := ret[0].([]*models.Agent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockAgentListerMockRecorder) List(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockAgentLister)(nil).List), ctx)
+}
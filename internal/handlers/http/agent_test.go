@@ -0,0 +1,51 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sbilibin2017/gophmetrics/internal/models"
+)
+
+func TestNewAgentListHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockLister := NewMockAgentLister(ctrl)
+	handler := NewAgentListHandler(mockLister)
+
+	t.Run("success", func(t *testing.T) {
+		agents := []*models.Agent{
+			{ID: "10.0.0.1", Status: models.AgentStale, UpdatedAt: time.Now()},
+		}
+		mockLister.EXPECT().List(gomock.Any()).Return(agents, nil)
+
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodGet, "/agents", nil))
+
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+		var got []*models.Agent
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+		assert.Equal(t, agents[0].ID, got[0].ID)
+		assert.Equal(t, agents[0].Status, got[0].Status)
+	})
+
+	t.Run("lister error", func(t *testing.T) {
+		mockLister.EXPECT().List(gomock.Any()).Return(nil, errors.New("fail"))
+
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodGet, "/agents", nil))
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+}
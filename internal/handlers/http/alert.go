@@ -0,0 +1,38 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/sbilibin2017/gophmetrics/internal/models"
+)
+
+// AlertLister lists the current state of all alerts.
+type AlertLister interface {
+	List(ctx context.Context) ([]*models.Alert, error)
+}
+
+// NewAlertListHandler lists all alerts with their current state.
+//
+// @Summary List alerts
+// @Description Returns the current state of every alerting rule
+// @Tags alerts
+// @Accept plain
+// @Produce json
+// @Success 200 {array} models.Alert "Alert states"
+// @Failure 500 "Internal Server Error"
+// @Router /alerts [get]
+func NewAlertListHandler(lister AlertLister) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		alerts, err := lister.List(r.Context())
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(alerts)
+	}
+}
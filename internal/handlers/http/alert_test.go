@@ -0,0 +1,50 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sbilibin2017/gophmetrics/internal/models"
+)
+
+func TestNewAlertListHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockLister := NewMockAlertLister(ctrl)
+	handler := NewAlertListHandler(mockLister)
+
+	t.Run("success", func(t *testing.T) {
+		alerts := []*models.Alert{
+			{Name: "LowMemory", State: models.AlertFiring, Condition: "FreeMemory < 1e+09"},
+		}
+		mockLister.EXPECT().List(gomock.Any()).Return(alerts, nil)
+
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodGet, "/alerts", nil))
+
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+		var got []*models.Alert
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+		assert.Equal(t, alerts[0].Name, got[0].Name)
+		assert.Equal(t, alerts[0].State, got[0].State)
+	})
+
+	t.Run("lister error", func(t *testing.T) {
+		mockLister.EXPECT().List(gomock.Any()).Return(nil, errors.New("fail"))
+
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodGet, "/alerts", nil))
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+}
@@ -1,14 +1,23 @@
 package http
 
 import (
+	"bytes"
 	"context"
+	_ "embed"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
+	"html/template"
+	"io"
+	"math"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/sbilibin2017/gophmetrics/internal/models"
+	"github.com/sbilibin2017/gophmetrics/internal/ratelimit"
 )
 
 // Updater updates metric values.
@@ -16,6 +25,11 @@ type Updater interface {
 	Update(ctx context.Context, metric *models.Metrics) (*models.Metrics, error)
 }
 
+// BatchUpdater updates batches of metric values.
+type BatchUpdater interface {
+	UpdateBatch(ctx context.Context, metrics []*models.Metrics) ([]*models.Metrics, error)
+}
+
 // Getter retrieves a metric.
 type Getter interface {
 	Get(ctx context.Context, id *models.MetricID) (*models.Metrics, error)
@@ -39,6 +53,7 @@ type Lister interface {
 // @Success 200 "OK"
 // @Failure 400 "Bad Request"
 // @Failure 404 "Not Found"
+// @Failure 429 "Too Many Requests"
 // @Failure 500 "Internal Server Error"
 // @Router /update/{type}/{name}/{value} [post]
 func NewMetricUpdatePathHandler(updater Updater) http.HandlerFunc {
@@ -80,7 +95,7 @@ func NewMetricUpdatePathHandler(updater Updater) http.HandlerFunc {
 		}
 
 		if _, err := updater.Update(ctx, &metric); err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
+			w.WriteHeader(updateErrorStatus(err))
 			return
 		}
 
@@ -89,15 +104,18 @@ func NewMetricUpdatePathHandler(updater Updater) http.HandlerFunc {
 }
 
 // NewMetricGetPathHandler retrieves a metric by type and ID.
+// The value is written as plain text unless the client accepts
+// application/json, in which case the whole metric is returned.
 //
 // @Summary Get metric by type and ID
-// @Description Retrieves a metric value or delta as plain text
+// @Description Retrieves a metric value or delta as plain text, or the full metric as JSON when requested via Accept
 // @Tags metrics
 // @Accept plain
 // @Produce plain
+// @Produce json
 // @Param type path string true "Metric type (gauge or counter)"
 // @Param id path string true "Metric ID"
-// @Success 200 {string} string "Metric value as plain text"
+// @Success 200 {object} models.Metrics "Metric value as plain text or full metric as JSON"
 // @Failure 400 "Bad Request"
 // @Failure 404 "Not Found"
 // @Failure 500 "Internal Server Error"
@@ -128,6 +146,13 @@ func NewMetricGetPathHandler(getter Getter) http.HandlerFunc {
 			return
 		}
 
+		if negotiate(r, contentTypePlain, contentTypeJSON) == contentTypeJSON {
+			w.Header().Set("Content-Type", contentTypeJSON)
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(metric)
+			return
+		}
+
 		switch metric.MType {
 		case models.Gauge:
 			if metric.Value == nil {
@@ -147,17 +172,73 @@ func NewMetricGetPathHandler(getter Getter) http.HandlerFunc {
 	}
 }
 
-// NewMetricListHTMLHandler lists all metrics.
+// HistoryReader returns recent values of a metric, oldest first.
+type HistoryReader interface {
+	History(ctx context.Context, id models.MetricID) ([]float64, error)
+}
+
+//go:embed templates/dashboard.html
+var dashboardHTML string
+
+var dashboardTemplate = template.Must(template.New("dashboard").Parse(dashboardHTML))
+
+const (
+	// defaultDashboardRefresh is the auto-refresh interval in seconds used
+	// when the request does not set one.
+	defaultDashboardRefresh = 10
+
+	sparkWidth  = 120
+	sparkHeight = 24
+
+	dashboardTimeFormat = "2006-01-02 15:04:05"
+)
+
+type dashboardRow struct {
+	ID        string
+	MType     string
+	Value     string
+	CreatedAt string
+	UpdatedAt string
+	Stale     bool
+	Sparkline string
+}
+
+type dashboardPage struct {
+	Rows        []dashboardRow
+	Total       int
+	Query       string
+	Type        string
+	Refresh     int
+	GeneratedAt string
+	SparkWidth  int
+	SparkHeight int
+}
+
+// NewMetricListHandler lists all metrics in the format requested by the
+// Accept header: an HTML dashboard (default), a JSON array or a CSV export.
+//
+// The list can be narrowed with the q (name substring) and type query
+// parameters in every format.
+//
+// In the dashboard metrics not updated for staleAfter are marked as stale; a
+// non-positive staleAfter disables the marking. When history is not nil every
+// row gets a sparkline of its recent values. The refresh query parameter sets
+// the auto-refresh interval in seconds, 0 disables it.
 //
 // @Summary List all metrics
-// @Description Returns an HTML page with all metrics in a table
+// @Description Returns all metrics as an HTML dashboard, a JSON array or CSV depending on the Accept header; stale metrics are marked in the dashboard
 // @Tags metrics
 // @Accept plain
 // @Produce html
-// @Success 200 {string} string "HTML table of all metrics"
+// @Produce json
+// @Produce text/csv
+// @Param q query string false "Case-insensitive metric name filter"
+// @Param type query string false "Metric type filter (gauge or counter)"
+// @Param refresh query int false "Dashboard auto-refresh interval in seconds, 0 disables it" default(10)
+// @Success 200 {array} models.Metrics "All metrics as HTML dashboard, JSON array or CSV"
 // @Failure 500 "Internal Server Error"
 // @Router / [get]
-func NewMetricListHTMLHandler(lister Lister) http.HandlerFunc {
+func NewMetricListHandler(lister Lister, staleAfter time.Duration, history HistoryReader) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 
@@ -167,29 +248,169 @@ func NewMetricListHTMLHandler(lister Lister) http.HandlerFunc {
 			return
 		}
 
-		var sb strings.Builder
-		sb.WriteString("<html><body><h1>Metrics List</h1>")
-		sb.WriteString("<table border='1'><tr><th>Name</th><th>Value</th></tr>")
+		query := r.URL.Query()
+		search := strings.TrimSpace(query.Get("q"))
+		mType := query.Get("type")
+		if mType != models.Gauge && mType != models.Counter {
+			mType = ""
+		}
+		filtered := filterMetrics(metrics, search, mType)
+
+		switch negotiate(r, contentTypeHTML, contentTypeJSON, contentTypeCSV) {
+		case contentTypeJSON:
+			w.Header().Set("Content-Type", contentTypeJSON)
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(filtered)
+		case contentTypeCSV:
+			var buf bytes.Buffer
+			if err := writeMetricsCSV(&buf, filtered); err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+			w.Header().Set("Content-Disposition", `attachment; filename="metrics.csv"`)
+			w.Write(buf.Bytes())
+		default:
+			page := dashboardPage{
+				Total:       len(metrics),
+				Query:       search,
+				Type:        mType,
+				Refresh:     defaultDashboardRefresh,
+				SparkWidth:  sparkWidth,
+				SparkHeight: sparkHeight,
+			}
+			if v, err := strconv.Atoi(query.Get("refresh")); err == nil && v >= 0 {
+				page.Refresh = v
+			}
 
-		for _, m := range metrics {
-			val := ""
-			if m.Value != nil {
-				val = strconv.FormatFloat(*m.Value, 'f', -1, 64)
-			} else if m.Delta != nil {
-				val = strconv.FormatInt(*m.Delta, 10)
+			var buf bytes.Buffer
+			if err := renderDashboard(ctx, &buf, page, filtered, staleAfter, history); err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
 			}
-			sb.WriteString("<tr><td>")
-			sb.WriteString(m.ID)
-			sb.WriteString("</td><td>")
-			sb.WriteString(val)
-			sb.WriteString("</td></tr>")
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write(buf.Bytes())
 		}
+	}
+}
 
-		sb.WriteString("</table></body></html>")
+// filterMetrics returns the metrics whose ID contains search (case-insensitive)
+// and whose type equals mType; empty criteria match everything.
+func filterMetrics(metrics []*models.Metrics, search, mType string) []*models.Metrics {
+	needle := strings.ToLower(search)
+	result := make([]*models.Metrics, 0, len(metrics))
+	for _, m := range metrics {
+		if needle != "" && !strings.Contains(strings.ToLower(m.ID), needle) {
+			continue
+		}
+		if mType != "" && m.MType != mType {
+			continue
+		}
+		result = append(result, m)
+	}
+	return result
+}
+
+// writeMetricsCSV writes metrics as CSV with a header row.
+// Timestamps are in RFC 3339, unset values and timestamps are left empty.
+func writeMetricsCSV(w io.Writer, metrics []*models.Metrics) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"id", "type", "value", "delta", "created_at", "updated_at"}); err != nil {
+		return err
+	}
+	for _, m := range metrics {
+		record := make([]string, 6)
+		record[0] = m.ID
+		record[1] = m.MType
+		if m.Value != nil {
+			record[2] = strconv.FormatFloat(*m.Value, 'f', -1, 64)
+		}
+		if m.Delta != nil {
+			record[3] = strconv.FormatInt(*m.Delta, 10)
+		}
+		if !m.CreatedAt.IsZero() {
+			record[4] = m.CreatedAt.Format(time.RFC3339)
+		}
+		if !m.UpdatedAt.IsZero() {
+			record[5] = m.UpdatedAt.Format(time.RFC3339)
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// renderDashboard fills page with rows for metrics and executes the dashboard template.
+func renderDashboard(
+	ctx context.Context,
+	w io.Writer,
+	page dashboardPage,
+	metrics []*models.Metrics,
+	staleAfter time.Duration,
+	history HistoryReader,
+) error {
+	now := time.Now()
+	page.GeneratedAt = now.Format(dashboardTimeFormat)
+
+	for _, m := range metrics {
+		row := dashboardRow{
+			ID:        m.ID,
+			MType:     m.MType,
+			CreatedAt: formatDashboardTime(m.CreatedAt),
+			UpdatedAt: formatDashboardTime(m.UpdatedAt),
+			Stale:     staleAfter > 0 && !m.UpdatedAt.IsZero() && now.Sub(m.UpdatedAt) >= staleAfter,
+		}
+		if m.Value != nil {
+			row.Value = strconv.FormatFloat(*m.Value, 'f', -1, 64)
+		} else if m.Delta != nil {
+			row.Value = strconv.FormatInt(*m.Delta, 10)
+		}
+		if history != nil {
+			values, err := history.History(ctx, models.MetricID{ID: m.ID, MType: m.MType})
+			if err != nil {
+				return err
+			}
+			row.Sparkline = sparkline(values, page.SparkWidth, page.SparkHeight)
+		}
+		page.Rows = append(page.Rows, row)
+	}
+
+	return dashboardTemplate.Execute(w, page)
+}
 
-		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		w.Write([]byte(sb.String()))
+// formatDashboardTime formats t for the dashboard, the zero time is shown as a dash.
+func formatDashboardTime(t time.Time) string {
+	if t.IsZero() {
+		return "—"
+	}
+	return t.Local().Format(dashboardTimeFormat)
+}
+
+// sparkline converts values into SVG polyline points scaled to a width x height box.
+// Fewer than two values produce no line.
+func sparkline(values []float64, width, height int) string {
+	if len(values) < 2 {
+		return ""
+	}
+
+	lo, hi := values[0], values[0]
+	for _, v := range values[1:] {
+		lo = math.Min(lo, v)
+		hi = math.Max(hi, v)
+	}
+
+	step := float64(width) / float64(len(values)-1)
+	points := make([]string, 0, len(values))
+	for i, v := range values {
+		y := float64(height) / 2
+		if hi > lo {
+			y = float64(height) - (v-lo)/(hi-lo)*float64(height)
+		}
+		points = append(points, strconv.FormatFloat(float64(i)*step, 'f', 1, 64)+","+strconv.FormatFloat(y, 'f', 1, 64))
 	}
+	return strings.Join(points, " ")
 }
 
 // NewMetricUpdateBodyHandler creates a handler that updates a metric using JSON payload.
@@ -203,6 +424,7 @@ func NewMetricListHTMLHandler(lister Lister) http.HandlerFunc {
 // @Success 200 {object} models.Metrics "Updated metric returned in response"
 // @Failure 400 "Bad Request"
 // @Failure 404 "Not Found"
+// @Failure 429 "Too Many Requests"
 // @Failure 500 "Internal Server Error"
 // @Router /update/ [post]
 func NewMetricUpdateBodyHandler(updater Updater) http.HandlerFunc {
@@ -227,7 +449,7 @@ func NewMetricUpdateBodyHandler(updater Updater) http.HandlerFunc {
 
 		updatedMetric, err := updater.Update(r.Context(), &metric)
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
+			w.WriteHeader(updateErrorStatus(err))
 			return
 		}
 
@@ -287,6 +509,7 @@ func NewMetricGetBodyHandler(getter Getter) http.HandlerFunc {
 }
 
 // NewMetricUpdatesBodyHandler creates a handler that updates a batch of metrics using a JSON array.
+// The whole batch is validated before any metric is updated.
 //
 // @Summary Save or update multiple metrics (JSON)
 // @Description Updates multiple metrics using a JSON array in request body
@@ -297,11 +520,12 @@ func NewMetricGetBodyHandler(getter Getter) http.HandlerFunc {
 // @Success 200 "All metrics updated successfully"
 // @Failure 400 "Bad Request"
 // @Failure 404 "Not Found"
+// @Failure 429 "Too Many Requests"
 // @Failure 500 "Internal Server Error"
 // @Router /updates/ [post]
-func NewMetricUpdatesBodyHandler(updater Updater) http.HandlerFunc {
+func NewMetricUpdatesBodyHandler(updater BatchUpdater) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		var metrics []models.Metrics
+		var metrics []*models.Metrics
 		dec := json.NewDecoder(r.Body)
 		defer r.Body.Close()
 
@@ -312,7 +536,7 @@ func NewMetricUpdatesBodyHandler(updater Updater) http.HandlerFunc {
 
 		for _, metric := range metrics {
 			// Inline validation of ID and MType
-			if strings.TrimSpace(metric.ID) == "" {
+			if metric == nil || strings.TrimSpace(metric.ID) == "" {
 				w.WriteHeader(http.StatusNotFound)
 				return
 			}
@@ -320,13 +544,22 @@ func NewMetricUpdatesBodyHandler(updater Updater) http.HandlerFunc {
 				w.WriteHeader(http.StatusBadRequest)
 				return
 			}
+		}
 
-			if _, err := updater.Update(r.Context(), &metric); err != nil {
-				w.WriteHeader(http.StatusInternalServerError)
-				return
-			}
+		if _, err := updater.UpdateBatch(r.Context(), metrics); err != nil {
+			w.WriteHeader(updateErrorStatus(err))
+			return
 		}
 
 		w.WriteHeader(http.StatusOK)
 	}
 }
+
+// updateErrorStatus returns the status code of a failed update: 429 if the
+// client is over its metric quota, 500 otherwise.
+func updateErrorStatus(err error) int {
+	if errors.Is(err, ratelimit.ErrQuotaExceeded) {
+		return http.StatusTooManyRequests
+	}
+	return http.StatusInternalServerError
+}
@@ -1,5 +1,5 @@
 // Code generated by MockGen. DO NOT EDIT.
-// Source: /home/sergey/Github/gophmetrics/internal/handlers/http/metric.go
+// Source: internal/handlers/http/metric.go
 
 // Package http is a generated GoMock package.
 package http
@@ -50,6 +50,44 @@ func (mr *MockUpdaterMockRecorder) Update(ctx, metric interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockUpdater)(nil).Update), ctx, metric)
 }
 
+// MockBatchUpdater is a mock of BatchUpdater interface.
+type MockBatchUpdater struct {
+	ctrl     *gomock.Controller
+	recorder *MockBatchUpdaterMockRecorder
+}
+
+// MockBatchUpdaterMockRecorder is the mock recorder for MockBatchUpdater.
+type MockBatchUpdaterMockRecorder struct {
+	mock *MockBatchUpdater
+}
+
+// NewMockBatchUpdater creates a new mock instance.
+func NewMockBatchUpdater(ctrl *gomock.Controller) *MockBatchUpdater {
+	mock := &MockBatchUpdater{ctrl: ctrl}
+	mock.recorder = &MockBatchUpdaterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockBatchUpdater) EXPECT() *MockBatchUpdaterMockRecorder {
+	return m.recorder
+}
+
+// UpdateBatch mocks base method.
+func (m *MockBatchUpdater) UpdateBatch(ctx context.Context, metrics []*models.Metrics) ([]*models.Metrics, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateBatch", ctx, metrics)
+	ret0, _ := ret[0].([]*models.Metrics)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateBatch indicates an expected call of UpdateBatch.
+func (mr *MockBatchUpdaterMockRecorder) UpdateBatch(ctx, metrics interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateBatch", reflect.TypeOf((*MockBatchUpdater)(nil).UpdateBatch), ctx, metrics)
+}
+
 // MockGetter is a mock of Getter interface.
 type MockGetter struct {
 	ctrl     *gomock.Controller
@@ -125,3 +163,41 @@ func (mr *MockListerMockRecorder) List(ctx interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockLister)(nil).List), ctx)
 }
+
+// MockHistoryReader is a mock of HistoryReader interface.
+type MockHistoryReader struct {
+	ctrl     *gomock.Controller
+	recorder *MockHistoryReaderMockRecorder
+}
+
+// MockHistoryReaderMockRecorder is the mock recorder for MockHistoryReader.
+type MockHistoryReaderMockRecorder struct {
+	mock *MockHistoryReader
+}
+
+// NewMockHistoryReader creates a new mock instance.
+func NewMockHistoryReader(ctrl *gomock.Controller) *MockHistoryReader {
+	mock := &MockHistoryReader{ctrl: ctrl}
+	mock.recorder = &MockHistoryReaderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockHistoryReader) EXPECT() *MockHistoryReaderMockRecorder {
+	return m.recorder
+}
+
+// History mocks base method.
+func (m *MockHistoryReader) History(ctx context.Context, id models.MetricID) ([]float64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "History", ctx, id)
+	ret0, _ := ret[0].([]float64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// History indicates an expected call of History.
+func (mr *MockHistoryReaderMockRecorder) History(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "History", reflect.TypeOf((*MockHistoryReader)(nil).History), ctx, id)
+}
@@ -9,6 +9,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/golang/mock/gomock"
@@ -16,6 +17,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/sbilibin2017/gophmetrics/internal/models"
+	"github.com/sbilibin2017/gophmetrics/internal/ratelimit"
 )
 
 func TestNewMetricUpdatePathHandler(t *testing.T) {
@@ -108,6 +110,18 @@ func TestNewMetricUpdatePathHandler(t *testing.T) {
 			},
 			expectedStatus: http.StatusInternalServerError,
 		},
+		{
+			name:        "metric quota exceeded",
+			metricType:  models.Gauge,
+			metricName:  "metric7",
+			metricValue: "1",
+			mockSetup: func() {
+				mockUpdater.EXPECT().
+					Update(gomock.Any(), gomock.AssignableToTypeOf(&models.Metrics{})).
+					Return(nil, ratelimit.ErrQuotaExceeded).Times(1)
+			},
+			expectedStatus: http.StatusTooManyRequests,
+		},
 	}
 
 	for _, tt := range tests {
@@ -303,7 +317,7 @@ func (e *testError) Error() string {
 	return e.msg
 }
 
-func TestNewMetricListHTMLHandler(t *testing.T) {
+func TestNewMetricListHandler(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
@@ -330,6 +344,19 @@ func TestNewMetricListHTMLHandler(t *testing.T) {
 			wantStatus:  http.StatusOK,
 			wantBodySub: "<table", // basic check for html table
 		},
+		{
+			name: "success_stale_metric_marked",
+			setupMock: func() {
+				val := 1.0
+				mockLister.EXPECT().
+					List(gomock.Any()).
+					Return([]*models.Metrics{
+						{ID: "old", MType: models.Gauge, Value: &val, UpdatedAt: time.Now().Add(-time.Hour)},
+					}, nil)
+			},
+			wantStatus:  http.StatusOK,
+			wantBodySub: `<td class="value">1 (stale)</td>`,
+		},
 		{
 			name: "success_empty_metrics",
 			setupMock: func() {
@@ -338,7 +365,20 @@ func TestNewMetricListHTMLHandler(t *testing.T) {
 					Return([]*models.Metrics{}, nil)
 			},
 			wantStatus:  http.StatusOK,
-			wantBodySub: "Metrics List", // header present even if empty
+			wantBodySub: "No metrics", // placeholder row when empty
+		},
+		{
+			name: "success_id_escaped",
+			setupMock: func() {
+				val := 1.0
+				mockLister.EXPECT().
+					List(gomock.Any()).
+					Return([]*models.Metrics{
+						{ID: "<script>alert(1)</script>", MType: models.Gauge, Value: &val},
+					}, nil)
+			},
+			wantStatus:  http.StatusOK,
+			wantBodySub: "<td>&lt;script&gt;alert(1)&lt;/script&gt;</td>",
 		},
 		{
 			name: "failure_internal_error",
@@ -352,7 +392,7 @@ func TestNewMetricListHTMLHandler(t *testing.T) {
 		},
 	}
 
-	handler := NewMetricListHTMLHandler(mockLister)
+	handler := NewMetricListHandler(mockLister, time.Minute, nil)
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -378,6 +418,215 @@ func TestNewMetricListHTMLHandler(t *testing.T) {
 	}
 }
 
+func TestNewMetricListHandler_Filters(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockLister := NewMockLister(ctrl)
+	handler := NewMetricListHandler(mockLister, 0, nil)
+
+	val := 1.5
+	delta := int64(3)
+	metrics := []*models.Metrics{
+		{ID: "Alloc", MType: models.Gauge, Value: &val},
+		{ID: "HeapAlloc", MType: models.Gauge, Value: &val},
+		{ID: "PollCount", MType: models.Counter, Delta: &delta},
+	}
+
+	tests := []struct {
+		name    string
+		target  string
+		want    []string
+		notWant []string
+	}{
+		{
+			name:    "search by name",
+			target:  "/?q=alloc",
+			want:    []string{"<td>Alloc</td>", "<td>HeapAlloc</td>", "2 of 3 metrics"},
+			notWant: []string{"<td>PollCount</td>"},
+		},
+		{
+			name:    "filter by type",
+			target:  "/?type=counter",
+			want:    []string{"<td>PollCount</td>", `<option value="counter" selected>`},
+			notWant: []string{"<td>Alloc</td>"},
+		},
+		{
+			name:   "default refresh",
+			target: "/",
+			want:   []string{`<meta http-equiv="refresh" content="10">`},
+		},
+		{
+			name:    "refresh disabled",
+			target:  "/?refresh=0",
+			notWant: []string{`http-equiv="refresh"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockLister.EXPECT().List(gomock.Any()).Return(metrics, nil)
+
+			w := httptest.NewRecorder()
+			handler(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			require.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+			for _, sub := range tt.want {
+				assert.Contains(t, w.Body.String(), sub)
+			}
+			for _, sub := range tt.notWant {
+				assert.NotContains(t, w.Body.String(), sub)
+			}
+		})
+	}
+}
+
+func TestNewMetricListHandler_Sparkline(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockLister := NewMockLister(ctrl)
+	mockHistory := NewMockHistoryReader(ctrl)
+	handler := NewMetricListHandler(mockLister, 0, mockHistory)
+
+	val := 2.0
+	metrics := []*models.Metrics{{ID: "Alloc", MType: models.Gauge, Value: &val}}
+
+	t.Run("history rendered", func(t *testing.T) {
+		mockLister.EXPECT().List(gomock.Any()).Return(metrics, nil)
+		mockHistory.EXPECT().
+			History(gomock.Any(), models.MetricID{ID: "Alloc", MType: models.Gauge}).
+			Return([]float64{0, 1, 2}, nil)
+
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `<polyline points="0.0,24.0 60.0,12.0 120.0,0.0"/>`)
+	})
+
+	t.Run("history error", func(t *testing.T) {
+		mockLister.EXPECT().List(gomock.Any()).Return(metrics, nil)
+		mockHistory.EXPECT().History(gomock.Any(), gomock.Any()).Return(nil, errors.New("fail"))
+
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+}
+
+func TestSparkline(t *testing.T) {
+	assert.Equal(t, "", sparkline(nil, 100, 10))
+	assert.Equal(t, "", sparkline([]float64{1}, 100, 10))
+	assert.Equal(t, "0.0,5.0 100.0,5.0", sparkline([]float64{3, 3}, 100, 10))
+	assert.Equal(t, "0.0,10.0 50.0,0.0 100.0,5.0", sparkline([]float64{0, 2, 1}, 100, 10))
+}
+
+func TestNewMetricListHandler_Negotiation(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockLister := NewMockLister(ctrl)
+	handler := NewMetricListHandler(mockLister, time.Minute, nil)
+
+	val := 1.5
+	delta := int64(3)
+	updated := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	metrics := []*models.Metrics{
+		{ID: "Alloc", MType: models.Gauge, Value: &val, CreatedAt: updated, UpdatedAt: updated},
+		{ID: "PollCount", MType: models.Counter, Delta: &delta},
+	}
+
+	t.Run("json", func(t *testing.T) {
+		mockLister.EXPECT().List(gomock.Any()).Return(metrics, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/?type=gauge", nil)
+		req.Header.Set("Accept", "application/json")
+		w := httptest.NewRecorder()
+		handler(w, req)
+
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+		var got []models.Metrics
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+		require.Len(t, got, 1)
+		assert.Equal(t, "Alloc", got[0].ID)
+		assert.Equal(t, val, *got[0].Value)
+	})
+
+	t.Run("json empty", func(t *testing.T) {
+		mockLister.EXPECT().List(gomock.Any()).Return(nil, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept", "application/json")
+		w := httptest.NewRecorder()
+		handler(w, req)
+
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, "[]", w.Body.String())
+	})
+
+	t.Run("csv", func(t *testing.T) {
+		mockLister.EXPECT().List(gomock.Any()).Return(metrics, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept", "text/csv")
+		w := httptest.NewRecorder()
+		handler(w, req)
+
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+		assert.Equal(t, "id,type,value,delta,created_at,updated_at\n"+
+			"Alloc,gauge,1.5,,2024-01-02T03:04:05Z,2024-01-02T03:04:05Z\n"+
+			"PollCount,counter,,3,,\n", w.Body.String())
+	})
+
+	t.Run("html by default", func(t *testing.T) {
+		mockLister.EXPECT().List(gomock.Any()).Return(metrics, nil)
+
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	})
+}
+
+func TestNewMetricGetPathHandler_JSON(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockGetter := NewMockGetter(ctrl)
+	handler := NewMetricGetPathHandler(mockGetter)
+
+	val := 3.14
+	mockGetter.EXPECT().
+		Get(gomock.Any(), &models.MetricID{ID: "Alloc", MType: models.Gauge}).
+		Return(&models.Metrics{ID: "Alloc", MType: models.Gauge, Value: &val}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/value/gauge/Alloc", nil)
+	req.Header.Set("Accept", "application/json")
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("type", models.Gauge)
+	rctx.URLParams.Add("id", "Alloc")
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+	w := httptest.NewRecorder()
+	handler(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	var got models.Metrics
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+	assert.Equal(t, "Alloc", got.ID)
+	assert.Equal(t, models.Gauge, got.MType)
+	assert.Equal(t, val, *got.Value)
+}
+
 func TestNewMetricUpdateBodyHandler(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -652,7 +901,7 @@ func TestNewBatchMetricUpdateHandler_Gomock(t *testing.T) {
 		name               string
 		contentType        string
 		requestBody        interface{}
-		mockSetup          func(m *MockUpdater)
+		mockSetup          func(m *MockBatchUpdater)
 		expectedStatusCode int
 	}
 
@@ -664,11 +913,11 @@ func TestNewBatchMetricUpdateHandler_Gomock(t *testing.T) {
 				{ID: "m1", MType: models.Gauge, Value: float64Ptr(1.23)},
 				{ID: "m2", MType: models.Counter, Delta: int64Ptr(42)},
 			},
-			mockSetup: func(m *MockUpdater) {
-				m.EXPECT().Update(gomock.Any(), gomock.Any()).DoAndReturn(
-					func(ctx context.Context, metric *models.Metrics) (*models.Metrics, error) {
-						return metric, nil
-					}).Times(2)
+			mockSetup: func(m *MockBatchUpdater) {
+				m.EXPECT().UpdateBatch(gomock.Any(), gomock.Len(2)).DoAndReturn(
+					func(ctx context.Context, metrics []*models.Metrics) ([]*models.Metrics, error) {
+						return metrics, nil
+					}).Times(1)
 			},
 			expectedStatusCode: http.StatusOK,
 		},
@@ -676,14 +925,14 @@ func TestNewBatchMetricUpdateHandler_Gomock(t *testing.T) {
 			name:               "invalid content-type",
 			contentType:        "text/plain",
 			requestBody:        nil,
-			mockSetup:          func(m *MockUpdater) {},
+			mockSetup:          func(m *MockBatchUpdater) {},
 			expectedStatusCode: http.StatusBadRequest,
 		},
 		{
 			name:               "invalid json",
 			contentType:        "application/json",
 			requestBody:        "{bad json}",
-			mockSetup:          func(m *MockUpdater) {},
+			mockSetup:          func(m *MockBatchUpdater) {},
 			expectedStatusCode: http.StatusBadRequest,
 		},
 		{
@@ -692,7 +941,7 @@ func TestNewBatchMetricUpdateHandler_Gomock(t *testing.T) {
 			requestBody: []models.Metrics{
 				{ID: "", MType: models.Gauge, Value: float64Ptr(1.0)},
 			},
-			mockSetup:          func(m *MockUpdater) {},
+			mockSetup:          func(m *MockBatchUpdater) {},
 			expectedStatusCode: http.StatusNotFound,
 		},
 		{
@@ -701,7 +950,7 @@ func TestNewBatchMetricUpdateHandler_Gomock(t *testing.T) {
 			requestBody: []models.Metrics{
 				{ID: "m1", MType: "invalid", Value: float64Ptr(1.0)},
 			},
-			mockSetup:          func(m *MockUpdater) {},
+			mockSetup:          func(m *MockBatchUpdater) {},
 			expectedStatusCode: http.StatusBadRequest,
 		},
 		{
@@ -710,16 +959,37 @@ func TestNewBatchMetricUpdateHandler_Gomock(t *testing.T) {
 			requestBody: []models.Metrics{
 				{ID: "m1", MType: models.Gauge, Value: float64Ptr(1.0)},
 			},
-			mockSetup: func(m *MockUpdater) {
-				m.EXPECT().Update(gomock.Any(), gomock.Any()).Return(nil, errors.New("db error")).Times(1)
+			mockSetup: func(m *MockBatchUpdater) {
+				m.EXPECT().UpdateBatch(gomock.Any(), gomock.Any()).Return(nil, errors.New("db error")).Times(1)
 			},
 			expectedStatusCode: http.StatusInternalServerError,
 		},
+		{
+			name:        "invalid metric after valid ones updates nothing",
+			contentType: "application/json",
+			requestBody: []models.Metrics{
+				{ID: "m1", MType: models.Gauge, Value: float64Ptr(1.0)},
+				{ID: "m2", MType: "invalid", Value: float64Ptr(1.0)},
+			},
+			mockSetup:          func(m *MockBatchUpdater) {},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:        "quota exceeded",
+			contentType: "application/json",
+			requestBody: []models.Metrics{
+				{ID: "m1", MType: models.Gauge, Value: float64Ptr(1.0)},
+			},
+			mockSetup: func(m *MockBatchUpdater) {
+				m.EXPECT().UpdateBatch(gomock.Any(), gomock.Any()).Return(nil, ratelimit.ErrQuotaExceeded).Times(1)
+			},
+			expectedStatusCode: http.StatusTooManyRequests,
+		},
 	}
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			mockUpdater := NewMockUpdater(ctrl)
+			mockUpdater := NewMockBatchUpdater(ctrl)
 			tc.mockSetup(mockUpdater)
 
 			handler := NewMetricUpdatesBodyHandler(mockUpdater)
@@ -0,0 +1,74 @@
+package http
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Media types served by the read handlers.
+const (
+	contentTypeHTML  = "text/html"
+	contentTypePlain = "text/plain"
+	contentTypeJSON  = "application/json"
+	contentTypeCSV   = "text/csv"
+)
+
+// negotiate picks the offered media type that best matches the Accept header
+// of the request. The first offer is the default: it is returned when the
+// header is missing or matches none of the offers.
+func negotiate(r *http.Request, offers ...string) string {
+	header := r.Header.Get("Accept")
+	if header == "" {
+		return offers[0]
+	}
+
+	best, bestQ, bestSpecificity := offers[0], 0.0, -1
+	for _, part := range strings.Split(header, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+
+		for _, offer := range offers {
+			specificity := matchMediaType(mediaType, offer)
+			if specificity < 0 {
+				continue
+			}
+			// A higher quality wins; on a tie the more specific range wins,
+			// and the order of offers breaks the remaining ties.
+			if q > bestQ || (q == bestQ && specificity > bestSpecificity) {
+				best, bestQ, bestSpecificity = offer, q, specificity
+			}
+			break
+		}
+	}
+	return best
+}
+
+// matchMediaType reports how specifically the media range matches offer:
+// 2 for an exact match, 1 for type/*, 0 for */* and -1 for no match.
+func matchMediaType(mediaRange, offer string) int {
+	switch {
+	case mediaRange == offer:
+		return 2
+	case mediaRange == "*/*":
+		return 0
+	case strings.HasSuffix(mediaRange, "/*") &&
+		strings.HasPrefix(offer, strings.TrimSuffix(mediaRange, "*")):
+		return 1
+	default:
+		return -1
+	}
+}
@@ -0,0 +1,41 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiate(t *testing.T) {
+	offers := []string{contentTypeHTML, contentTypeJSON, contentTypeCSV}
+
+	tests := []struct {
+		name   string
+		accept string
+		want   string
+	}{
+		{name: "no header", accept: "", want: contentTypeHTML},
+		{name: "exact json", accept: "application/json", want: contentTypeJSON},
+		{name: "exact csv", accept: "text/csv", want: contentTypeCSV},
+		{name: "any", accept: "*/*", want: contentTypeHTML},
+		{name: "type wildcard", accept: "application/*", want: contentTypeJSON},
+		{name: "quality", accept: "text/html;q=0.5, text/csv", want: contentTypeCSV},
+		{name: "specific beats wildcard", accept: "*/*, application/json", want: contentTypeJSON},
+		{name: "browser", accept: "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", want: contentTypeHTML},
+		{name: "zero quality excluded", accept: "application/json;q=0, */*;q=0.1", want: contentTypeHTML},
+		{name: "unsupported falls back", accept: "image/png", want: contentTypeHTML},
+		{name: "malformed ignored", accept: "???, text/csv", want: contentTypeCSV},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+			assert.Equal(t, tt.want, negotiate(r, offers...))
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// AgentIDMetadataKey is the metadata key carrying the identifier of the reporting agent.
+const AgentIDMetadataKey = "x-agent-id"
+
+// writeServicePrefix is the full method prefix of the metric write service.
+const writeServicePrefix = "/metrics.MetricWriteService/"
+
+// AgentTracker records the time a reporting source was last seen.
+type AgentTracker interface {
+	Touch(ctx context.Context, id string) error
+}
+
+// AgentTrackingInterceptor returns a unary server interceptor that records every
+// successful call of the metric write service as a report from the source
+// identified by AgentID.
+// It is meant to run after AuthInterceptor, so that rejected calls cannot add agents.
+//
+// Tracking errors are ignored so that they never affect metric updates.
+func AgentTrackingInterceptor(tracker AgentTracker) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		resp, err := handler(ctx, req)
+		if err == nil && strings.HasPrefix(info.FullMethod, writeServicePrefix) {
+			if id := AgentID(ctx); id != "" {
+				tracker.Touch(ctx, id)
+			}
+		}
+		return resp, err
+	}
+}
+
+// AgentID returns the identifier of the call source: the x-agent-id metadata,
+// the x-real-ip metadata or the host part of the peer address, in that order.
+func AgentID(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get(AgentIDMetadataKey); len(v) > 0 && v[0] != "" {
+			return v[0]
+		}
+		if v := md.Get("x-real-ip"); len(v) > 0 && v[0] != "" {
+			return v[0]
+		}
+	}
+
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
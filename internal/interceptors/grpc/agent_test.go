@@ -0,0 +1,77 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func TestAgentTrackingInterceptor(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockTracker := NewMockAgentTracker(ctrl)
+	interceptor := AgentTrackingInterceptor(mockTracker)
+
+	handler := func(ctx context.Context, req any) (any, error) {
+		return "ok", nil
+	}
+
+	t.Run("write service call is tracked", func(t *testing.T) {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(AgentIDMetadataKey, "agent-1"))
+		mockTracker.EXPECT().Touch(gomock.Any(), "agent-1").Return(nil)
+
+		resp, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/metrics.MetricWriteService/Update"}, handler)
+		assert.NoError(t, err)
+		assert.Equal(t, "ok", resp)
+	})
+
+	t.Run("failed write service call is not tracked", func(t *testing.T) {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(AgentIDMetadataKey, "agent-2"))
+		failing := func(ctx context.Context, req any) (any, error) {
+			return nil, status.Error(codes.InvalidArgument, "bad metric")
+		}
+
+		_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/metrics.MetricWriteService/Update"}, failing)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("read service call is not tracked", func(t *testing.T) {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(AgentIDMetadataKey, "agent-1"))
+
+		resp, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/metrics.MetricReadService/List"}, handler)
+		assert.NoError(t, err)
+		assert.Equal(t, "ok", resp)
+	})
+}
+
+func TestAgentID(t *testing.T) {
+	peerCtx := peer.NewContext(context.Background(), &peer.Peer{
+		Addr: &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 5000},
+	})
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want string
+	}{
+		{"agent id metadata", metadata.NewIncomingContext(peerCtx, metadata.Pairs(AgentIDMetadataKey, "agent-1", "x-real-ip", "10.0.0.1")), "agent-1"},
+		{"real ip metadata", metadata.NewIncomingContext(peerCtx, metadata.Pairs("x-real-ip", "10.0.0.1")), "10.0.0.1"},
+		{"peer address", peerCtx, "127.0.0.1"},
+		{"nothing", context.Background(), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, AgentID(tt.ctx))
+		})
+	}
+}
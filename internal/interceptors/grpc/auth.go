@@ -0,0 +1,71 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/sbilibin2017/gophmetrics/internal/auth"
+	"github.com/sbilibin2017/gophmetrics/internal/ratelimit"
+)
+
+// readServicePrefix is the full method prefix of the metric read service.
+const readServicePrefix = "/metrics.MetricReadService/"
+
+// Authorizer checks that a bearer token grants a scope.
+type Authorizer interface {
+	// Authorize returns the identity of the token for per-client limits, or
+	// "" if authentication is disabled. It returns auth.ErrUnauthenticated
+	// for a missing or unknown token and auth.ErrForbidden for a token
+	// without the scope.
+	Authorize(token string, scope auth.Scope) (string, error)
+}
+
+// AuthInterceptor returns a unary server interceptor that requires a bearer
+// token in the authorization metadata. Calls of the metric write service need
+// the write scope, calls of the read service the read scope and any other
+// calls the admin scope. Calls without a valid token fail with
+// codes.Unauthenticated, tokens lacking the scope with codes.PermissionDenied.
+// Authorized calls are counted against the quota of their token rather than
+// of their address.
+func AuthInterceptor(authorizer Authorizer) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		scope := auth.ScopeAdmin
+		switch {
+		case strings.HasPrefix(info.FullMethod, writeServicePrefix):
+			scope = auth.ScopeWrite
+		case strings.HasPrefix(info.FullMethod, readServicePrefix):
+			scope = auth.ScopeRead
+		}
+
+		var token string
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if v := md.Get("authorization"); len(v) > 0 {
+				token = auth.BearerToken(v[0])
+			}
+		}
+
+		client, err := authorizer.Authorize(token, scope)
+		switch {
+		case err == nil:
+			if client != "" {
+				ctx = ratelimit.WithClient(ctx, client)
+			}
+			return handler(ctx, req)
+		case errors.Is(err, auth.ErrForbidden):
+			return nil, status.Errorf(codes.PermissionDenied, "%s: %s", err, scope)
+		default:
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+	}
+}
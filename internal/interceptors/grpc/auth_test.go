@@ -0,0 +1,59 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/sbilibin2017/gophmetrics/internal/auth"
+	"github.com/sbilibin2017/gophmetrics/internal/ratelimit"
+)
+
+func TestAuthInterceptor(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockAuthorizer := NewMockAuthorizer(ctrl)
+	interceptor := AuthInterceptor(mockAuthorizer)
+
+	handler := func(ctx context.Context, req any) (any, error) {
+		return ratelimit.ClientFromContext(ctx), nil
+	}
+	withToken := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer secret"))
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		method string
+		token  string
+		scope  auth.Scope
+		client string
+		err    error
+		code   codes.Code
+	}{
+		{"write call", withToken, "/metrics.MetricWriteService/Update", "secret", auth.ScopeWrite, "token:1", nil, codes.OK},
+		{"read call", withToken, "/metrics.MetricReadService/List", "secret", auth.ScopeRead, "token:1", nil, codes.OK},
+		{"other call needs admin", withToken, "/grpc.health.v1.Health/Check", "secret", auth.ScopeAdmin, "token:1", nil, codes.OK},
+		{"authentication disabled", context.Background(), "/metrics.MetricWriteService/Update", "", auth.ScopeWrite, "", nil, codes.OK},
+		{"missing token", context.Background(), "/metrics.MetricWriteService/Update", "", auth.ScopeWrite, "", auth.ErrUnauthenticated, codes.Unauthenticated},
+		{"lacking scope", withToken, "/metrics.MetricWriteService/Update", "secret", auth.ScopeWrite, "", auth.ErrForbidden, codes.PermissionDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockAuthorizer.EXPECT().Authorize(tt.token, tt.scope).Return(tt.client, tt.err)
+
+			resp, err := interceptor(tt.ctx, nil, &grpc.UnaryServerInfo{FullMethod: tt.method}, handler)
+			assert.Equal(t, tt.code, status.Code(err))
+			if tt.code == codes.OK {
+				assert.Equal(t, tt.client, resp, "quota counted per token")
+			}
+		})
+	}
+}
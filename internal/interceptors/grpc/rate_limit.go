@@ -0,0 +1,66 @@
+package grpc
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/sbilibin2017/gophmetrics/internal/ratelimit"
+)
+
+// RateLimiter decides whether a client may make another call.
+type RateLimiter interface {
+	// Allow reports whether the call is allowed and, if not, how long the
+	// client should wait before retrying.
+	Allow(client string) (bool, time.Duration)
+}
+
+// RateLimitInterceptor returns a unary server interceptor that limits the
+// calls of each client, identified by its address: the peer address, or the
+// x-real-ip metadata of calls forwarded by one of proxies. Rejected calls fail
+// with codes.ResourceExhausted carrying RetryInfo, which tells them apart from
+// calls over the metric quota.
+//
+// It is meant to run before AuthInterceptor so that unauthenticated calls are
+// limited too. The client address is stored in the call context for
+// per-client quotas; AuthInterceptor replaces it with the token identity.
+func RateLimitInterceptor(limiter RateLimiter, proxies ratelimit.Proxies) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		client := clientAddr(ctx, proxies)
+		if ok, wait := limiter.Allow(client); !ok {
+			st := status.Newf(codes.ResourceExhausted, "rate limit exceeded, retry in %s", wait.Round(time.Millisecond))
+			if detailed, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(wait)}); err == nil {
+				st = detailed
+			}
+			return nil, st.Err()
+		}
+		return handler(ratelimit.WithClient(ctx, client), req)
+	}
+}
+
+// clientAddr returns the address of the caller, taking x-real-ip metadata
+// only from proxies.
+func clientAddr(ctx context.Context, proxies ratelimit.Proxies) string {
+	var remote, realIP string
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		remote = p.Addr.String()
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get("x-real-ip"); len(v) > 0 {
+			realIP = v[0]
+		}
+	}
+	return proxies.ClientAddr(remote, realIP)
+}
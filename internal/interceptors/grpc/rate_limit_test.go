@@ -0,0 +1,71 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/sbilibin2017/gophmetrics/internal/ratelimit"
+)
+
+func TestRateLimitInterceptor(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockLimiter := NewMockRateLimiter(ctrl)
+	proxies, err := ratelimit.ParseProxies([]string{"10.0.0.0/8"})
+	assert.NoError(t, err)
+	interceptor := RateLimitInterceptor(mockLimiter, proxies)
+	info := &grpc.UnaryServerInfo{FullMethod: "/metrics.MetricWriteService/Update"}
+
+	newContext := func(peerAddr string, pairs ...string) context.Context {
+		ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(peerAddr), Port: 5000}})
+		return metadata.NewIncomingContext(ctx, metadata.Pairs(pairs...))
+	}
+	clientOf := func(ctx context.Context, req any) (any, error) {
+		return ratelimit.ClientFromContext(ctx), nil
+	}
+
+	t.Run("allowed call sees the client", func(t *testing.T) {
+		mockLimiter.EXPECT().Allow("203.0.113.7").Return(true, time.Duration(0))
+
+		resp, err := interceptor(newContext("203.0.113.7", AgentIDMetadataKey, "agent-1"), nil, info, clientOf)
+		assert.NoError(t, err)
+		assert.Equal(t, "203.0.113.7", resp, "agent ID is chosen by the client and ignored")
+	})
+
+	t.Run("x-real-ip of a trusted proxy", func(t *testing.T) {
+		mockLimiter.EXPECT().Allow("203.0.113.8").Return(true, time.Duration(0))
+
+		resp, err := interceptor(newContext("10.0.0.1", "x-real-ip", "203.0.113.8"), nil, info, clientOf)
+		assert.NoError(t, err)
+		assert.Equal(t, "203.0.113.8", resp)
+	})
+
+	t.Run("rejected call", func(t *testing.T) {
+		mockLimiter.EXPECT().Allow("203.0.113.7").Return(false, 250*time.Millisecond)
+
+		ctx := newContext("203.0.113.7", "x-real-ip", "203.0.113.99")
+		resp, err := interceptor(ctx, nil, info, func(ctx context.Context, req any) (any, error) {
+			t.Fatal("handler must not be called")
+			return nil, nil
+		})
+		assert.Nil(t, resp)
+		assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+		assert.Contains(t, err.Error(), "retry in 250ms")
+		details := status.Convert(err).Details()
+		if assert.Len(t, details, 1) {
+			assert.Equal(t, 250*time.Millisecond, details[0].(*errdetails.RetryInfo).GetRetryDelay().AsDuration())
+		}
+	})
+}
@@ -0,0 +1,55 @@
+package http
+
+import (
+	"context"
+	"net"
+	"net/http"
+)
+
+// AgentIDHeader is the request header carrying the identifier of the reporting agent.
+const AgentIDHeader = "X-Agent-ID"
+
+// AgentTracker records the time a reporting source was last seen.
+type AgentTracker interface {
+	Touch(ctx context.Context, id string) error
+}
+
+// AgentTrackingMiddleware returns a middleware that records every request
+// answered with a 2xx status as a report from the source identified by
+// AgentID, so that rejected updates cannot add agents. It is meant for the
+// update routes.
+//
+// Tracking errors are ignored so that they never affect metric updates.
+func AgentTrackingMiddleware(tracker AgentTracker) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rw := &responseWriter{
+				ResponseWriter: w,
+				statusCode:     http.StatusOK,
+			}
+			next.ServeHTTP(rw, r)
+
+			if rw.statusCode >= 200 && rw.statusCode < 300 {
+				if id := AgentID(r); id != "" {
+					tracker.Touch(r.Context(), id)
+				}
+			}
+		})
+	}
+}
+
+// AgentID returns the identifier of the request source: the X-Agent-ID header,
+// the X-Real-IP header or the host part of the remote address, in that order.
+func AgentID(r *http.Request) string {
+	if id := r.Header.Get(AgentIDHeader); id != "" {
+		return id
+	}
+	if ip := r.Header.Get("X-Real-IP"); ip != "" {
+		return ip
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
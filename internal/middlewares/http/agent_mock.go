@@ -0,0 +1,49 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: internal/middlewares/http/agent.go
+
+// Package http is a generated GoMock package.
+package http
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockAgentTracker is a mock of AgentTracker interface.
+type MockAgentTracker struct {
+	ctrl     *gomock.Controller
+	recorder *MockAgentTrackerMockRecorder
+}
+
+// MockAgentTrackerMockRecorder is the mock recorder for MockAgentTracker.
+type MockAgentTrackerMockRecorder struct {
+	mock *MockAgentTracker
+}
+
+// NewMockAgentTracker creates a new mock instance.
+func NewMockAgentTracker(ctrl *gomock.Controller) *MockAgentTracker {
+	mock := &MockAgentTracker{ctrl: ctrl}
+	mock.recorder = &MockAgentTrackerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAgentTracker) EXPECT() *MockAgentTrackerMockRecorder {
+	return m.recorder
+}
+
+// Touch mocks base method.
+func (m *MockAgentTracker) Touch(ctx context.Context, id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Touch", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Touch indicates an expected call of Touch.
+func (mr *MockAgentTrackerMockRecorder) Touch(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Touch", reflect.TypeOf((*MockAgentTracker)(nil).Touch), ctx, id)
+}
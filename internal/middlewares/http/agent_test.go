@@ -0,0 +1,74 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAgentTrackingMiddleware(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockTracker := NewMockAgentTracker(ctrl)
+
+	handler := AgentTrackingMiddleware(mockTracker)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("fail") != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+
+	t.Run("successful request is tracked", func(t *testing.T) {
+		mockTracker.EXPECT().Touch(gomock.Any(), "agent-1").Return(nil)
+
+		req := httptest.NewRequest(http.MethodPost, "/updates/", nil)
+		req.Header.Set(AgentIDHeader, "agent-1")
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("failed request is not tracked", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/updates/?fail=1", nil)
+		req.Header.Set(AgentIDHeader, "agent-2")
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestAgentID(t *testing.T) {
+	tests := []struct {
+		name       string
+		agentID    string
+		realIP     string
+		remoteAddr string
+		want       string
+	}{
+		{"agent id header", "agent-1", "10.0.0.1", "127.0.0.1:1234", "agent-1"},
+		{"real ip header", "", "10.0.0.1", "127.0.0.1:1234", "10.0.0.1"},
+		{"remote addr", "", "", "127.0.0.1:1234", "127.0.0.1"},
+		{"remote addr without port", "", "", "unix", "unix"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.agentID != "" {
+				req.Header.Set(AgentIDHeader, tt.agentID)
+			}
+			if tt.realIP != "" {
+				req.Header.Set("X-Real-IP", tt.realIP)
+			}
+			assert.Equal(t, tt.want, AgentID(req))
+		})
+	}
+}
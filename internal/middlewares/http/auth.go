@@ -0,0 +1,43 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/sbilibin2017/gophmetrics/internal/auth"
+	"github.com/sbilibin2017/gophmetrics/internal/ratelimit"
+)
+
+// Authorizer checks that a bearer token grants a scope.
+type Authorizer interface {
+	// Authorize returns the identity of the token for per-client limits, or
+	// "" if authentication is disabled. It returns auth.ErrUnauthenticated
+	// for a missing or unknown token and auth.ErrForbidden for a token
+	// without the scope.
+	Authorize(token string, scope auth.Scope) (string, error)
+}
+
+// AuthMiddleware returns a middleware that requires a bearer token granting
+// scope in the Authorization header. It responds with HTTP 401 Unauthorized
+// to requests without a valid token and with HTTP 403 Forbidden to tokens
+// lacking the scope. Authorized requests are counted against the quota of
+// their token rather than of their address.
+func AuthMiddleware(authorizer Authorizer, scope auth.Scope) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			client, err := authorizer.Authorize(auth.BearerToken(r.Header.Get("Authorization")), scope)
+			switch {
+			case err == nil:
+				if client != "" {
+					r = r.WithContext(ratelimit.WithClient(r.Context(), client))
+				}
+				next.ServeHTTP(w, r)
+			case errors.Is(err, auth.ErrForbidden):
+				w.WriteHeader(http.StatusForbidden)
+			default:
+				w.Header().Set("WWW-Authenticate", `Bearer realm="gophmetrics"`)
+				w.WriteHeader(http.StatusUnauthorized)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sbilibin2017/gophmetrics/internal/auth"
+	"github.com/sbilibin2017/gophmetrics/internal/ratelimit"
+)
+
+func TestAuthMiddleware(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockAuthorizer := NewMockAuthorizer(ctrl)
+
+	var client string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client = ratelimit.ClientFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := AuthMiddleware(mockAuthorizer, auth.ScopeWrite)(next)
+
+	tests := []struct {
+		name   string
+		header string
+		token  string
+		client string
+		err    error
+		want   int
+	}{
+		{"authorized", "Bearer secret", "secret", "token:1", nil, http.StatusOK},
+		{"lowercase scheme", "bearer secret", "secret", "token:1", nil, http.StatusOK},
+		{"authentication disabled", "", "", "", nil, http.StatusOK},
+		{"missing token", "", "", "", auth.ErrUnauthenticated, http.StatusUnauthorized},
+		{"basic auth is ignored", "Basic dXNlcjpwYXNz", "", "", auth.ErrUnauthenticated, http.StatusUnauthorized},
+		{"lacking scope", "Bearer secret", "secret", "", auth.ErrForbidden, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockAuthorizer.EXPECT().Authorize(tt.token, auth.ScopeWrite).Return(tt.client, tt.err)
+
+			client = ""
+			req := httptest.NewRequest(http.MethodPost, "/updates/", nil)
+			req = req.WithContext(ratelimit.WithClient(req.Context(), "203.0.113.7"))
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, req)
+			assert.Equal(t, tt.want, w.Code)
+			if tt.want == http.StatusOK {
+				want := tt.client
+				if want == "" {
+					want = "203.0.113.7"
+				}
+				assert.Equal(t, want, client, "quota counted per token")
+			}
+			if tt.want == http.StatusUnauthorized {
+				assert.Contains(t, w.Header().Get("WWW-Authenticate"), "Bearer")
+			}
+		})
+	}
+}
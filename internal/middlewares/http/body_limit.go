@@ -0,0 +1,49 @@
+package http
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+)
+
+// BodyLimitMiddleware returns a middleware that rejects requests whose body
+// is larger than limit bytes with HTTP 413 Request Entity Too Large.
+// The limit applies to the body as sent, i.e. before decompression;
+// GzipLimitMiddleware applies it to the decompressed body.
+// If limit is not positive, the middleware just passes the request through.
+func BodyLimitMiddleware(limit int64) func(http.Handler) http.Handler {
+	if limit <= 0 {
+		return func(next http.Handler) http.Handler {
+			return next
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > limit {
+				w.WriteHeader(http.StatusRequestEntityTooLarge)
+				return
+			}
+			if r.Body == nil || r.Body == http.NoBody {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// The body is read up front, so an oversized body without a
+			// Content-Length is rejected before any handler sees it.
+			body, err := io.ReadAll(io.LimitReader(r.Body, limit+1))
+			r.Body.Close()
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			if int64(len(body)) > limit {
+				w.WriteHeader(http.StatusRequestEntityTooLarge)
+				return
+			}
+
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
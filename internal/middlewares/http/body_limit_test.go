@@ -0,0 +1,56 @@
+package http
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBodyLimitMiddleware(t *testing.T) {
+	var got string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		got = string(b)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name          string
+		limit         int64
+		body          string
+		chunked       bool
+		wantStatus    int
+		wantForwarded bool
+	}{
+		{name: "disabled", limit: 0, body: "0123456789", wantStatus: http.StatusOK, wantForwarded: true},
+		{name: "within limit", limit: 10, body: "0123456789", wantStatus: http.StatusOK, wantForwarded: true},
+		{name: "content length over limit", limit: 5, body: "0123456789", wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "chunked over limit", limit: 5, body: "0123456789", chunked: true, wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "chunked within limit", limit: 10, body: "0123456789", chunked: true, wantStatus: http.StatusOK, wantForwarded: true},
+		{name: "no body", limit: 5, wantStatus: http.StatusOK, wantForwarded: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = "unset"
+			req := httptest.NewRequest(http.MethodPost, "/updates/", strings.NewReader(tt.body))
+			if tt.chunked {
+				req.ContentLength = -1
+			}
+			w := httptest.NewRecorder()
+
+			BodyLimitMiddleware(tt.limit)(next).ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantForwarded {
+				assert.Equal(t, tt.body, got)
+			} else {
+				assert.Equal(t, "unset", got)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package http
 
 import (
+	"bytes"
 	"compress/gzip"
 	"io"
 	"net/http"
@@ -17,27 +18,52 @@ import (
 // "Content-Encoding: gzip" header in the response.
 //
 // This middleware ensures transparent gzip support for both request and response.
+// It does not limit the size of the decompressed body; see GzipLimitMiddleware.
 func GzipMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Header.Get("Content-Encoding") == "gzip" {
-			gz, err := gzip.NewReader(r.Body)
-			if err != nil {
-				w.WriteHeader(http.StatusInternalServerError)
-				return
+	return GzipLimitMiddleware(0)(next)
+}
+
+// GzipLimitMiddleware returns a GzipMiddleware that rejects requests whose
+// body decompresses to more than limit bytes with HTTP 413 Request Entity Too
+// Large, so that a small compressed body cannot inflate without bound.
+// The decompressed body is read up front, before any handler sees it.
+// If limit is not positive, the decompressed size is unlimited.
+func GzipLimitMiddleware(limit int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Content-Encoding") == "gzip" {
+				gz, err := gzip.NewReader(r.Body)
+				if err != nil {
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				defer gz.Close()
+				r.Body = gz
+
+				if limit > 0 {
+					body, err := io.ReadAll(io.LimitReader(gz, limit+1))
+					if err != nil {
+						w.WriteHeader(http.StatusBadRequest)
+						return
+					}
+					if int64(len(body)) > limit {
+						w.WriteHeader(http.StatusRequestEntityTooLarge)
+						return
+					}
+					r.Body = io.NopCloser(bytes.NewReader(body))
+				}
 			}
-			defer gz.Close()
-			r.Body = gz
-		}
-
-		if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
-			w.Header().Set("Content-Encoding", "gzip")
-			gz := gzip.NewWriter(w)
-			defer gz.Close()
-			w = &gzipResponseWriter{Writer: gz, ResponseWriter: w}
-		}
-
-		next.ServeHTTP(w, r)
-	})
+
+			if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+				w.Header().Set("Content-Encoding", "gzip")
+				gz := gzip.NewWriter(w)
+				defer gz.Close()
+				w = &gzipResponseWriter{Writer: gz, ResponseWriter: w}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
 }
 
 // gzipResponseWriter wraps http.ResponseWriter to provide gzip compression on the response.
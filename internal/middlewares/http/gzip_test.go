@@ -125,3 +125,48 @@ func TestGzipMiddleware(t *testing.T) {
 		})
 	}
 }
+
+func TestGzipLimitMiddleware(t *testing.T) {
+	var forwarded []byte
+	handler := GzipLimitMiddleware(1024)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		forwarded = body
+	}))
+
+	compress := func(data []byte) []byte {
+		var buf bytes.Buffer
+		gzw := gzip.NewWriter(&buf)
+		_, err := gzw.Write(data)
+		require.NoError(t, err)
+		require.NoError(t, gzw.Close())
+		return buf.Bytes()
+	}
+	serve := func(body []byte) int {
+		req := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewReader(body))
+		req.Header.Set("Content-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	t.Run("within limit", func(t *testing.T) {
+		data := bytes.Repeat([]byte("a"), 1024)
+		require.Equal(t, http.StatusOK, serve(compress(data)))
+		require.Equal(t, data, forwarded)
+	})
+
+	t.Run("small body inflating over limit", func(t *testing.T) {
+		forwarded = nil
+		bomb := compress(make([]byte, 256<<10))
+		require.Less(t, len(bomb), 1024, "compressed body is within the limit")
+
+		require.Equal(t, http.StatusRequestEntityTooLarge, serve(bomb))
+		require.Nil(t, forwarded)
+	})
+
+	t.Run("truncated stream", func(t *testing.T) {
+		data := compress(bytes.Repeat([]byte("a"), 100))
+		require.Equal(t, http.StatusBadRequest, serve(data[:len(data)-4]))
+	})
+}
@@ -2,8 +2,13 @@ package http
 
 import (
 	"bytes"
+	"crypto/hmac"
 	"io"
 	"net/http"
+	"strconv"
+	"time"
+
+	hashers "github.com/sbilibin2017/gophmetrics/internal/configs/hasher"
 )
 
 // Hasher is an interface defining a hashing algorithm.
@@ -12,6 +17,55 @@ type Hasher interface {
 	Hash(data []byte) string
 }
 
+// Keyring computes hashes with one of several keys chosen by ID.
+type Keyring interface {
+	// HashWithKey computes the hash of data with the key keyID and reports
+	// whether the key exists.
+	HashWithKey(keyID string, data []byte) (string, bool)
+}
+
+// ReplayGuard rejects stale and replayed signed requests.
+type ReplayGuard interface {
+	// Check returns an error if the request signed at timestamp with nonce
+	// must be rejected. A zero timestamp or empty nonce means the request
+	// has none.
+	Check(timestamp time.Time, nonce string) error
+}
+
+// HashOpt configures HashMiddleware.
+type HashOpt func(*hashOptions)
+
+type hashOptions struct {
+	keyring Keyring
+	replay  ReplayGuard
+}
+
+// WithKeyring checks requests carrying a key ID in the header named like the
+// hash header with a "-KeyID" suffix, e.g. HashSHA256-KeyID, with that key of
+// the keyring instead of the default hasher. Requests with an unknown key ID
+// fail the hash check, and the response is signed with the same key.
+func WithKeyring(keyring Keyring) HashOpt {
+	return func(o *hashOptions) {
+		o.keyring = keyring
+	}
+}
+
+// WithReplayGuard checks signed requests with the guard after their hash is
+// verified, so unsigned requests cannot fill its nonce cache. The timestamp in
+// Unix seconds and the nonce are read from the headers named like the hash
+// header with "-Timestamp" and "-Nonce" suffixes.
+func WithReplayGuard(guard ReplayGuard) HashOpt {
+	return func(o *hashOptions) {
+		o.replay = guard
+	}
+}
+
+// KeyIDHeader returns the name of the header carrying the ID of the key
+// a body is signed with.
+func KeyIDHeader(header string) string {
+	return header + "-KeyID"
+}
+
 // HashMiddleware returns an HTTP middleware that verifies the request body hash
 // against the hash provided in the specified request header. It also computes
 // a hash of the response body and adds it to the same header in the response.
@@ -22,12 +76,21 @@ type Hasher interface {
 // Parameters:
 //   - hasher: an implementation of the Hasher interface used to compute hashes.
 //   - header: the HTTP header name where the hash is expected in the request and set in the response.
+//   - opts: optional settings such as WithKeyring and WithReplayGuard.
 //
 // Behavior:
 //   - Reads the entire request body to verify its hash if the header is present.
 //   - Buffers the response body to compute its hash before sending it to the client.
 //   - Sets the computed hash in the configured header of the response.
-func HashMiddleware(hasher Hasher, header string) func(http.Handler) http.Handler {
+func HashMiddleware(hasher Hasher, header string, opts ...HashOpt) func(http.Handler) http.Handler {
+	var o hashOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	keyIDHeader := KeyIDHeader(header)
+	timestampHeader := header + "-Timestamp"
+	nonceHeader := header + "-Nonce"
+
 	return func(next http.Handler) http.Handler {
 		if hasher == nil {
 			return next
@@ -42,13 +105,51 @@ func HashMiddleware(hasher Hasher, header string) func(http.Handler) http.Handle
 			r.Body.Close()
 			r.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
 
+			// hash signs with the key the request names, if any. An unknown
+			// key gives an empty hash, which matches no received hash.
+			hash := hasher.Hash
+			keyID := r.Header.Get(keyIDHeader)
+			if o.keyring == nil {
+				keyID = ""
+			}
+			if keyID != "" {
+				hash = func(data []byte) string {
+					h, _ := o.keyring.HashWithKey(keyID, data)
+					return h
+				}
+			}
+
 			receivedHash := r.Header.Get(header)
 			if receivedHash != "" {
-				expectedHash := hasher.Hash(bodyBytes)
-				if expectedHash != receivedHash {
+				// A timestamp and nonce, if sent, are signed with the body.
+				timestamp := r.Header.Get(timestampHeader)
+				nonce := r.Header.Get(nonceHeader)
+				signed := bodyBytes
+				if timestamp != "" || nonce != "" {
+					signed = hashers.Message(timestamp, nonce, bodyBytes)
+				}
+
+				expectedHash := hash(signed)
+				if expectedHash == "" || !hmac.Equal([]byte(expectedHash), []byte(receivedHash)) {
 					w.WriteHeader(http.StatusBadRequest)
 					return
 				}
+
+				if o.replay != nil {
+					var signedAt time.Time
+					if timestamp != "" {
+						sec, err := strconv.ParseInt(timestamp, 10, 64)
+						if err != nil {
+							w.WriteHeader(http.StatusBadRequest)
+							return
+						}
+						signedAt = time.Unix(sec, 0)
+					}
+					if err := o.replay.Check(signedAt, nonce); err != nil {
+						w.WriteHeader(http.StatusBadRequest)
+						return
+					}
+				}
 			}
 
 			rw := &responseWriterWithHash{
@@ -59,9 +160,12 @@ func HashMiddleware(hasher Hasher, header string) func(http.Handler) http.Handle
 			next.ServeHTTP(rw, r)
 
 			responseBody := rw.buf.Bytes()
-			respHash := hasher.Hash(responseBody)
-
-			w.Header().Set(header, respHash)
+			if respHash := hash(responseBody); respHash != "" {
+				w.Header().Set(header, respHash)
+				if keyID != "" {
+					w.Header().Set(keyIDHeader, keyID)
+				}
+			}
 			w.Write(responseBody)
 		})
 	}
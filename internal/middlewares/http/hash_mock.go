@@ -1,11 +1,12 @@
 // Code generated by MockGen. DO NOT EDIT.
-// Source: /home/sergey/Github/gophmetrics/internal/middlewares/http/hash.go
+// Source: internal/middlewares/http/hash.go
 
 // Package http is a generated GoMock package.
 package http
 
 import (
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 )
@@ -46,3 +47,78 @@ func (mr *MockHasherMockRecorder) Hash(data interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Hash", reflect.TypeOf((*MockHasher)(nil).Hash), data)
 }
+
+// MockKeyring is a mock of Keyring interface.
+type MockKeyring struct {
+	ctrl     *gomock.Controller
+	recorder *MockKeyringMockRecorder
+}
+
+// MockKeyringMockRecorder is the mock recorder for MockKeyring.
+type MockKeyringMockRecorder struct {
+	mock *MockKeyring
+}
+
+// NewMockKeyring creates a new mock instance.
+func NewMockKeyring(ctrl *gomock.Controller) *MockKeyring {
+	mock := &MockKeyring{ctrl: ctrl}
+	mock.recorder = &MockKeyringMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockKeyring) EXPECT() *MockKeyringMockRecorder {
+	return m.recorder
+}
+
+// HashWithKey mocks base method.
+func (m *MockKeyring) HashWithKey(keyID string, data []byte) (string, bool) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HashWithKey", keyID, data)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(bool)
+	return ret0, ret1
+}
+
+// HashWithKey indicates an expected call of HashWithKey.
+func (mr *MockKeyringMockRecorder) HashWithKey(keyID, data interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HashWithKey", reflect.TypeOf((*MockKeyring)(nil).HashWithKey), keyID, data)
+}
+
+// MockReplayGuard is a mock of ReplayGuard interface.
+type MockReplayGuard struct {
+	ctrl     *gomock.Controller
+	recorder *MockReplayGuardMockRecorder
+}
+
+// MockReplayGuardMockRecorder is the mock recorder for MockReplayGuard.
+type MockReplayGuardMockRecorder struct {
+	mock *MockReplayGuard
+}
+
+// NewMockReplayGuard creates a new mock instance.
+func NewMockReplayGuard(ctrl *gomock.Controller) *MockReplayGuard {
+	mock := &MockReplayGuard{ctrl: ctrl}
+	mock.recorder = &MockReplayGuardMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockReplayGuard) EXPECT() *MockReplayGuardMockRecorder {
+	return m.recorder
+}
+
+// Check mocks base method.
+func (m *MockReplayGuard) Check(timestamp time.Time, nonce string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Check", timestamp, nonce)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Check indicates an expected call of Check.
+func (mr *MockReplayGuardMockRecorder) Check(timestamp, nonce interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Check", reflect.TypeOf((*MockReplayGuard)(nil).Check), timestamp, nonce)
+}
@@ -2,12 +2,18 @@ package http
 
 import (
 	"bytes"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
+	"github.com/sbilibin2017/gophmetrics/internal/config"
+	hashers "github.com/sbilibin2017/gophmetrics/internal/configs/hasher"
+	"github.com/sbilibin2017/gophmetrics/internal/replay"
 	"github.com/stretchr/testify/require"
 )
 
@@ -108,3 +114,186 @@ func (e *errorReader) Read([]byte) (int, error) {
 func (e *errorReader) Close() error {
 	return nil
 }
+
+func TestHashMiddleware_WithKeyring(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockHasher := NewMockHasher(ctrl)
+	mockKeyring := NewMockKeyring(ctrl)
+
+	const header = "HashSHA256"
+	body := []byte("body")
+	response := []byte("response")
+
+	mw := HashMiddleware(mockHasher, header, WithKeyring(mockKeyring))
+	handler := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(response)
+	}))
+
+	t.Run("request signed with a keyring key", func(t *testing.T) {
+		mockKeyring.EXPECT().HashWithKey("agent-1", body).Return("agent-hash", true)
+		mockKeyring.EXPECT().HashWithKey("agent-1", response).Return("response-hash", true)
+
+		req := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewReader(body))
+		req.Header.Set(header, "agent-hash")
+		req.Header.Set(KeyIDHeader(header), "agent-1")
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Equal(t, "response-hash", rec.Header().Get(header))
+		require.Equal(t, "agent-1", rec.Header().Get("HashSHA256-KeyID"))
+	})
+
+	t.Run("hash of another key is rejected", func(t *testing.T) {
+		mockKeyring.EXPECT().HashWithKey("agent-1", body).Return("agent-hash", true)
+
+		req := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewReader(body))
+		req.Header.Set(header, "shared-hash")
+		req.Header.Set(KeyIDHeader(header), "agent-1")
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("unknown key is rejected", func(t *testing.T) {
+		mockKeyring.EXPECT().HashWithKey("agent-2", body).Return("", false)
+
+		req := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewReader(body))
+		req.Header.Set(header, "agent-hash")
+		req.Header.Set(KeyIDHeader(header), "agent-2")
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("request without key ID uses the shared key", func(t *testing.T) {
+		mockHasher.EXPECT().Hash(body).Return("shared-hash")
+		mockHasher.EXPECT().Hash(response).Return("shared-response-hash")
+
+		req := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewReader(body))
+		req.Header.Set(header, "shared-hash")
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Equal(t, "shared-response-hash", rec.Header().Get(header))
+		require.Empty(t, rec.Header().Get("HashSHA256-KeyID"))
+	})
+}
+
+func TestHashMiddleware_WithReplayGuard(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockHasher := NewMockHasher(ctrl)
+	mockGuard := NewMockReplayGuard(ctrl)
+
+	const header = "HashSHA256"
+	body := []byte("body")
+	signed := hashers.Message("1700000000", "abc", body)
+	response := []byte("response")
+
+	mw := HashMiddleware(mockHasher, header, WithReplayGuard(mockGuard))
+	handler := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(response)
+	}))
+
+	newRequest := func(hash, timestamp, nonce string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewReader(body))
+		req.Header.Set(header, hash)
+		if timestamp != "" {
+			req.Header.Set("HashSHA256-Timestamp", timestamp)
+		}
+		if nonce != "" {
+			req.Header.Set("HashSHA256-Nonce", nonce)
+		}
+		return req
+	}
+
+	t.Run("fresh request is accepted", func(t *testing.T) {
+		mockHasher.EXPECT().Hash(signed).Return("signed-hash")
+		mockGuard.EXPECT().Check(time.Unix(1700000000, 0), "abc").Return(nil)
+		mockHasher.EXPECT().Hash(response).Return("response-hash")
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, newRequest("signed-hash", "1700000000", "abc"))
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Equal(t, "response-hash", rec.Header().Get(header))
+	})
+
+	t.Run("rejected by the guard", func(t *testing.T) {
+		mockHasher.EXPECT().Hash(signed).Return("signed-hash")
+		mockGuard.EXPECT().Check(time.Unix(1700000000, 0), "abc").Return(errors.New("nonce already used"))
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, newRequest("signed-hash", "1700000000", "abc"))
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("invalid hash is rejected before the guard", func(t *testing.T) {
+		mockHasher.EXPECT().Hash(signed).Return("signed-hash")
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, newRequest("forged-hash", "1700000000", "abc"))
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("invalid timestamp is rejected", func(t *testing.T) {
+		mockHasher.EXPECT().Hash(hashers.Message("soon", "abc", body)).Return("signed-hash")
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, newRequest("signed-hash", "soon", "abc"))
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("request without timestamp and nonce is left to the guard", func(t *testing.T) {
+		mockHasher.EXPECT().Hash(body).Return("body-hash")
+		mockGuard.EXPECT().Check(time.Time{}, "").Return(nil)
+		mockHasher.EXPECT().Hash(response).Return("response-hash")
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, newRequest("body-hash", "", ""))
+		require.Equal(t, http.StatusOK, rec.Code)
+	})
+}
+
+func TestHashMiddleware_ReplayDefaults(t *testing.T) {
+	cfg := config.NewServer()
+	h := hashers.New("secret")
+	body := []byte(`[{"id":"c","type":"counter","delta":1}]`)
+
+	newHandler := func(guard *replay.Guard) http.Handler {
+		mw := HashMiddleware(h, "HashSHA256", WithReplayGuard(guard))
+		return mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	}
+	serve := func(handler http.Handler, timestamp, nonce string) int {
+		signed := body
+		if timestamp != "" {
+			signed = hashers.Message(timestamp, nonce, body)
+		}
+		req := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewReader(body))
+		req.Header.Set("HashSHA256", h.Hash(signed))
+		if timestamp != "" {
+			req.Header.Set("HashSHA256-Timestamp", timestamp)
+			req.Header.Set("HashSHA256-Nonce", nonce)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	handler := newHandler(replay.NewGuard(cfg.ReplayWindow, cfg.ReplayCache, replay.WithRequired(cfg.ReplayRequired)))
+	require.Equal(t, http.StatusOK, serve(handler, "", ""), "request signed by an earlier agent")
+	require.Equal(t, http.StatusOK, serve(handler, "", ""), "earlier agents have no nonce to replay")
+
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	require.Equal(t, http.StatusOK, serve(handler, now, "abc"))
+	require.Equal(t, http.StatusBadRequest, serve(handler, now, "abc"), "replayed")
+
+	strict := newHandler(replay.NewGuard(cfg.ReplayWindow, cfg.ReplayCache, replay.WithRequired(true)))
+	require.Equal(t, http.StatusBadRequest, serve(strict, "", ""))
+}
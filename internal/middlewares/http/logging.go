@@ -8,10 +8,21 @@ import (
 	"go.uber.org/zap"
 )
 
-var logger *zap.Logger
+var (
+	logger   *zap.Logger
+	logLevel = zap.NewAtomicLevelAt(zap.InfoLevel)
+)
 
 func init() {
-	logger, _ = zap.NewProduction()
+	cfg := zap.NewProductionConfig()
+	cfg.Level = logLevel
+	logger, _ = cfg.Build()
+}
+
+// SetLogLevel changes the level of the request log, e.g. to "debug" or
+// "warn". It takes effect immediately, also for requests in flight.
+func SetLogLevel(level string) error {
+	return logLevel.UnmarshalText([]byte(level))
 }
 
 // LoggingMiddleware is a middleware that logs request and response info
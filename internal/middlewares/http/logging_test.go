@@ -114,3 +114,33 @@ func TestResponseWriter_Write(t *testing.T) {
 		t.Errorf("expected body %q, got %q", data, recorder.Body.Bytes())
 	}
 }
+
+func TestSetLogLevel(t *testing.T) {
+	var logBuffer bytes.Buffer
+	encoderCfg := zap.NewDevelopmentEncoderConfig()
+	encoderCfg.TimeKey = ""
+	logger = zap.New(zapcore.NewCore(zapcore.NewConsoleEncoder(encoderCfg), zapcore.AddSync(&logBuffer), logLevel))
+	defer SetLogLevel("info")
+
+	handler := LoggingMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	if err := SetLogLevel("warn"); err != nil {
+		t.Fatal(err)
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if logBuffer.Len() != 0 {
+		t.Errorf("expected no request log at warn level, got %q", logBuffer.String())
+	}
+
+	if err := SetLogLevel("info"); err != nil {
+		t.Fatal(err)
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if !strings.Contains(logBuffer.String(), "request") {
+		t.Errorf("expected request log at info level, got %q", logBuffer.String())
+	}
+
+	if err := SetLogLevel("verbose"); err == nil {
+		t.Error("expected error for unknown level")
+	}
+}
@@ -0,0 +1,39 @@
+package http
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sbilibin2017/gophmetrics/internal/ratelimit"
+)
+
+// RateLimiter decides whether a client may make another request.
+type RateLimiter interface {
+	// Allow reports whether the request is allowed and, if not, how long the
+	// client should wait before retrying.
+	Allow(client string) (bool, time.Duration)
+}
+
+// RateLimitMiddleware returns a middleware that limits the requests of each
+// client, identified by its address: the remote address of the connection,
+// or the X-Real-IP header of requests forwarded by one of proxies. Rejected
+// requests get HTTP 429 Too Many Requests with a Retry-After header in seconds.
+//
+// It is meant to run before authentication so that unauthenticated requests
+// are limited too. The client address is stored in the request context for
+// per-client quotas; AuthMiddleware replaces it with the token identity.
+func RateLimitMiddleware(limiter RateLimiter, proxies ratelimit.Proxies) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			client := proxies.ClientAddr(r.RemoteAddr, r.Header.Get("X-Real-IP"))
+			if ok, wait := limiter.Allow(client); !ok {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(ratelimit.WithClient(r.Context(), client)))
+		})
+	}
+}
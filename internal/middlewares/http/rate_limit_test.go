@@ -0,0 +1,69 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sbilibin2017/gophmetrics/internal/ratelimit"
+)
+
+func TestRateLimitMiddleware(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockLimiter := NewMockRateLimiter(ctrl)
+
+	var client string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client = ratelimit.ClientFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	})
+	proxies, err := ratelimit.ParseProxies([]string{"10.0.0.0/8"})
+	assert.NoError(t, err)
+	handler := RateLimitMiddleware(mockLimiter, proxies)(next)
+
+	t.Run("allowed request sees the client", func(t *testing.T) {
+		mockLimiter.EXPECT().Allow("203.0.113.7").Return(true, time.Duration(0))
+
+		req := httptest.NewRequest(http.MethodPost, "/updates/", nil)
+		req.RemoteAddr = "203.0.113.7:5000"
+		req.Header.Set(AgentIDHeader, "agent-1")
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "203.0.113.7", client, "agent ID is chosen by the client and ignored")
+	})
+
+	t.Run("X-Real-IP of a trusted proxy", func(t *testing.T) {
+		mockLimiter.EXPECT().Allow("203.0.113.8").Return(true, time.Duration(0))
+
+		req := httptest.NewRequest(http.MethodPost, "/updates/", nil)
+		req.RemoteAddr = "10.0.0.1:5000"
+		req.Header.Set("X-Real-IP", "203.0.113.8")
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, "203.0.113.8", client)
+	})
+
+	t.Run("X-Real-IP of another source is ignored", func(t *testing.T) {
+		client = ""
+		mockLimiter.EXPECT().Allow("203.0.113.7").Return(false, 1500*time.Millisecond)
+
+		req := httptest.NewRequest(http.MethodPost, "/updates/", nil)
+		req.RemoteAddr = "203.0.113.7:5000"
+		req.Header.Set("X-Real-IP", "203.0.113.99")
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Equal(t, "2", w.Header().Get("Retry-After"))
+		assert.Empty(t, client)
+	})
+}
@@ -0,0 +1,35 @@
+package http
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// Middleware wraps an http.Handler.
+type Middleware func(http.Handler) http.Handler
+
+// ReloadableMiddleware is a middleware that can be replaced while the server
+// is running, e.g. when the hash key or the trusted subnet is reloaded.
+// Requests already in flight finish with the middleware they started with.
+type ReloadableMiddleware struct {
+	current atomic.Pointer[Middleware]
+}
+
+// NewReloadableMiddleware creates a ReloadableMiddleware applying mw.
+func NewReloadableMiddleware(mw Middleware) *ReloadableMiddleware {
+	m := &ReloadableMiddleware{}
+	m.Store(mw)
+	return m
+}
+
+// Store replaces the middleware applied to subsequent requests.
+func (m *ReloadableMiddleware) Store(mw Middleware) {
+	m.current.Store(&mw)
+}
+
+// Handler applies the current middleware to every request passed to next.
+func (m *ReloadableMiddleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		(*m.current.Load())(next).ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,33 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReloadableMiddleware(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	m := NewReloadableMiddleware(TrustedSubnetMiddleware(""))
+	h := m.Handler(next)
+
+	serve := func() int {
+		req := httptest.NewRequest(http.MethodPost, "/update/", nil)
+		req.Header.Set("X-Real-IP", "10.0.0.1")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusOK, serve())
+
+	m.Store(TrustedSubnetMiddleware("192.168.0.0/16"))
+	assert.Equal(t, http.StatusForbidden, serve())
+
+	m.Store(TrustedSubnetMiddleware("10.0.0.0/8"))
+	assert.Equal(t, http.StatusOK, serve())
+}
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// Agent statuses.
+const (
+	AgentLive  = "live"  // AgentLive means the agent reported recently.
+	AgentStale = "stale" // AgentStale means the agent missed its expected reports.
+	AgentDead  = "dead"  // AgentDead means the agent has not reported for a long time.
+)
+
+// Agent represents a metrics reporting source.
+//
+// swagger:model Agent
+type Agent struct {
+	// Agent identifier: agent ID or IP address of the reporting host.
+	//
+	// required: true
+	ID string `json:"id" example:"192.168.1.10"`
+
+	// Agent status: "live", "stale" or "dead".
+	//
+	// enum: live,stale,dead
+	Status string `json:"status,omitempty" example:"live"`
+
+	// First time the agent reported (read-only).
+	//
+	// read only: true
+	CreatedAt time.Time `json:"created_at"`
+
+	// Last time the agent reported (read-only).
+	//
+	// read only: true
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// Alert states.
+const (
+	AlertInactive = "inactive" // AlertInactive means the rule condition is not met.
+	AlertPending  = "pending"  // AlertPending means the condition is met but not for long enough.
+	AlertFiring   = "firing"   // AlertFiring means the condition has been met for the required duration.
+	AlertResolved = "resolved" // AlertResolved means a firing alert stopped matching its condition.
+)
+
+// Alert represents the current state of an alerting rule.
+//
+// swagger:model Alert
+type Alert struct {
+	// Rule name.
+	//
+	// required: true
+	Name string `json:"name" example:"LowMemory"`
+
+	// Alert state: "inactive", "pending", "firing" or "resolved".
+	//
+	// required: true
+	// enum: inactive,pending,firing,resolved
+	State string `json:"state" example:"firing"`
+
+	// Human-readable rule condition.
+	Condition string `json:"condition" example:"FreeMemory < 1e+09 for 2m0s"`
+
+	// Metric value observed on the last evaluation.
+	Value *float64 `json:"value,omitempty"`
+
+	// Time the condition started to match (pending and firing alerts).
+	ActiveSince *time.Time `json:"active_since,omitempty"`
+
+	// Time the alert started firing.
+	FiredAt *time.Time `json:"fired_at,omitempty"`
+
+	// Time the alert was resolved.
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+
+	// Time of the last evaluation.
+	EvaluatedAt time.Time `json:"evaluated_at"`
+}
@@ -0,0 +1,175 @@
+// Package promtext parses the Prometheus text exposition format.
+package promtext
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Metric family types.
+const (
+	TypeCounter   = "counter"
+	TypeGauge     = "gauge"
+	TypeHistogram = "histogram"
+	TypeSummary   = "summary"
+	TypeUntyped   = "untyped"
+)
+
+// ErrSyntax is returned for a line that is not valid exposition format.
+var ErrSyntax = errors.New("invalid exposition format")
+
+// Label is a label name and value pair.
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Sample is a single sample of a metric family.
+type Sample struct {
+	// Name is the sample name, e.g. http_request_duration_seconds_bucket.
+	Name string
+	// Family is the name of the metric family declared by # TYPE,
+	// e.g. http_request_duration_seconds; it equals Name when undeclared.
+	Family string
+	// Type is the family type, TypeUntyped when undeclared.
+	Type   string
+	Labels []Label
+	Value  float64
+}
+
+// Parse reads all samples from r. Comments other than # TYPE and timestamps
+// are ignored.
+func Parse(r io.Reader) ([]Sample, error) {
+	types := make(map[string]string)
+	var samples []Sample
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			fields := strings.Fields(line)
+			if len(fields) >= 4 && fields[1] == "TYPE" {
+				types[fields[2]] = fields[3]
+			}
+			continue
+		}
+
+		s, err := parseSample(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		s.Family, s.Type = family(s.Name, types)
+		samples = append(samples, s)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return samples, nil
+}
+
+// family resolves the declared family of a sample name: histogram and
+// summary samples carry _bucket, _sum and _count suffixes, counters may
+// carry _total.
+func family(name string, types map[string]string) (string, string) {
+	if t, ok := types[name]; ok {
+		return name, t
+	}
+	for _, suffix := range []string{"_bucket", "_sum", "_count", "_total"} {
+		base, ok := strings.CutSuffix(name, suffix)
+		if !ok {
+			continue
+		}
+		switch t := types[base]; {
+		case suffix == "_total" && t == TypeCounter:
+			return base, t
+		case suffix != "_total" && (t == TypeHistogram || t == TypeSummary):
+			return base, t
+		}
+	}
+	return name, TypeUntyped
+}
+
+// parseSample parses a line of the form name{labels} value [timestamp].
+func parseSample(line string) (Sample, error) {
+	var s Sample
+
+	end := strings.IndexAny(line, "{ \t")
+	if end <= 0 {
+		return s, ErrSyntax
+	}
+	s.Name = line[:end]
+	rest := line[end:]
+
+	if rest[0] == '{' {
+		labels, n, err := parseLabels(rest[1:])
+		if err != nil {
+			return s, err
+		}
+		s.Labels = labels
+		rest = rest[1+n:]
+	}
+
+	fields := strings.Fields(rest)
+	if len(fields) == 0 || len(fields) > 2 {
+		return s, ErrSyntax
+	}
+	v, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return s, fmt.Errorf("%w: value %q", ErrSyntax, fields[0])
+	}
+	s.Value = v
+	return s, nil
+}
+
+// parseLabels parses label pairs up to and including the closing brace and
+// returns the number of bytes consumed.
+func parseLabels(in string) ([]Label, int, error) {
+	var labels []Label
+	i := 0
+	for {
+		for i < len(in) && (in[i] == ' ' || in[i] == ',') {
+			i++
+		}
+		if i >= len(in) {
+			return nil, 0, ErrSyntax
+		}
+		if in[i] == '}' {
+			return labels, i + 1, nil
+		}
+
+		eq := strings.IndexByte(in[i:], '=')
+		if eq <= 0 || i+eq+1 >= len(in) || in[i+eq+1] != '"' {
+			return nil, 0, ErrSyntax
+		}
+		name := strings.TrimSpace(in[i : i+eq])
+		i += eq + 2
+
+		var sb strings.Builder
+		for ; i < len(in) && in[i] != '"'; i++ {
+			if in[i] != '\\' || i+1 == len(in) {
+				sb.WriteByte(in[i])
+				continue
+			}
+			i++
+			switch in[i] {
+			case 'n':
+				sb.WriteByte('\n')
+			default:
+				sb.WriteByte(in[i])
+			}
+		}
+		if i >= len(in) {
+			return nil, 0, ErrSyntax
+		}
+		i++ // closing quote
+		labels = append(labels, Label{Name: name, Value: sb.String()})
+	}
+}
@@ -0,0 +1,59 @@
+package promtext
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	input := `# HELP http_requests_total Total requests.
+# TYPE http_requests_total counter
+http_requests_total{method="get",code="200"} 1027 1395066363000
+http_requests_total{method="post", code="400"} 3
+
+# TYPE temperature gauge
+temperature -1.5
+# TYPE rpc_duration_seconds histogram
+rpc_duration_seconds_bucket{le="0.1"} 5
+rpc_duration_seconds_bucket{le="+Inf"} 7
+rpc_duration_seconds_sum 0.9
+rpc_duration_seconds_count 7
+# TYPE requests counter
+requests_total 4
+escaped{path="C:\\dir\"x\"\nend"} 1
+up 1
+`
+	samples, err := Parse(strings.NewReader(input))
+	require.NoError(t, err)
+	assert.Equal(t, []Sample{
+		{Name: "http_requests_total", Family: "http_requests_total", Type: TypeCounter, Labels: []Label{{"method", "get"}, {"code", "200"}}, Value: 1027},
+		{Name: "http_requests_total", Family: "http_requests_total", Type: TypeCounter, Labels: []Label{{"method", "post"}, {"code", "400"}}, Value: 3},
+		{Name: "temperature", Family: "temperature", Type: TypeGauge, Value: -1.5},
+		{Name: "rpc_duration_seconds_bucket", Family: "rpc_duration_seconds", Type: TypeHistogram, Labels: []Label{{"le", "0.1"}}, Value: 5},
+		{Name: "rpc_duration_seconds_bucket", Family: "rpc_duration_seconds", Type: TypeHistogram, Labels: []Label{{"le", "+Inf"}}, Value: 7},
+		{Name: "rpc_duration_seconds_sum", Family: "rpc_duration_seconds", Type: TypeHistogram, Value: 0.9},
+		{Name: "rpc_duration_seconds_count", Family: "rpc_duration_seconds", Type: TypeHistogram, Value: 7},
+		{Name: "requests_total", Family: "requests", Type: TypeCounter, Value: 4},
+		{Name: "escaped", Family: "escaped", Type: TypeUntyped, Labels: []Label{{"path", "C:\\dir\"x\"\nend"}}, Value: 1},
+		{Name: "up", Family: "up", Type: TypeUntyped, Value: 1},
+	}, samples)
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []string{
+		"novalue",
+		"m{a=\"1\"",
+		"m{a=1} 2",
+		"m abc",
+		"m 1 2 3",
+		"{a=\"1\"} 2",
+	}
+	for _, input := range tests {
+		_, err := Parse(strings.NewReader(input))
+		assert.ErrorIs(t, err, ErrSyntax, input)
+		assert.ErrorContains(t, err, "line 1", input)
+	}
+}
@@ -0,0 +1,171 @@
+package queue
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/sbilibin2017/gophmetrics/internal/models"
+)
+
+// batchExt is the extension of spooled batch files.
+const batchExt = ".json"
+
+// ErrEmpty is returned by Peek when the queue holds no batches.
+var ErrEmpty = errors.New("queue is empty")
+
+// Queue is a bounded FIFO of metric batches spooled to a directory.
+// Every batch is a separate file named after its sequence number, so the
+// queue survives restarts of the process. When the queue is full the oldest
+// batch is dropped to make room for the new one.
+type Queue struct {
+	mu      sync.Mutex
+	dir     string
+	max     int
+	seqs    []uint64 // sequence numbers of spooled batches, oldest first
+	next    uint64
+	dropped int64
+}
+
+// New opens the queue in dir holding at most max batches, creating dir if needed.
+// Batches left from a previous run are kept and replayed first.
+func New(dir string, max int) (*Queue, error) {
+	if max <= 0 {
+		return nil, errors.New("queue size must be greater than 0")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	q := &Queue{dir: dir, max: max}
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, batchExt) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, batchExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		q.seqs = append(q.seqs, seq)
+	}
+	sort.Slice(q.seqs, func(i, j int) bool { return q.seqs[i] < q.seqs[j] })
+	if len(q.seqs) > 0 {
+		q.next = q.seqs[len(q.seqs)-1] + 1
+	}
+
+	return q, nil
+}
+
+// Push appends the batch to the tail of the queue, dropping the oldest
+// batches when the queue is full.
+func (q *Queue) Push(batch []*models.Metrics) error {
+	data, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for len(q.seqs) >= q.max {
+		if err := q.remove(q.seqs[0]); err != nil {
+			return err
+		}
+		q.seqs = q.seqs[1:]
+		q.dropped++
+	}
+
+	seq := q.next
+	tmp := filepath.Join(q.dir, fmt.Sprintf(".%020d%s.tmp", seq, batchExt))
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, q.path(seq)); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	q.seqs = append(q.seqs, seq)
+	q.next++
+	return nil
+}
+
+// Peek returns the oldest batch without removing it.
+// Batches that cannot be decoded are discarded and counted as dropped.
+// It returns ErrEmpty when there is nothing to replay.
+func (q *Queue) Peek() ([]*models.Metrics, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for len(q.seqs) > 0 {
+		data, err := os.ReadFile(q.path(q.seqs[0]))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+
+		var batch []*models.Metrics
+		if err == nil && json.Unmarshal(data, &batch) == nil {
+			return batch, nil
+		}
+
+		if err := q.remove(q.seqs[0]); err != nil {
+			return nil, err
+		}
+		q.seqs = q.seqs[1:]
+		q.dropped++
+	}
+
+	return nil, ErrEmpty
+}
+
+// Pop removes the oldest batch. It is a no-op on an empty queue.
+func (q *Queue) Pop() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.seqs) == 0 {
+		return nil
+	}
+	if err := q.remove(q.seqs[0]); err != nil {
+		return err
+	}
+	q.seqs = q.seqs[1:]
+	return nil
+}
+
+// Len returns the number of spooled batches.
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.seqs)
+}
+
+// Dropped returns the number of batches dropped because the queue was full.
+func (q *Queue) Dropped() int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.dropped
+}
+
+func (q *Queue) path(seq uint64) string {
+	return filepath.Join(q.dir, fmt.Sprintf("%020d%s", seq, batchExt))
+}
+
+func (q *Queue) remove(seq uint64) error {
+	if err := os.Remove(q.path(seq)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
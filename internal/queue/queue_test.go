@@ -0,0 +1,103 @@
+package queue
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sbilibin2017/gophmetrics/internal/models"
+)
+
+func batch(id string, delta int64) []*models.Metrics {
+	return []*models.Metrics{{ID: id, MType: models.Counter, Delta: &delta}}
+}
+
+func TestQueue_FIFO(t *testing.T) {
+	q, err := New(t.TempDir(), 10)
+	require.NoError(t, err)
+
+	_, err = q.Peek()
+	assert.ErrorIs(t, err, ErrEmpty)
+
+	require.NoError(t, q.Push(batch("a", 1)))
+	require.NoError(t, q.Push(batch("b", 2)))
+	assert.Equal(t, 2, q.Len())
+
+	got, err := q.Peek()
+	require.NoError(t, err)
+	assert.Equal(t, batch("a", 1), got)
+
+	require.NoError(t, q.Pop())
+	got, err = q.Peek()
+	require.NoError(t, err)
+	assert.Equal(t, batch("b", 2), got)
+
+	require.NoError(t, q.Pop())
+	assert.Equal(t, 0, q.Len())
+	require.NoError(t, q.Pop())
+}
+
+func TestQueue_DropsOldestWhenFull(t *testing.T) {
+	q, err := New(t.TempDir(), 2)
+	require.NoError(t, err)
+
+	require.NoError(t, q.Push(batch("a", 1)))
+	require.NoError(t, q.Push(batch("b", 2)))
+	require.NoError(t, q.Push(batch("c", 3)))
+
+	assert.Equal(t, 2, q.Len())
+	assert.Equal(t, int64(1), q.Dropped())
+
+	got, err := q.Peek()
+	require.NoError(t, err)
+	assert.Equal(t, batch("b", 2), got)
+}
+
+func TestQueue_Reopen(t *testing.T) {
+	dir := t.TempDir()
+
+	q, err := New(dir, 10)
+	require.NoError(t, err)
+	require.NoError(t, q.Push(batch("a", 1)))
+	require.NoError(t, q.Push(batch("b", 2)))
+	require.NoError(t, q.Pop())
+
+	q, err = New(dir, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, q.Len())
+
+	require.NoError(t, q.Push(batch("c", 3)))
+	got, err := q.Peek()
+	require.NoError(t, err)
+	assert.Equal(t, batch("b", 2), got)
+
+	require.NoError(t, q.Pop())
+	got, err = q.Peek()
+	require.NoError(t, err)
+	assert.Equal(t, batch("c", 3), got)
+}
+
+func TestQueue_SkipsCorruptedBatch(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "00000000000000000001.json"), []byte("{"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "not-a-batch.txt"), []byte("x"), 0o644))
+
+	q, err := New(dir, 10)
+	require.NoError(t, err)
+	require.NoError(t, q.Push(batch("a", 1)))
+	assert.Equal(t, 2, q.Len())
+
+	got, err := q.Peek()
+	require.NoError(t, err)
+	assert.Equal(t, batch("a", 1), got)
+	assert.Equal(t, int64(1), q.Dropped())
+	assert.Equal(t, 1, q.Len())
+}
+
+func TestNew_InvalidSize(t *testing.T) {
+	_, err := New(t.TempDir(), 0)
+	assert.Error(t, err)
+}
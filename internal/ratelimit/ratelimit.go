@@ -0,0 +1,271 @@
+// Package ratelimit limits the requests and the distinct metrics of each
+// client of the server.
+//
+// Requests are limited by the address of the client, which it cannot choose
+// freely, before authentication, so that unauthenticated floods and token
+// guessing are throttled too. Quotas are counted per token once a request is
+// authenticated, or per address if authentication is disabled.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sbilibin2017/gophmetrics/internal/models"
+)
+
+// ErrQuotaExceeded is returned when a client reports more distinct metrics
+// than its quota allows.
+var ErrQuotaExceeded = errors.New("metric quota exceeded")
+
+// sweepInterval is how often idle clients are forgotten.
+const sweepInterval = time.Minute
+
+// quotaIdleAfter is the time without new metrics after which the quota of a
+// client is forgotten, so that the number of counted clients stays bounded.
+const quotaIdleAfter = time.Hour
+
+// bucket is the token bucket of one client.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter is a token bucket rate limiter keyed by client. Each client may
+// make burst requests at once and rate requests per second on average.
+type Limiter struct {
+	mu        sync.Mutex
+	rate      float64
+	burst     float64
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewLimiter creates a limiter allowing rate requests per second with bursts
+// of burst requests per client. A burst below 1 is taken as rate.
+// If rate is not positive, every request is allowed.
+func NewLimiter(rate, burst int) *Limiter {
+	l := &Limiter{
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+	l.SetLimit(rate, burst)
+	return l
+}
+
+// SetLimit changes the rate and burst of all clients.
+func (l *Limiter) SetLimit(rate, burst int) {
+	if burst < 1 {
+		burst = rate
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rate = float64(rate)
+	l.burst = float64(burst)
+}
+
+// Allow takes a token from the bucket of client. If the bucket is empty it
+// returns false and the time until a token is available.
+func (l *Limiter) Allow(client string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.rate <= 0 {
+		return true, 0
+	}
+
+	now := l.now()
+	if now.Sub(l.lastSweep) >= sweepInterval {
+		l.sweep(now)
+	}
+
+	b, ok := l.buckets[client]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[client] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+		return false, wait
+	}
+	b.tokens--
+	return true, 0
+}
+
+// sweep forgets the clients whose buckets have refilled, as a new bucket
+// is full anyway.
+func (l *Limiter) sweep(now time.Time) {
+	for client, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, client)
+		}
+	}
+	l.lastSweep = now
+}
+
+// Quota limits the number of distinct metrics each client may report.
+// Metrics are counted until the client reports no metric for quotaIdleAfter.
+type Quota struct {
+	mu        sync.Mutex
+	max       int
+	clients   map[string]*clientMetrics
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// clientMetrics are the metrics reported by one client.
+type clientMetrics struct {
+	ids  map[models.MetricID]struct{}
+	last time.Time
+}
+
+// NewQuota creates a quota of max distinct metrics per client.
+// If max is not positive, the number of metrics is unlimited.
+func NewQuota(max int) *Quota {
+	return &Quota{
+		max:     max,
+		clients: make(map[string]*clientMetrics),
+		now:     time.Now,
+	}
+}
+
+// SetMax changes the quota of all clients. Metrics already reported stay
+// counted, so a client over the new quota may only update them.
+func (q *Quota) SetMax(max int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.max = max
+}
+
+// Admit counts the metrics for the client stored in ctx and returns the ones
+// that were not counted before. It returns ErrQuotaExceeded, counting none of
+// them, if the new metrics would take the client over its quota.
+// Calls without a client are always admitted.
+func (q *Quota) Admit(ctx context.Context, ids ...models.MetricID) ([]models.MetricID, error) {
+	client := ClientFromContext(ctx)
+	if client == "" {
+		return nil, nil
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.max <= 0 {
+		return nil, nil
+	}
+
+	now := q.now()
+	if now.Sub(q.lastSweep) >= sweepInterval {
+		q.sweep(now)
+	}
+
+	c, ok := q.clients[client]
+	if !ok {
+		c = &clientMetrics{ids: make(map[models.MetricID]struct{})}
+		q.clients[client] = c
+	}
+	c.last = now
+
+	var added []models.MetricID
+	for _, id := range ids {
+		if _, ok := c.ids[id]; ok || slices.Contains(added, id) {
+			continue
+		}
+		added = append(added, id)
+	}
+	if len(added) > 0 && len(c.ids)+len(added) > q.max {
+		return nil, ErrQuotaExceeded
+	}
+	for _, id := range added {
+		c.ids[id] = struct{}{}
+	}
+	return added, nil
+}
+
+// Release uncounts metrics admitted for the client stored in ctx that were
+// not updated after all, so that failed updates do not use up the quota.
+func (q *Quota) Release(ctx context.Context, ids ...models.MetricID) {
+	client := ClientFromContext(ctx)
+	if client == "" {
+		return
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if c, ok := q.clients[client]; ok {
+		for _, id := range ids {
+			delete(c.ids, id)
+		}
+	}
+}
+
+// sweep forgets the clients that have reported no metric for quotaIdleAfter.
+func (q *Quota) sweep(now time.Time) {
+	for client, c := range q.clients {
+		if now.Sub(c.last) >= quotaIdleAfter {
+			delete(q.clients, client)
+		}
+	}
+	q.lastSweep = now
+}
+
+// Proxies are the networks of trusted reverse proxies, whose X-Real-IP
+// header identifies the client.
+type Proxies []*net.IPNet
+
+// ParseProxies parses comma-separated CIDRs of trusted proxies.
+func ParseProxies(cidrs []string) (Proxies, error) {
+	var proxies Proxies
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(strings.TrimSpace(cidr))
+		if err != nil {
+			return nil, err
+		}
+		proxies = append(proxies, network)
+	}
+	return proxies, nil
+}
+
+// ClientAddr returns the address identifying a client connected from
+// remote, a host with or without port. If remote is a trusted proxy and
+// realIP is set, realIP is returned instead.
+func (p Proxies) ClientAddr(remote, realIP string) string {
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		remote = host
+	}
+	if realIP == "" {
+		return remote
+	}
+	ip := net.ParseIP(remote)
+	for _, network := range p {
+		if ip != nil && network.Contains(ip) {
+			return realIP
+		}
+	}
+	return remote
+}
+
+type clientKey struct{}
+
+// WithClient returns a copy of ctx carrying the identity of the client.
+func WithClient(ctx context.Context, client string) context.Context {
+	return context.WithValue(ctx, clientKey{}, client)
+}
+
+// ClientFromContext returns the identity of the client stored in ctx, or "".
+func ClientFromContext(ctx context.Context) string {
+	client, _ := ctx.Value(clientKey{}).(string)
+	return client
+}
@@ -0,0 +1,162 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sbilibin2017/gophmetrics/internal/models"
+)
+
+// fakeClock returns a settable time for the limiter.
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func TestLimiter_Allow(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	l := NewLimiter(2, 3)
+	l.now = clock.now
+
+	for i := 0; i < 3; i++ {
+		ok, _ := l.Allow("a")
+		assert.True(t, ok, "burst request %d", i)
+	}
+	ok, wait := l.Allow("a")
+	assert.False(t, ok)
+	assert.Equal(t, 500*time.Millisecond, wait)
+
+	ok, _ = l.Allow("b")
+	assert.True(t, ok, "clients have separate buckets")
+
+	clock.t = clock.t.Add(500 * time.Millisecond)
+	ok, _ = l.Allow("a")
+	assert.True(t, ok, "token refilled")
+	ok, _ = l.Allow("a")
+	assert.False(t, ok)
+}
+
+func TestLimiter_Unlimited(t *testing.T) {
+	l := NewLimiter(0, 0)
+	for i := 0; i < 100; i++ {
+		ok, _ := l.Allow("a")
+		assert.True(t, ok)
+	}
+
+	l.SetLimit(1, 0)
+	ok, _ := l.Allow("a")
+	assert.True(t, ok)
+	ok, _ = l.Allow("a")
+	assert.False(t, ok, "burst defaults to rate")
+}
+
+func TestLimiter_Sweep(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	l := NewLimiter(1, 1)
+	l.now = clock.now
+
+	l.Allow("a")
+	clock.t = clock.t.Add(sweepInterval)
+	l.Allow("b")
+
+	assert.NotContains(t, l.buckets, "a", "idle client forgotten")
+	assert.Contains(t, l.buckets, "b")
+}
+
+func TestQuota_Admit(t *testing.T) {
+	q := NewQuota(2)
+	a := WithClient(context.Background(), "a")
+	b := WithClient(context.Background(), "b")
+
+	gauge := func(id string) models.MetricID { return models.MetricID{ID: id, MType: models.Gauge} }
+	admit := func(ctx context.Context, ids ...models.MetricID) error {
+		_, err := q.Admit(ctx, ids...)
+		return err
+	}
+
+	added, err := q.Admit(a, gauge("m1"))
+	require.NoError(t, err)
+	assert.Equal(t, []models.MetricID{gauge("m1")}, added)
+	added, err = q.Admit(a, gauge("m1"), gauge("m2"), gauge("m2"))
+	require.NoError(t, err)
+	assert.Equal(t, []models.MetricID{gauge("m2")}, added, "only new metrics are returned")
+	added, err = q.Admit(a, gauge("m1"))
+	assert.NoError(t, err, "known metric")
+	assert.Empty(t, added)
+	assert.ErrorIs(t, admit(a, gauge("m3")), ErrQuotaExceeded)
+	assert.ErrorIs(t, admit(a, models.MetricID{ID: "m1", MType: models.Counter}), ErrQuotaExceeded, "type is part of the identity")
+	assert.NoError(t, admit(b, gauge("m3")), "clients have separate quotas")
+	assert.NoError(t, admit(context.Background(), gauge("m4")), "no client")
+
+	q.SetMax(0)
+	assert.NoError(t, admit(a, gauge("m3")), "unlimited")
+}
+
+func TestQuota_AdmitBatch(t *testing.T) {
+	q := NewQuota(2)
+	a := WithClient(context.Background(), "a")
+	gauge := func(id string) models.MetricID { return models.MetricID{ID: id, MType: models.Gauge} }
+
+	_, err := q.Admit(a, gauge("m1"))
+	require.NoError(t, err)
+
+	_, err = q.Admit(a, gauge("m1"), gauge("m2"), gauge("m3"))
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+	assert.Len(t, q.clients["a"].ids, 1, "a rejected batch counts nothing")
+
+	_, err = q.Admit(a, gauge("m1"), gauge("m2"))
+	assert.NoError(t, err)
+}
+
+func TestQuota_Release(t *testing.T) {
+	q := NewQuota(1)
+	a := WithClient(context.Background(), "a")
+	gauge := func(id string) models.MetricID { return models.MetricID{ID: id, MType: models.Gauge} }
+
+	added, err := q.Admit(a, gauge("m1"))
+	require.NoError(t, err)
+	q.Release(a, added...)
+
+	_, err = q.Admit(a, gauge("m2"))
+	assert.NoError(t, err, "released metric does not use up the quota")
+
+	q.Release(context.Background(), gauge("m2"))
+	q.Release(WithClient(context.Background(), "b"), gauge("m2"))
+	_, err = q.Admit(a, gauge("m3"))
+	assert.ErrorIs(t, err, ErrQuotaExceeded, "other clients cannot release")
+}
+
+func TestQuota_Sweep(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	q := NewQuota(1)
+	q.now = clock.now
+
+	a := WithClient(context.Background(), "a")
+	b := WithClient(context.Background(), "b")
+	gauge := func(id string) models.MetricID { return models.MetricID{ID: id, MType: models.Gauge} }
+
+	_, err := q.Admit(a, gauge("m1"))
+	assert.NoError(t, err)
+	clock.t = clock.t.Add(quotaIdleAfter)
+	_, err = q.Admit(b, gauge("m1"))
+	assert.NoError(t, err)
+
+	assert.NotContains(t, q.clients, "a", "idle client forgotten")
+	assert.Contains(t, q.clients, "b")
+}
+
+func TestProxies_ClientAddr(t *testing.T) {
+	proxies, err := ParseProxies([]string{"10.0.0.0/8", " 192.168.1.1/32"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "203.0.113.7", proxies.ClientAddr("10.1.2.3:5000", "203.0.113.7"), "trusted proxy")
+	assert.Equal(t, "10.1.2.3", proxies.ClientAddr("10.1.2.3:5000", ""), "proxy without X-Real-IP")
+	assert.Equal(t, "203.0.113.9", proxies.ClientAddr("203.0.113.9:5000", "10.9.9.9"), "untrusted source")
+	assert.Equal(t, "192.168.1.1", Proxies(nil).ClientAddr("192.168.1.1", "203.0.113.7"), "no proxies")
+
+	_, err = ParseProxies([]string{"10.0.0.0"})
+	assert.Error(t, err)
+}
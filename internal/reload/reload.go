@@ -0,0 +1,70 @@
+// Package reload re-applies configuration while a process is running.
+package reload
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Watch calls apply whenever the process receives SIGHUP and, if interval is
+// greater than 0, when the modification time of the file at path changes,
+// checking it every interval. It returns when ctx is done.
+//
+// apply is expected to validate the new configuration before changing
+// anything; its errors are logged and the running configuration stays in
+// effect.
+func Watch(ctx context.Context, path string, interval time.Duration, apply func() error) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var tick <-chan time.Time
+	if interval > 0 && path != "" {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	modTime := fileModTime(path)
+
+	reload := func(reason string) {
+		if err := apply(); err != nil {
+			log.Printf("config reload on %s rejected, keeping the running config: %v", reason, err)
+			return
+		}
+		log.Printf("config reloaded on %s", reason)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			modTime = fileModTime(path)
+			reload("SIGHUP")
+		case <-tick:
+			t := fileModTime(path)
+			if t.Equal(modTime) {
+				continue
+			}
+			modTime = t
+			reload("change of " + path)
+		}
+	}
+}
+
+// fileModTime returns the modification time of the file at path, or the zero
+// time if it cannot be read.
+func fileModTime(path string) time.Time {
+	if path == "" {
+		return time.Time{}
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return fi.ModTime()
+}
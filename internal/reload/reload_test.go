@@ -0,0 +1,71 @@
+package reload
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatch_SIGHUP(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	applied := make(chan struct{}, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		Watch(ctx, "", 0, func() error {
+			applied <- struct{}{}
+			return errors.New("rejected")
+		})
+	}()
+
+	// The signal is sent until Watch has subscribed to it.
+	require.Eventually(t, func() bool {
+		require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGHUP))
+		select {
+		case <-applied:
+			return true
+		case <-time.After(10 * time.Millisecond):
+			return false
+		}
+	}, time.Second, 20*time.Millisecond)
+
+	cancel()
+	<-done
+}
+
+func TestWatch_FileChange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{}`), 0o600))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	applied := make(chan struct{}, 10)
+	go Watch(ctx, path, 5*time.Millisecond, func() error {
+		applied <- struct{}{}
+		return nil
+	})
+
+	select {
+	case <-applied:
+		t.Fatal("applied without a change")
+	case <-time.After(30 * time.Millisecond):
+	}
+
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Hour)))
+	select {
+	case <-applied:
+	case <-time.After(time.Second):
+		t.Fatal("change not detected")
+	}
+	assert.Empty(t, applied, "applied once per change")
+}
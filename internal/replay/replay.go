@@ -0,0 +1,136 @@
+// Package replay rejects signed requests that are stale or replayed.
+//
+// A signed request carries the time it was signed at and a random nonce.
+// The guard accepts it if the time is within the window around now and the
+// nonce was not seen within the window before. Requests signed by earlier
+// agents carry neither and are accepted unless the guard requires them.
+package replay
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// maxNonceLen bounds the memory a nonce takes in the cache.
+const maxNonceLen = 64
+
+var (
+	// ErrMissing is returned for a request with only one of timestamp and
+	// nonce, an invalid nonce, or neither if they are required.
+	ErrMissing = errors.New("missing or invalid timestamp or nonce")
+	// ErrStale is returned for a request signed outside the window.
+	ErrStale = errors.New("timestamp outside the allowed clock skew")
+	// ErrReplayed is returned for a nonce seen before.
+	ErrReplayed = errors.New("nonce already used")
+)
+
+// entry is a seen nonce and the time it can be forgotten at.
+type entry struct {
+	nonce   string
+	expires time.Time
+}
+
+// Guard remembers recent nonces in a bounded cache. When the cache is full
+// the oldest nonce is forgotten, so its request could be replayed until its
+// timestamp leaves the window.
+type Guard struct {
+	mu       sync.Mutex
+	window   time.Duration
+	required bool
+	size     int
+	seen     map[string]time.Time
+	order    []entry // nonces in the order they were seen
+	now      func() time.Time
+}
+
+// Opt configures a Guard.
+type Opt func(*Guard)
+
+// WithRequired rejects requests without timestamp and nonce.
+func WithRequired(required bool) Opt {
+	return func(g *Guard) {
+		g.required = required
+	}
+}
+
+// NewGuard creates a guard accepting requests signed at most window before
+// or after now and remembering up to size nonces. If window is not positive,
+// every request is accepted.
+func NewGuard(window time.Duration, size int, opts ...Opt) *Guard {
+	g := &Guard{
+		window: window,
+		size:   size,
+		seen:   make(map[string]time.Time),
+		now:    time.Now,
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
+
+// SetWindow changes the window. Nonces already seen are kept.
+func (g *Guard) SetWindow(window time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.window = window
+}
+
+// SetRequired changes whether requests without timestamp and nonce are
+// rejected.
+func (g *Guard) SetRequired(required bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.required = required
+}
+
+// Check records the nonce of a request signed at timestamp and returns an
+// error if the request must be rejected. A zero timestamp or empty nonce
+// means the request has none.
+func (g *Guard) Check(timestamp time.Time, nonce string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.window <= 0 {
+		return nil
+	}
+	if timestamp.IsZero() && nonce == "" && !g.required {
+		return nil
+	}
+	if timestamp.IsZero() || nonce == "" || len(nonce) > maxNonceLen {
+		return ErrMissing
+	}
+
+	now := g.now()
+	if skew := now.Sub(timestamp); skew > g.window || skew < -g.window {
+		return ErrStale
+	}
+
+	g.expire(now)
+	if _, ok := g.seen[nonce]; ok {
+		return ErrReplayed
+	}
+
+	for len(g.order) > 0 && len(g.order) >= g.size {
+		g.forgetOldest()
+	}
+	// After the window has passed the timestamp, the request is stale anyway.
+	expires := timestamp.Add(g.window)
+	g.seen[nonce] = expires
+	g.order = append(g.order, entry{nonce: nonce, expires: expires})
+	return nil
+}
+
+// expire forgets the oldest nonces as long as their requests are stale.
+func (g *Guard) expire(now time.Time) {
+	for len(g.order) > 0 && g.order[0].expires.Before(now) {
+		g.forgetOldest()
+	}
+}
+
+func (g *Guard) forgetOldest() {
+	delete(g.seen, g.order[0].nonce)
+	g.order[0] = entry{}
+	g.order = g.order[1:]
+}
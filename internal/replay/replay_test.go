@@ -0,0 +1,87 @@
+package replay
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeClock returns a settable time for the guard.
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func newTestGuard(window time.Duration, size int, opts ...Opt) (*Guard, *fakeClock) {
+	clock := &fakeClock{t: time.Unix(1700000000, 0)}
+	g := NewGuard(window, size, opts...)
+	g.now = clock.now
+	return g, clock
+}
+
+func TestGuard_Check(t *testing.T) {
+	g, clock := newTestGuard(time.Minute, 10)
+	now := clock.t
+
+	assert.NoError(t, g.Check(now, "a"))
+	assert.ErrorIs(t, g.Check(now, "a"), ErrReplayed)
+	assert.NoError(t, g.Check(now.Add(-time.Minute), "b"), "signed at the edge of the window")
+	assert.NoError(t, g.Check(now.Add(time.Minute), "c"), "agent clock slightly ahead")
+
+	assert.ErrorIs(t, g.Check(now.Add(-time.Minute-time.Second), "d"), ErrStale)
+	assert.ErrorIs(t, g.Check(now.Add(time.Minute+time.Second), "e"), ErrStale)
+
+	assert.NoError(t, g.Check(time.Time{}, ""), "signed by an earlier agent")
+	assert.ErrorIs(t, g.Check(time.Time{}, "f"), ErrMissing)
+	assert.ErrorIs(t, g.Check(now, ""), ErrMissing)
+	assert.ErrorIs(t, g.Check(now, strings.Repeat("x", maxNonceLen+1)), ErrMissing)
+}
+
+func TestGuard_Expire(t *testing.T) {
+	g, clock := newTestGuard(time.Minute, 10)
+	signedAt := clock.t
+
+	assert.NoError(t, g.Check(signedAt, "a"))
+	clock.t = clock.t.Add(time.Minute)
+	assert.ErrorIs(t, g.Check(signedAt, "a"), ErrReplayed)
+
+	clock.t = clock.t.Add(time.Second)
+	assert.ErrorIs(t, g.Check(signedAt, "a"), ErrStale, "expired nonces are stale anyway")
+	assert.NoError(t, g.Check(clock.t, "b"))
+	assert.Len(t, g.seen, 1, "expired nonce is forgotten")
+}
+
+func TestGuard_Bounded(t *testing.T) {
+	g, clock := newTestGuard(time.Minute, 2)
+
+	assert.NoError(t, g.Check(clock.t, "a"))
+	assert.NoError(t, g.Check(clock.t, "b"))
+	assert.NoError(t, g.Check(clock.t, "c"))
+	assert.Len(t, g.seen, 2)
+
+	assert.NoError(t, g.Check(clock.t, "a"), "oldest nonce was evicted")
+	assert.ErrorIs(t, g.Check(clock.t, "c"), ErrReplayed)
+}
+
+func TestGuard_Disabled(t *testing.T) {
+	g, clock := newTestGuard(0, 10)
+
+	assert.NoError(t, g.Check(time.Time{}, ""))
+	assert.NoError(t, g.Check(clock.t, "a"))
+	assert.NoError(t, g.Check(clock.t, "a"))
+
+	g.SetWindow(time.Minute)
+	assert.NoError(t, g.Check(clock.t, "a"))
+	assert.ErrorIs(t, g.Check(clock.t, "a"), ErrReplayed)
+}
+
+func TestGuard_Required(t *testing.T) {
+	g, clock := newTestGuard(time.Minute, 10, WithRequired(true))
+
+	assert.ErrorIs(t, g.Check(time.Time{}, ""), ErrMissing)
+	assert.NoError(t, g.Check(clock.t, "a"))
+
+	g.SetRequired(false)
+	assert.NoError(t, g.Check(time.Time{}, ""))
+}
@@ -3,6 +3,7 @@ package db
 import (
 	"context"
 	"database/sql"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/sbilibin2017/gophmetrics/internal/models"
@@ -19,18 +20,29 @@ func NewMetricWriteRepository(db *sqlx.DB) *MetricWriteRepository {
 }
 
 // Save inserts or updates a metric in the database, updating timestamps accordingly.
+// The timestamps of the metric are stored as is, unset ones default to the current
+// time; created_at of an existing row is kept.
 func (r *MetricWriteRepository) Save(
 	ctx context.Context,
 	metric *models.Metrics,
 ) error {
 	query := `
 		INSERT INTO metrics (id, type, delta, value, created_at, updated_at)
-		VALUES (:id, :type, :delta, :value, now(), now())
+		VALUES (:id, :type, :delta, :value, :created_at, :updated_at)
 		ON CONFLICT (id, type) DO UPDATE
-		SET delta = EXCLUDED.delta, value = EXCLUDED.value, updated_at = now()
+		SET delta = EXCLUDED.delta, value = EXCLUDED.value, updated_at = EXCLUDED.updated_at
 	`
 
-	_, err := r.db.NamedExecContext(ctx, query, metric)
+	row := *metric
+	now := time.Now()
+	if row.UpdatedAt.IsZero() {
+		row.UpdatedAt = now
+	}
+	if row.CreatedAt.IsZero() {
+		row.CreatedAt = row.UpdatedAt
+	}
+
+	_, err := r.db.NamedExecContext(ctx, query, &row)
 
 	return err
 }
@@ -85,3 +97,31 @@ func (r *MetricReadRepository) List(ctx context.Context) ([]*models.Metrics, err
 
 	return result, nil
 }
+
+// Walk calls fn for each metric stored in the database as the rows are read,
+// without loading them all into memory.
+func (r *MetricReadRepository) Walk(ctx context.Context, fn func(*models.Metrics) error) error {
+	query := `
+		SELECT id, type, delta, value, created_at, updated_at
+		FROM metrics
+		ORDER BY id, type
+	`
+
+	rows, err := r.db.QueryxContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var metric models.Metrics
+		if err := rows.StructScan(&metric); err != nil {
+			return err
+		}
+		if err := fn(&metric); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
@@ -160,6 +160,37 @@ func TestMetricReadRepository_List(t *testing.T) {
 	}
 }
 
+func TestMetricReadRepository_Walk(t *testing.T) {
+	ctx, cleanup := setupPostgres(t)
+	defer cleanup()
+
+	writeRepo := NewMetricWriteRepository(db)
+	readRepo := NewMetricReadRepository(db)
+
+	metrics := []*models.Metrics{
+		{ID: "m2", MType: models.Counter, Delta: ptrInt64(2)},
+		{ID: "m1", MType: models.Gauge, Value: ptrFloat64(1.0)},
+	}
+
+	for _, m := range metrics {
+		err := writeRepo.Save(ctx, m)
+		require.NoError(t, err)
+	}
+
+	var walked []string
+	err := readRepo.Walk(ctx, func(m *models.Metrics) error {
+		walked = append(walked, m.ID)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"m1", "m2"}, walked)
+
+	err = readRepo.Walk(ctx, func(m *models.Metrics) error {
+		return assert.AnError
+	})
+	assert.ErrorIs(t, err, assert.AnError)
+}
+
 func TestMetricReadRepository_Get_NotFound(t *testing.T) {
 	ctx, cleanup := setupPostgres(t)
 	defer cleanup()
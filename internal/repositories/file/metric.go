@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"io"
 	"os"
 	"sort"
 	"sync"
@@ -24,6 +25,11 @@ func NewMetricWriteRepository(path string) *MetricWriteRepository {
 
 // Save appends a metric to the file.
 func (r *MetricWriteRepository) Save(ctx context.Context, metric *models.Metrics) error {
+	return r.SaveBatch(ctx, []*models.Metrics{metric})
+}
+
+// SaveBatch appends the metrics to the file with a single sync.
+func (r *MetricWriteRepository) SaveBatch(ctx context.Context, metrics []*models.Metrics) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
@@ -35,8 +41,10 @@ func (r *MetricWriteRepository) Save(ctx context.Context, metric *models.Metrics
 
 	writer := bufio.NewWriter(file)
 	encoder := json.NewEncoder(writer)
-	if err := encoder.Encode(metric); err != nil {
-		return err
+	for _, metric := range metrics {
+		if err := encoder.Encode(metric); err != nil {
+			return err
+		}
 	}
 
 	if err := writer.Flush(); err != nil {
@@ -100,6 +108,59 @@ func (r *MetricReadRepository) List(ctx context.Context) ([]*models.Metrics, err
 	return metricsSlice, nil
 }
 
+// Walk calls fn for the last saved value of each metric, in the order of the
+// file. The file is read twice, first to find the line holding the last value
+// of each metric, so that only the metric IDs are kept in memory.
+func (r *MetricReadRepository) Walk(ctx context.Context, fn func(*models.Metrics) error) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	file, err := os.Open(r.metricFilePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil // no metrics saved yet
+		}
+		return err
+	}
+	defer file.Close()
+
+	last := make(map[models.MetricID]int)
+	err = scanMetrics(file, func(line int, m *models.Metrics) error {
+		last[models.MetricID{ID: m.ID, MType: m.MType}] = line
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	return scanMetrics(file, func(line int, m *models.Metrics) error {
+		if last[models.MetricID{ID: m.ID, MType: m.MType}] != line {
+			return nil // overwritten later in the file
+		}
+		return fn(m)
+	})
+}
+
+// scanMetrics decodes the metrics of r line by line and calls fn for each
+// of them with its line number.
+func scanMetrics(r io.Reader, fn func(line int, m *models.Metrics) error) error {
+	scanner := bufio.NewScanner(r)
+	for line := 0; scanner.Scan(); line++ {
+		var m models.Metrics
+		if err := json.Unmarshal(scanner.Bytes(), &m); err != nil {
+			return err
+		}
+		if err := fn(line, &m); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
 // Get fetches the last matching metric from file by ID and type.
 func (r *MetricReadRepository) Get(ctx context.Context, id models.MetricID) (*models.Metrics, error) {
 	r.mu.RLock()
@@ -72,6 +72,72 @@ func TestMetricWriteRepository_SaveAndRead(t *testing.T) {
 	assert.Nil(t, m)
 }
 
+func TestMetricWriteRepository_SaveBatch(t *testing.T) {
+	ctx := context.Background()
+	filePath := filepath.Join(t.TempDir(), "metrics.json")
+
+	writerRepo := NewMetricWriteRepository(filePath)
+	readerRepo := NewMetricReadRepository(filePath)
+
+	err := writerRepo.SaveBatch(ctx, []*models.Metrics{
+		{ID: "Alloc", MType: "gauge", Value: float64Ptr(1)},
+		{ID: "PollCount", MType: "counter", Delta: int64Ptr(10)},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, writerRepo.SaveBatch(ctx, nil))
+
+	metrics, err := readerRepo.List(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, metrics, 2) {
+		assert.Equal(t, "Alloc", metrics[0].ID)
+		assert.Equal(t, "PollCount", metrics[1].ID)
+	}
+}
+
+func TestMetricReadRepository_Walk(t *testing.T) {
+	ctx := context.Background()
+	filePath := filepath.Join(t.TempDir(), "metrics.json")
+
+	writerRepo := NewMetricWriteRepository(filePath)
+	readerRepo := NewMetricReadRepository(filePath)
+
+	var walked []*models.Metrics
+	collect := func(m *models.Metrics) error {
+		walked = append(walked, m)
+		return nil
+	}
+
+	// No file yet
+	assert.NoError(t, readerRepo.Walk(ctx, collect))
+	assert.Empty(t, walked)
+
+	for _, m := range []*models.Metrics{
+		{ID: "Alloc", MType: "gauge", Value: float64Ptr(1)},
+		{ID: "PollCount", MType: "counter", Delta: int64Ptr(10)},
+		{ID: "Alloc", MType: "gauge", Value: float64Ptr(2)},
+	} {
+		assert.NoError(t, writerRepo.Save(ctx, m))
+	}
+
+	// Only the last value of each metric is walked
+	assert.NoError(t, readerRepo.Walk(ctx, collect))
+	if assert.Len(t, walked, 2) {
+		assert.Equal(t, "PollCount", walked[0].ID)
+		assert.Equal(t, int64(10), *walked[0].Delta)
+		assert.Equal(t, "Alloc", walked[1].ID)
+		assert.Equal(t, 2.0, *walked[1].Value)
+	}
+
+	// An error of fn stops the walk
+	calls := 0
+	err := readerRepo.Walk(ctx, func(m *models.Metrics) error {
+		calls++
+		return assert.AnError
+	})
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.Equal(t, 1, calls)
+}
+
 func float64Ptr(f float64) *float64 {
 	return &f
 }
@@ -0,0 +1,92 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sbilibin2017/gophmetrics/internal/models"
+)
+
+// AgentWriteRepository provides write access to in-memory agents.
+type AgentWriteRepository struct {
+	mu   *sync.RWMutex
+	data map[string]models.Agent
+}
+
+// NewAgentWriteRepository creates a new AgentWriteRepository.
+func NewAgentWriteRepository(
+	mu *sync.RWMutex,
+	data map[string]models.Agent,
+) *AgentWriteRepository {
+	return &AgentWriteRepository{mu: mu, data: data}
+}
+
+// Save adds or updates an agent in the repository.
+// The creation time of an already stored agent is preserved.
+func (r *AgentWriteRepository) Save(
+	ctx context.Context,
+	agent *models.Agent,
+) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored := *agent
+	if existing, ok := r.data[agent.ID]; ok && !existing.CreatedAt.IsZero() {
+		stored.CreatedAt = existing.CreatedAt
+	}
+
+	r.data[agent.ID] = stored
+	return nil
+}
+
+// DeleteBefore removes the agents last updated before t.
+func (r *AgentWriteRepository) DeleteBefore(
+	ctx context.Context,
+	t time.Time,
+) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, agent := range r.data {
+		if agent.UpdatedAt.Before(t) {
+			delete(r.data, id)
+		}
+	}
+	return nil
+}
+
+// AgentReadRepository provides read access to in-memory agents.
+type AgentReadRepository struct {
+	mu   *sync.RWMutex
+	data map[string]models.Agent
+}
+
+// NewAgentReadRepository creates a new AgentReadRepository.
+func NewAgentReadRepository(
+	mu *sync.RWMutex,
+	data map[string]models.Agent,
+) *AgentReadRepository {
+	return &AgentReadRepository{mu: mu, data: data}
+}
+
+// List returns all agents sorted by ID.
+func (r *AgentReadRepository) List(
+	ctx context.Context,
+) ([]*models.Agent, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	agents := make([]*models.Agent, 0, len(r.data))
+	for _, a := range r.data {
+		agent := a
+		agents = append(agents, &agent)
+	}
+
+	sort.Slice(agents, func(i, j int) bool {
+		return agents[i].ID < agents[j].ID
+	})
+
+	return agents, nil
+}
@@ -0,0 +1,53 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sbilibin2017/gophmetrics/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAgentRepositories(t *testing.T) {
+	ctx := context.Background()
+	mu := &sync.RWMutex{}
+	data := make(map[string]models.Agent)
+
+	writer := NewAgentWriteRepository(mu, data)
+	reader := NewAgentReadRepository(mu, data)
+
+	first := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	second := first.Add(time.Minute)
+
+	require.NoError(t, writer.Save(ctx, &models.Agent{ID: "b", CreatedAt: first, UpdatedAt: first}))
+	require.NoError(t, writer.Save(ctx, &models.Agent{ID: "a", CreatedAt: first, UpdatedAt: first}))
+	require.NoError(t, writer.Save(ctx, &models.Agent{ID: "b", CreatedAt: second, UpdatedAt: second}))
+
+	agents, err := reader.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, agents, 2)
+
+	assert.Equal(t, "a", agents[0].ID)
+	assert.Equal(t, "b", agents[1].ID)
+	assert.Equal(t, first, agents[1].CreatedAt)
+	assert.Equal(t, second, agents[1].UpdatedAt)
+}
+
+func TestAgentWriteRepository_DeleteBefore(t *testing.T) {
+	ctx := context.Background()
+	mu := &sync.RWMutex{}
+	data := make(map[string]models.Agent)
+	writer := NewAgentWriteRepository(mu, data)
+
+	old := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	recent := old.Add(time.Hour)
+	require.NoError(t, writer.Save(ctx, &models.Agent{ID: "old", UpdatedAt: old}))
+	require.NoError(t, writer.Save(ctx, &models.Agent{ID: "recent", UpdatedAt: recent}))
+
+	require.NoError(t, writer.DeleteBefore(ctx, recent))
+	assert.NotContains(t, data, "old")
+	assert.Contains(t, data, "recent")
+}
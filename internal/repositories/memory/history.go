@@ -0,0 +1,70 @@
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"github.com/sbilibin2017/gophmetrics/internal/models"
+)
+
+// MetricHistoryRepository keeps the last values of every metric in memory.
+type MetricHistoryRepository struct {
+	mu   sync.RWMutex
+	size int
+	data map[models.MetricID][]float64
+}
+
+// NewMetricHistoryRepository creates a new MetricHistoryRepository
+// keeping up to size values per metric. A non-positive size keeps nothing.
+func NewMetricHistoryRepository(size int) *MetricHistoryRepository {
+	return &MetricHistoryRepository{
+		size: size,
+		data: make(map[models.MetricID][]float64),
+	}
+}
+
+// Append records the current value of the metric, dropping the oldest
+// value once the history is full.
+func (r *MetricHistoryRepository) Append(
+	ctx context.Context,
+	metric *models.Metrics,
+) error {
+	if r.size <= 0 {
+		return nil
+	}
+
+	var value float64
+	switch {
+	case metric.Value != nil:
+		value = *metric.Value
+	case metric.Delta != nil:
+		value = float64(*metric.Delta)
+	default:
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := models.MetricID{ID: metric.ID, MType: metric.MType}
+	values := append(r.data[key], value)
+	if len(values) > r.size {
+		values = values[len(values)-r.size:]
+	}
+	r.data[key] = values
+	return nil
+}
+
+// History returns the recorded values of the metric from oldest to newest.
+func (r *MetricHistoryRepository) History(
+	ctx context.Context,
+	id models.MetricID,
+) ([]float64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	values := r.data[id]
+	result := make([]float64, len(values))
+	copy(result, values)
+	return result, nil
+}
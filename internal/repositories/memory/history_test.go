@@ -0,0 +1,47 @@
+package memory
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sbilibin2017/gophmetrics/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricHistoryRepository(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMetricHistoryRepository(3)
+
+	gaugeID := models.MetricID{ID: "g", MType: models.Gauge}
+	counterID := models.MetricID{ID: "c", MType: models.Counter}
+
+	for _, v := range []float64{1, 2, 3, 4} {
+		require.NoError(t, repo.Append(ctx, &models.Metrics{ID: "g", MType: models.Gauge, Value: ptrFloat64(v)}))
+	}
+	require.NoError(t, repo.Append(ctx, &models.Metrics{ID: "c", MType: models.Counter, Delta: ptrInt64(7)}))
+	require.NoError(t, repo.Append(ctx, &models.Metrics{ID: "empty", MType: models.Gauge}))
+
+	values, err := repo.History(ctx, gaugeID)
+	require.NoError(t, err)
+	assert.Equal(t, []float64{2, 3, 4}, values)
+
+	values, err = repo.History(ctx, counterID)
+	require.NoError(t, err)
+	assert.Equal(t, []float64{7}, values)
+
+	values, err = repo.History(ctx, models.MetricID{ID: "empty", MType: models.Gauge})
+	require.NoError(t, err)
+	assert.Empty(t, values)
+}
+
+func TestMetricHistoryRepository_Disabled(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMetricHistoryRepository(0)
+
+	require.NoError(t, repo.Append(ctx, &models.Metrics{ID: "g", MType: models.Gauge, Value: ptrFloat64(1)}))
+
+	values, err := repo.History(ctx, models.MetricID{ID: "g", MType: models.Gauge})
+	require.NoError(t, err)
+	assert.Empty(t, values)
+}
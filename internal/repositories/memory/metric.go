@@ -22,6 +22,7 @@ func NewMetricWriteRepository(
 }
 
 // Save adds or updates a metric in the repository.
+// The creation time of an already stored metric is preserved.
 func (r *MetricWriteRepository) Save(
 	ctx context.Context,
 	metric *models.Metrics,
@@ -34,7 +35,12 @@ func (r *MetricWriteRepository) Save(
 		MType: metric.MType,
 	}
 
-	r.data[key] = *metric
+	stored := *metric
+	if existing, ok := r.data[key]; ok && !existing.CreatedAt.IsZero() {
+		stored.CreatedAt = existing.CreatedAt
+	}
+
+	r.data[key] = stored
 	return nil
 }
 
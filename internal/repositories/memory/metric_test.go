@@ -3,6 +3,7 @@ package memory
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/sbilibin2017/gophmetrics/internal/models"
 	"github.com/stretchr/testify/assert"
@@ -135,6 +136,24 @@ func TestMetricReadRepository_List(t *testing.T) {
 	assert.Equal(t, "z_metric", metrics[1].ID)
 }
 
+// Test that saving an existing metric keeps its creation time.
+func TestMetricWriteRepository_SavePreservesCreatedAt(t *testing.T) {
+	ctx := context.Background()
+	data := make(map[models.MetricID]models.Metrics)
+	repo := NewMetricWriteRepository(data)
+
+	createdAt := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	updatedAt := createdAt.Add(time.Hour)
+
+	assert.NoError(t, repo.Save(ctx, &models.Metrics{ID: "g", MType: models.Gauge, Value: ptrFloat64(1), CreatedAt: createdAt, UpdatedAt: createdAt}))
+	assert.NoError(t, repo.Save(ctx, &models.Metrics{ID: "g", MType: models.Gauge, Value: ptrFloat64(2), CreatedAt: updatedAt, UpdatedAt: updatedAt}))
+
+	stored := data[models.MetricID{ID: "g", MType: models.Gauge}]
+	assert.Equal(t, createdAt, stored.CreatedAt)
+	assert.Equal(t, updatedAt, stored.UpdatedAt)
+	assert.Equal(t, 2.0, *stored.Value)
+}
+
 // Helper to get *int64
 func ptrInt64(v int64) *int64 {
 	return &v
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sbilibin2017/gophmetrics/internal/models"
+)
+
+// AgentWriter defines the interface for saving agents.
+type AgentWriter interface {
+	// Save persists the given agent.
+	Save(ctx context.Context, agent *models.Agent) error
+	// DeleteBefore removes the agents last updated before t.
+	DeleteBefore(ctx context.Context, t time.Time) error
+}
+
+// AgentReader defines the interface for retrieving agents.
+type AgentReader interface {
+	// List retrieves all known agents.
+	List(ctx context.Context) ([]*models.Agent, error)
+}
+
+// agentSweepInterval is how often agents past their retention are removed.
+const agentSweepInterval = time.Minute
+
+// AgentService tracks when reporting sources were last seen.
+type AgentService struct {
+	writer     AgentWriter
+	reader     AgentReader
+	staleAfter time.Duration
+	deadAfter  time.Duration
+	retention  time.Duration
+
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+// AgentOpt defines a functional option type used to configure an AgentService.
+type AgentOpt func(*AgentService)
+
+// WithRetention removes agents that have not reported for retention,
+// so that the number of tracked agents stays bounded. A non-positive value
+// keeps agents forever.
+func WithRetention(retention time.Duration) AgentOpt {
+	return func(svc *AgentService) {
+		svc.retention = retention
+	}
+}
+
+// NewAgentService creates a new AgentService.
+// An agent is considered stale when it has not reported for staleAfter
+// and dead when it has not reported for deadAfter.
+func NewAgentService(
+	writer AgentWriter,
+	reader AgentReader,
+	staleAfter time.Duration,
+	deadAfter time.Duration,
+	opts ...AgentOpt,
+) *AgentService {
+	svc := &AgentService{
+		writer:     writer,
+		reader:     reader,
+		staleAfter: staleAfter,
+		deadAfter:  deadAfter,
+	}
+	for _, opt := range opts {
+		opt(svc)
+	}
+	return svc
+}
+
+// Touch records that the agent with the given ID has just reported.
+// At most once per agentSweepInterval it also removes the agents that have
+// not reported for the retention.
+func (svc *AgentService) Touch(ctx context.Context, id string) error {
+	now := time.Now()
+	if err := svc.writer.Save(ctx, &models.Agent{
+		ID:        id,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}); err != nil {
+		return err
+	}
+
+	if svc.retention <= 0 || !svc.sweepDue(now) {
+		return nil
+	}
+	return svc.writer.DeleteBefore(ctx, now.Add(-svc.retention))
+}
+
+// sweepDue reports whether agentSweepInterval has passed since the last sweep
+// and, if so, starts a new one.
+func (svc *AgentService) sweepDue(now time.Time) bool {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+
+	if now.Sub(svc.lastSweep) < agentSweepInterval {
+		return false
+	}
+	svc.lastSweep = now
+	return true
+}
+
+// List returns all known agents with their current status.
+func (svc *AgentService) List(ctx context.Context) ([]*models.Agent, error) {
+	agents, err := svc.reader.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for _, a := range agents {
+		a.Status = agentStatus(now.Sub(a.UpdatedAt), svc.staleAfter, svc.deadAfter)
+	}
+
+	return agents, nil
+}
+
+// agentStatus returns the agent status for the time elapsed since its last report.
+// Non-positive thresholds disable the corresponding status.
+func agentStatus(elapsed, staleAfter, deadAfter time.Duration) string {
+	switch {
+	case deadAfter > 0 && elapsed >= deadAfter:
+		return models.AgentDead
+	case staleAfter > 0 && elapsed >= staleAfter:
+		return models.AgentStale
+	default:
+		return models.AgentLive
+	}
+}
@@ -0,0 +1,103 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: internal/services/agent.go
+
+// Package services is a generated GoMock package.
+package services
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+
+	gomock "github.com/golang/mock/gomock"
+	models "github.com/sbilibin2017/gophmetrics/internal/models"
+)
+
+// MockAgentWriter is a mock of AgentWriter interface.
+type MockAgentWriter struct {
+	ctrl     *gomock.Controller
+	recorder *MockAgentWriterMockRecorder
+}
+
+// MockAgentWriterMockRecorder is the mock recorder for MockAgentWriter.
+type MockAgentWriterMockRecorder struct {
+	mock *MockAgentWriter
+}
+
+// NewMockAgentWriter creates a new mock instance.
+func NewMockAgentWriter(ctrl *gomock.Controller) *MockAgentWriter {
+	mock := &MockAgentWriter{ctrl: ctrl}
+	mock.recorder = &MockAgentWriterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAgentWriter) EXPECT() *MockAgentWriterMockRecorder {
+	return m.recorder
+}
+
+// DeleteBefore mocks base method.
+func (m *MockAgentWriter) DeleteBefore(ctx context.Context, t time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteBefore", ctx, t)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteBefore indicates an expected call of DeleteBefore.
+func (mr *MockAgentWriterMockRecorder) DeleteBefore(ctx, t interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteBefore", reflect.TypeOf((*MockAgentWriter)(nil).DeleteBefore), ctx, t)
+}
+
+// Save mocks base method.
+func (m *MockAgentWriter) Save(ctx context.Context, agent *models.Agent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Save", ctx, agent)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Save indicates an expected call of Save.
+func (mr *MockAgentWriterMockRecorder) Save(ctx, agent interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Save", reflect.TypeOf((*MockAgentWriter)(nil).Save), ctx, agent)
+}
+
+// MockAgentReader is a mock of AgentReader interface.
+type MockAgentReader struct {
+	ctrl     *gomock.Controller
+	recorder *MockAgentReaderMockRecorder
+}
+
+// MockAgentReaderMockRecorder is the mock recorder for MockAgentReader.
+type MockAgentReaderMockRecorder struct {
+	mock *MockAgentReader
+}
+
+// NewMockAgentReader creates a new mock instance.
+func NewMockAgentReader(ctrl *gomock.Controller) *MockAgentReader {
+	mock := &MockAgentReader{ctrl: ctrl}
+	mock.recorder = &MockAgentReaderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAgentReader) EXPECT() *MockAgentReaderMockRecorder {
+	return m.recorder
+}
+
+// List mocks base method.
+func (m *MockAgentReader) List(ctx context.Context) ([]*models.Agent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", ctx)
+	ret0, _ := ret[0].([]*models.Agent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockAgentReaderMockRecorder) List(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockAgentReader)(nil).List), ctx)
+}
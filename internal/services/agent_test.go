@@ -0,0 +1,103 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/sbilibin2017/gophmetrics/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAgentService_Touch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockWriter := NewMockAgentWriter(ctrl)
+	svc := NewAgentService(mockWriter, NewMockAgentReader(ctrl), time.Minute, time.Hour)
+
+	ctx := context.Background()
+	mockWriter.EXPECT().Save(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, a *models.Agent) error {
+		assert.Equal(t, "10.0.0.1", a.ID)
+		assert.False(t, a.UpdatedAt.IsZero())
+		return nil
+	})
+
+	assert.NoError(t, svc.Touch(ctx, "10.0.0.1"))
+}
+
+func TestAgentService_Touch_ForgetAfter(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockWriter := NewMockAgentWriter(ctrl)
+	svc := NewAgentService(mockWriter, NewMockAgentReader(ctrl), time.Minute, time.Hour, WithRetention(2*time.Hour))
+
+	ctx := context.Background()
+	mockWriter.EXPECT().Save(ctx, gomock.Any()).Return(nil).Times(2)
+	mockWriter.EXPECT().DeleteBefore(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, before time.Time) error {
+		assert.WithinDuration(t, time.Now().Add(-2*time.Hour), before, time.Second)
+		return nil
+	}).Times(1)
+
+	assert.NoError(t, svc.Touch(ctx, "agent-1"))
+	assert.NoError(t, svc.Touch(ctx, "agent-2"), "swept at most once per interval")
+}
+
+func TestAgentService_List(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockReader := NewMockAgentReader(ctrl)
+	svc := NewAgentService(NewMockAgentWriter(ctrl), mockReader, time.Minute, time.Hour)
+
+	ctx := context.Background()
+	now := time.Now()
+
+	t.Run("computes status", func(t *testing.T) {
+		mockReader.EXPECT().List(ctx).Return([]*models.Agent{
+			{ID: "live", UpdatedAt: now},
+			{ID: "stale", UpdatedAt: now.Add(-2 * time.Minute)},
+			{ID: "dead", UpdatedAt: now.Add(-2 * time.Hour)},
+		}, nil)
+
+		agents, err := svc.List(ctx)
+		require.NoError(t, err)
+		require.Len(t, agents, 3)
+		assert.Equal(t, models.AgentLive, agents[0].Status)
+		assert.Equal(t, models.AgentStale, agents[1].Status)
+		assert.Equal(t, models.AgentDead, agents[2].Status)
+	})
+
+	t.Run("reader error", func(t *testing.T) {
+		mockReader.EXPECT().List(ctx).Return(nil, errors.New("fail"))
+
+		agents, err := svc.List(ctx)
+		assert.Error(t, err)
+		assert.Nil(t, agents)
+	})
+}
+
+func Test_agentStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		elapsed    time.Duration
+		staleAfter time.Duration
+		deadAfter  time.Duration
+		want       string
+	}{
+		{"fresh", time.Second, time.Minute, time.Hour, models.AgentLive},
+		{"stale boundary", time.Minute, time.Minute, time.Hour, models.AgentStale},
+		{"dead", 2 * time.Hour, time.Minute, time.Hour, models.AgentDead},
+		{"thresholds disabled", 24 * time.Hour, 0, 0, models.AgentLive},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, agentStatus(tt.elapsed, tt.staleAfter, tt.deadAfter))
+		})
+	}
+}
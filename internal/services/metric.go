@@ -2,6 +2,8 @@ package services
 
 import (
 	"context"
+	"slices"
+	"time"
 
 	"github.com/sbilibin2017/gophmetrics/internal/models"
 )
@@ -20,28 +22,127 @@ type Reader interface {
 	List(ctx context.Context) ([]*models.Metrics, error)
 }
 
+// HistoryWriter defines the interface for recording metric values over time.
+type HistoryWriter interface {
+	// Append records the current value of the metric.
+	Append(ctx context.Context, metric *models.Metrics) error
+}
+
+// Quota limits the distinct metrics a client may report.
+type Quota interface {
+	// Admit returns an error if the metrics may not be updated by the
+	// client making the call, admitting all of them or none. It returns the
+	// metrics the client had not reported before.
+	Admit(ctx context.Context, ids ...models.MetricID) ([]models.MetricID, error)
+	// Release gives back admitted metrics that were not updated.
+	Release(ctx context.Context, ids ...models.MetricID)
+}
+
 // MetricService provides methods to manage metrics.
 type MetricService struct {
-	writer Writer
-	reader Reader
+	writer  Writer
+	reader  Reader
+	history HistoryWriter
+	quota   Quota
+}
+
+// Opt defines a functional option type used to configure a MetricService.
+type Opt func(*MetricService)
+
+// WithHistory records every updated metric in the given history.
+func WithHistory(history HistoryWriter) Opt {
+	return func(svc *MetricService) {
+		svc.history = history
+	}
+}
+
+// WithQuota checks every updated metric against the given quota.
+func WithQuota(quota Quota) Opt {
+	return func(svc *MetricService) {
+		svc.quota = quota
+	}
 }
 
 // NewMetricService creates a new MetricService with the given writer and reader.
 func NewMetricService(
 	writer Writer,
 	reader Reader,
+	opts ...Opt,
 ) *MetricService {
-	return &MetricService{
+	svc := &MetricService{
 		writer: writer,
 		reader: reader,
 	}
+	for _, opt := range opts {
+		opt(svc)
+	}
+	return svc
 }
 
-// Update updates the provided metric.
+// Update updates the provided metric and stamps it with the update time.
+// The quota error is returned as is if the metric is not admitted.
 func (svc *MetricService) Update(
 	ctx context.Context,
 	metric *models.Metrics,
 ) (*models.Metrics, error) {
+	updated, err := svc.UpdateBatch(ctx, []*models.Metrics{metric})
+	if err != nil {
+		return nil, err
+	}
+	return updated[0], nil
+}
+
+// UpdateBatch updates the provided metrics in order, like Update. The quota
+// admits all of them before any is updated, so that nothing is updated if
+// it returns an error. Metrics left unsaved by an error are given back to
+// the quota.
+func (svc *MetricService) UpdateBatch(
+	ctx context.Context,
+	metrics []*models.Metrics,
+) ([]*models.Metrics, error) {
+	var admitted []models.MetricID
+	if svc.quota != nil {
+		ids := make([]models.MetricID, len(metrics))
+		for i, metric := range metrics {
+			ids[i] = models.MetricID{ID: metric.ID, MType: metric.MType}
+		}
+		var err error
+		admitted, err = svc.quota.Admit(ctx, ids...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	updated := make([]*models.Metrics, 0, len(metrics))
+	for i, metric := range metrics {
+		metric, err := svc.save(ctx, metric)
+		if err != nil {
+			svc.release(ctx, admitted, metrics[:i])
+			return nil, err
+		}
+		if svc.history != nil {
+			if err := svc.history.Append(ctx, metric); err != nil {
+				svc.release(ctx, admitted, metrics[:i+1])
+				return nil, err
+			}
+		}
+		updated = append(updated, metric)
+	}
+	return updated, nil
+}
+
+// save stamps the metric with the update time, adds the stored Delta to a
+// counter and saves the result.
+func (svc *MetricService) save(
+	ctx context.Context,
+	metric *models.Metrics,
+) (*models.Metrics, error) {
+	now := time.Now()
+	metric.UpdatedAt = now
+	if metric.CreatedAt.IsZero() {
+		metric.CreatedAt = now
+	}
+
 	if metric.MType == models.Counter {
 		var err error
 		metric, err = updateCounter(ctx, svc.reader, metric)
@@ -49,15 +150,35 @@ func (svc *MetricService) Update(
 			return nil, err
 		}
 	}
-	err := svc.writer.Save(ctx, metric)
-	if err != nil {
+	if err := svc.writer.Save(ctx, metric); err != nil {
 		return nil, err
 	}
 	return metric, nil
 }
 
+// release gives the admitted metrics back to the quota, except those among
+// the saved ones.
+func (svc *MetricService) release(
+	ctx context.Context,
+	admitted []models.MetricID,
+	saved []*models.Metrics,
+) {
+	var unsaved []models.MetricID
+	for _, id := range admitted {
+		if !slices.ContainsFunc(saved, func(m *models.Metrics) bool {
+			return m.ID == id.ID && m.MType == id.MType
+		}) {
+			unsaved = append(unsaved, id)
+		}
+	}
+	if len(unsaved) > 0 {
+		svc.quota.Release(ctx, unsaved...)
+	}
+}
+
 // updateCounter updates the Delta value of the given metric by retrieving
 // the existing counter from the reader and summing the Deltas.
+// The creation time of the existing counter is preserved.
 func updateCounter(
 	ctx context.Context,
 	reader Reader,
@@ -74,6 +195,9 @@ func updateCounter(
 	if existing != nil && existing.Delta != nil && metric.Delta != nil {
 		*metric.Delta += *existing.Delta
 	}
+	if existing != nil && !existing.CreatedAt.IsZero() {
+		metric.CreatedAt = existing.CreatedAt
+	}
 
 	return metric, nil
 }
@@ -1,5 +1,5 @@
 // Code generated by MockGen. DO NOT EDIT.
-// Source: /home/sergey/Github/gophmetrics/internal/services/metric.go
+// Source: internal/services/metric.go
 
 // Package services is a generated GoMock package.
 package services
@@ -101,3 +101,100 @@ func (mr *MockReaderMockRecorder) List(ctx interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockReader)(nil).List), ctx)
 }
+
+// MockHistoryWriter is a mock of HistoryWriter interface.
+type MockHistoryWriter struct {
+	ctrl     *gomock.Controller
+	recorder *MockHistoryWriterMockRecorder
+}
+
+// MockHistoryWriterMockRecorder is the mock recorder for MockHistoryWriter.
+type MockHistoryWriterMockRecorder struct {
+	mock *MockHistoryWriter
+}
+
+// NewMockHistoryWriter creates a new mock instance.
+func NewMockHistoryWriter(ctrl *gomock.Controller) *MockHistoryWriter {
+	mock := &MockHistoryWriter{ctrl: ctrl}
+	mock.recorder = &MockHistoryWriterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockHistoryWriter) EXPECT() *MockHistoryWriterMockRecorder {
+	return m.recorder
+}
+
+// Append mocks base method.
+func (m *MockHistoryWriter) Append(ctx context.Context, metric *models.Metrics) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Append", ctx, metric)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Append indicates an expected call of Append.
+func (mr *MockHistoryWriterMockRecorder) Append(ctx, metric interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Append", reflect.TypeOf((*MockHistoryWriter)(nil).Append), ctx, metric)
+}
+
+// MockQuota is a mock of Quota interface.
+type MockQuota struct {
+	ctrl     *gomock.Controller
+	recorder *MockQuotaMockRecorder
+}
+
+// MockQuotaMockRecorder is the mock recorder for MockQuota.
+type MockQuotaMockRecorder struct {
+	mock *MockQuota
+}
+
+// NewMockQuota creates a new mock instance.
+func NewMockQuota(ctrl *gomock.Controller) *MockQuota {
+	mock := &MockQuota{ctrl: ctrl}
+	mock.recorder = &MockQuotaMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockQuota) EXPECT() *MockQuotaMockRecorder {
+	return m.recorder
+}
+
+// Admit mocks base method.
+func (m *MockQuota) Admit(ctx context.Context, ids ...models.MetricID) ([]models.MetricID, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx}
+	for _, a := range ids {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Admit", varargs...)
+	ret0, _ := ret[0].([]models.MetricID)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Admit indicates an expected call of Admit.
+func (mr *MockQuotaMockRecorder) Admit(ctx interface{}, ids ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx}, ids...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Admit", reflect.TypeOf((*MockQuota)(nil).Admit), varargs...)
+}
+
+// Release mocks base method.
+func (m *MockQuota) Release(ctx context.Context, ids ...models.MetricID) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx}
+	for _, a := range ids {
+		varargs = append(varargs, a)
+	}
+	m.ctrl.Call(m, "Release", varargs...)
+}
+
+// Release indicates an expected call of Release.
+func (mr *MockQuotaMockRecorder) Release(ctx interface{}, ids ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx}, ids...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Release", reflect.TypeOf((*MockQuota)(nil).Release), varargs...)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/sbilibin2017/gophmetrics/internal/models"
@@ -115,6 +116,7 @@ func TestMetricService_Save(t *testing.T) {
 
 	counterDelta := int64(10)
 	currentDelta := int64(5)
+	createdAt := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
 
 	tests := []struct {
 		name          string
@@ -136,13 +138,17 @@ func TestMetricService_Save(t *testing.T) {
 				mockReader.EXPECT().
 					Get(ctx, models.MetricID{ID: "counter1", MType: models.Counter}).
 					Return(&models.Metrics{
-						ID:    "counter1",
-						MType: models.Counter,
-						Delta: ptrInt64(currentDelta),
+						ID:        "counter1",
+						MType:     models.Counter,
+						Delta:     ptrInt64(currentDelta),
+						CreatedAt: createdAt,
 					}, nil)
 				mockWriter.EXPECT().
 					Save(ctx, gomock.AssignableToTypeOf(&models.Metrics{})).
-					Return(nil)
+					DoAndReturn(func(_ context.Context, m *models.Metrics) error {
+						assert.Equal(t, createdAt, m.CreatedAt)
+						return nil
+					})
 			},
 		},
 		{
@@ -200,6 +206,8 @@ func TestMetricService_Save(t *testing.T) {
 			} else {
 				assert.NoError(t, err)
 				assert.NotNil(t, res)
+				assert.False(t, res.UpdatedAt.IsZero())
+				assert.False(t, res.CreatedAt.IsZero())
 				if tt.metric.MType == models.Counter {
 					assert.NotNil(t, res.Delta)
 					assert.Equal(t, tt.expectedDelta, *res.Delta)
@@ -320,3 +328,126 @@ func ptrInt64(v int64) *int64 {
 func ptrFloat64(v float64) *float64 {
 	return &v
 }
+
+func TestMetricService_UpdateWithHistory(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockWriter := NewMockWriter(ctrl)
+	mockReader := NewMockReader(ctrl)
+	mockHistory := NewMockHistoryWriter(ctrl)
+
+	svc := NewMetricService(mockWriter, mockReader, WithHistory(mockHistory))
+	ctx := context.Background()
+
+	metric := &models.Metrics{ID: "gauge1", MType: models.Gauge, Value: ptrFloat64(1)}
+
+	t.Run("history appended after save", func(t *testing.T) {
+		gomock.InOrder(
+			mockWriter.EXPECT().Save(ctx, metric).Return(nil),
+			mockHistory.EXPECT().Append(ctx, metric).Return(nil),
+		)
+
+		res, err := svc.Update(ctx, metric)
+		assert.NoError(t, err)
+		assert.Equal(t, metric, res)
+	})
+
+	t.Run("history error", func(t *testing.T) {
+		mockWriter.EXPECT().Save(ctx, metric).Return(nil)
+		mockHistory.EXPECT().Append(ctx, metric).Return(errors.New("history error"))
+
+		res, err := svc.Update(ctx, metric)
+		assert.Error(t, err)
+		assert.Nil(t, res)
+	})
+}
+
+func TestMetricService_UpdateWithQuota(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockWriter := NewMockWriter(ctrl)
+	mockReader := NewMockReader(ctrl)
+	mockQuota := NewMockQuota(ctrl)
+
+	svc := NewMetricService(mockWriter, mockReader, WithQuota(mockQuota))
+	ctx := context.Background()
+
+	metric := &models.Metrics{ID: "gauge1", MType: models.Gauge, Value: ptrFloat64(1)}
+	id := models.MetricID{ID: "gauge1", MType: models.Gauge}
+
+	t.Run("admitted metric is saved", func(t *testing.T) {
+		gomock.InOrder(
+			mockQuota.EXPECT().Admit(ctx, id).Return([]models.MetricID{id}, nil),
+			mockWriter.EXPECT().Save(ctx, metric).Return(nil),
+		)
+
+		res, err := svc.Update(ctx, metric)
+		assert.NoError(t, err)
+		assert.Equal(t, metric, res)
+	})
+
+	t.Run("quota error is returned without saving", func(t *testing.T) {
+		quotaErr := errors.New("quota exceeded")
+		mockQuota.EXPECT().Admit(ctx, id).Return(nil, quotaErr)
+
+		res, err := svc.Update(ctx, metric)
+		assert.ErrorIs(t, err, quotaErr)
+		assert.Nil(t, res)
+	})
+
+	t.Run("failed update gives the metric back", func(t *testing.T) {
+		saveErr := errors.New("save failed")
+		gomock.InOrder(
+			mockQuota.EXPECT().Admit(ctx, id).Return([]models.MetricID{id}, nil),
+			mockWriter.EXPECT().Save(ctx, metric).Return(saveErr),
+			mockQuota.EXPECT().Release(ctx, id),
+		)
+
+		res, err := svc.Update(ctx, metric)
+		assert.ErrorIs(t, err, saveErr)
+		assert.Nil(t, res)
+	})
+}
+
+func TestMetricService_UpdateBatchWithQuota(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockWriter := NewMockWriter(ctrl)
+	mockReader := NewMockReader(ctrl)
+	mockQuota := NewMockQuota(ctrl)
+
+	svc := NewMetricService(mockWriter, mockReader, WithQuota(mockQuota))
+	ctx := context.Background()
+
+	g1 := &models.Metrics{ID: "g1", MType: models.Gauge, Value: ptrFloat64(1)}
+	g2 := &models.Metrics{ID: "g2", MType: models.Gauge, Value: ptrFloat64(2)}
+	g3 := &models.Metrics{ID: "g3", MType: models.Gauge, Value: ptrFloat64(3)}
+	id1 := models.MetricID{ID: "g1", MType: models.Gauge}
+	id2 := models.MetricID{ID: "g2", MType: models.Gauge}
+	id3 := models.MetricID{ID: "g3", MType: models.Gauge}
+
+	t.Run("quota error saves nothing", func(t *testing.T) {
+		mockQuota.EXPECT().Admit(ctx, id1, id2, id3).Return(nil, errors.New("quota exceeded"))
+
+		res, err := svc.UpdateBatch(ctx, []*models.Metrics{g1, g2, g3})
+		assert.Error(t, err)
+		assert.Nil(t, res)
+	})
+
+	t.Run("unsaved metrics are given back", func(t *testing.T) {
+		saveErr := errors.New("save failed")
+		gomock.InOrder(
+			mockQuota.EXPECT().Admit(ctx, id1, id2, id3).Return([]models.MetricID{id1, id2, id3}, nil),
+			mockWriter.EXPECT().Save(ctx, g1).Return(nil),
+			mockWriter.EXPECT().Save(ctx, g2).Return(saveErr),
+			mockQuota.EXPECT().Release(ctx, id2, id3),
+		)
+
+		res, err := svc.UpdateBatch(ctx, []*models.Metrics{g1, g2, g3})
+		assert.ErrorIs(t, err, saveErr)
+		assert.Nil(t, res)
+	})
+}
@@ -0,0 +1,158 @@
+package transfer
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/sbilibin2017/gophmetrics/internal/models"
+)
+
+// Walker reads the metrics of a storage backend one by one.
+type Walker interface {
+	// Walk calls fn for each metric as it is read and stops at the first
+	// error returned by fn.
+	Walk(ctx context.Context, fn func(*models.Metrics) error) error
+}
+
+// Writer saves a metric to a storage backend.
+type Writer interface {
+	Save(ctx context.Context, metric *models.Metrics) error
+}
+
+// BatchWriter is implemented by writers that save many metrics at once
+// cheaper than one by one, e.g. with a single fsync.
+type BatchWriter interface {
+	Writer
+	SaveBatch(ctx context.Context, metrics []*models.Metrics) error
+}
+
+// importBatchSize is the max number of metrics Import passes to a BatchWriter at once.
+const importBatchSize = 1000
+
+// ErrInvalidMetric is returned by Import for a record without ID or with an unknown type.
+var ErrInvalidMetric = errors.New("invalid metric")
+
+// Export writes all metrics of walker to w as NDJSON, one metric per line.
+// Each metric is encoded as soon as it is read, so the whole storage is never
+// held in memory. It returns the number of exported metrics.
+func Export(ctx context.Context, walker Walker, w io.Writer) (int, error) {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+
+	n := 0
+	err := walker.Walk(ctx, func(m *models.Metrics) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := enc.Encode(m); err != nil {
+			return err
+		}
+		n++
+		return nil
+	})
+	if err != nil {
+		return n, err
+	}
+
+	return n, bw.Flush()
+}
+
+// Import reads NDJSON metrics from r and saves them to writer as they are
+// decoded, so arbitrarily large dumps are streamed. A BatchWriter gets up to
+// importBatchSize metrics at once. Blank lines are skipped.
+// It returns the number of imported metrics.
+func Import(ctx context.Context, r io.Reader, writer Writer) (int, error) {
+	size := importBatchSize
+	batcher, ok := writer.(BatchWriter)
+	if !ok {
+		size = 1
+		batcher = singleWriter{writer}
+	}
+	dec := json.NewDecoder(r)
+
+	n := 0
+	batch := make([]*models.Metrics, 0, size)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := batcher.SaveBatch(ctx, batch); err != nil {
+			if len(batch) == 1 {
+				return fmt.Errorf("record %d: %w", n+1, err)
+			}
+			return fmt.Errorf("records %d-%d: %w", n+1, n+len(batch), err)
+		}
+		n += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return n, err
+		}
+
+		var m models.Metrics
+		err := dec.Decode(&m)
+		if errors.Is(err, io.EOF) {
+			err := flush()
+			return n, err
+		}
+		if err == nil {
+			err = validate(&m)
+		}
+		if err != nil {
+			// The records before the broken one are still imported.
+			if err := flush(); err != nil {
+				return n, err
+			}
+			return n, fmt.Errorf("record %d: %w", n+1, err)
+		}
+
+		batch = append(batch, &m)
+		if len(batch) == size {
+			if err := flush(); err != nil {
+				return n, err
+			}
+		}
+	}
+}
+
+// singleWriter saves the metrics of a batch one by one.
+type singleWriter struct {
+	Writer
+}
+
+func (w singleWriter) SaveBatch(ctx context.Context, metrics []*models.Metrics) error {
+	for _, m := range metrics {
+		if err := w.Save(ctx, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validate checks that m can be stored by any backend.
+func validate(m *models.Metrics) error {
+	if strings.TrimSpace(m.ID) == "" {
+		return fmt.Errorf("%w: empty id", ErrInvalidMetric)
+	}
+	switch m.MType {
+	case models.Gauge:
+		if m.Value == nil {
+			return fmt.Errorf("%w: gauge %q without value", ErrInvalidMetric, m.ID)
+		}
+	case models.Counter:
+		if m.Delta == nil {
+			return fmt.Errorf("%w: counter %q without delta", ErrInvalidMetric, m.ID)
+		}
+	default:
+		return fmt.Errorf("%w: unknown type %q", ErrInvalidMetric, m.MType)
+	}
+	return nil
+}
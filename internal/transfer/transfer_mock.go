@@ -0,0 +1,138 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: internal/transfer/transfer.go
+
+// Package transfer is a generated GoMock package.
+package transfer
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	models "github.com/sbilibin2017/gophmetrics/internal/models"
+)
+
+// MockWalker is a mock of Walker interface.
+type MockWalker struct {
+	ctrl     *gomock.Controller
+	recorder *MockWalkerMockRecorder
+}
+
+// MockWalkerMockRecorder is the mock recorder for MockWalker.
+type MockWalkerMockRecorder struct {
+	mock *MockWalker
+}
+
+// NewMockWalker creates a new mock instance.
+func NewMockWalker(ctrl *gomock.Controller) *MockWalker {
+	mock := &MockWalker{ctrl: ctrl}
+	mock.recorder = &MockWalkerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockWalker) EXPECT() *MockWalkerMockRecorder {
+	return m.recorder
+}
+
+// Walk mocks base method.
+func (m *MockWalker) Walk(ctx context.Context, fn func(*models.Metrics) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Walk", ctx, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Walk indicates an expected call of Walk.
+func (mr *MockWalkerMockRecorder) Walk(ctx, fn interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Walk", reflect.TypeOf((*MockWalker)(nil).Walk), ctx, fn)
+}
+
+// MockWriter is a mock of Writer interface.
+type MockWriter struct {
+	ctrl     *gomock.Controller
+	recorder *MockWriterMockRecorder
+}
+
+// MockWriterMockRecorder is the mock recorder for MockWriter.
+type MockWriterMockRecorder struct {
+	mock *MockWriter
+}
+
+// NewMockWriter creates a new mock instance.
+func NewMockWriter(ctrl *gomock.Controller) *MockWriter {
+	mock := &MockWriter{ctrl: ctrl}
+	mock.recorder = &MockWriterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockWriter) EXPECT() *MockWriterMockRecorder {
+	return m.recorder
+}
+
+// Save mocks base method.
+func (m *MockWriter) Save(ctx context.Context, metric *models.Metrics) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Save", ctx, metric)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Save indicates an expected call of Save.
+func (mr *MockWriterMockRecorder) Save(ctx, metric interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Save", reflect.TypeOf((*MockWriter)(nil).Save), ctx, metric)
+}
+
+// MockBatchWriter is a mock of BatchWriter interface.
+type MockBatchWriter struct {
+	ctrl     *gomock.Controller
+	recorder *MockBatchWriterMockRecorder
+}
+
+// MockBatchWriterMockRecorder is the mock recorder for MockBatchWriter.
+type MockBatchWriterMockRecorder struct {
+	mock *MockBatchWriter
+}
+
+// NewMockBatchWriter creates a new mock instance.
+func NewMockBatchWriter(ctrl *gomock.Controller) *MockBatchWriter {
+	mock := &MockBatchWriter{ctrl: ctrl}
+	mock.recorder = &MockBatchWriterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockBatchWriter) EXPECT() *MockBatchWriterMockRecorder {
+	return m.recorder
+}
+
+// Save mocks base method.
+func (m *MockBatchWriter) Save(ctx context.Context, metric *models.Metrics) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Save", ctx, metric)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Save indicates an expected call of Save.
+func (mr *MockBatchWriterMockRecorder) Save(ctx, metric interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Save", reflect.TypeOf((*MockBatchWriter)(nil).Save), ctx, metric)
+}
+
+// SaveBatch mocks base method.
+func (m *MockBatchWriter) SaveBatch(ctx context.Context, metrics []*models.Metrics) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveBatch", ctx, metrics)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveBatch indicates an expected call of SaveBatch.
+func (mr *MockBatchWriterMockRecorder) SaveBatch(ctx, metrics interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveBatch", reflect.TypeOf((*MockBatchWriter)(nil).SaveBatch), ctx, metrics)
+}
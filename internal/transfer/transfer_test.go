@@ -0,0 +1,195 @@
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sbilibin2017/gophmetrics/internal/models"
+)
+
+func ptrFloat64(v float64) *float64 { return &v }
+func ptrInt64(v int64) *int64       { return &v }
+
+func TestExport(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockWalker := NewMockWalker(ctrl)
+	ctx := context.Background()
+
+	walk := func(metrics ...*models.Metrics) func(context.Context, func(*models.Metrics) error) error {
+		return func(ctx context.Context, fn func(*models.Metrics) error) error {
+			for _, m := range metrics {
+				if err := fn(m); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+
+	t.Run("success", func(t *testing.T) {
+		mockWalker.EXPECT().Walk(ctx, gomock.Any()).DoAndReturn(walk(
+			&models.Metrics{ID: "Alloc", MType: models.Gauge, Value: ptrFloat64(1.5)},
+			&models.Metrics{ID: "PollCount", MType: models.Counter, Delta: ptrInt64(3)},
+		))
+
+		var buf bytes.Buffer
+		n, err := Export(ctx, mockWalker, &buf)
+		require.NoError(t, err)
+		assert.Equal(t, 2, n)
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		require.Len(t, lines, 2)
+		assert.Contains(t, lines[0], `"id":"Alloc","type":"gauge","value":1.5`)
+		assert.Contains(t, lines[1], `"id":"PollCount","type":"counter","delta":3`)
+	})
+
+	t.Run("walk error", func(t *testing.T) {
+		mockWalker.EXPECT().Walk(ctx, gomock.Any()).Return(errors.New("walk error"))
+
+		n, err := Export(ctx, mockWalker, &bytes.Buffer{})
+		assert.EqualError(t, err, "walk error")
+		assert.Zero(t, n)
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+		mockWalker.EXPECT().Walk(canceled, gomock.Any()).DoAndReturn(walk(
+			&models.Metrics{ID: "Alloc", MType: models.Gauge, Value: ptrFloat64(1.5)},
+		))
+
+		n, err := Export(canceled, mockWalker, &bytes.Buffer{})
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Zero(t, n)
+	})
+}
+
+func TestImport(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockWriter := NewMockWriter(ctrl)
+	ctx := context.Background()
+
+	t.Run("success", func(t *testing.T) {
+		input := `{"id":"Alloc","type":"gauge","value":1.5}
+
+{"id":"PollCount","type":"counter","delta":3}
+`
+		gomock.InOrder(
+			mockWriter.EXPECT().Save(ctx, &models.Metrics{ID: "Alloc", MType: models.Gauge, Value: ptrFloat64(1.5)}).Return(nil),
+			mockWriter.EXPECT().Save(ctx, &models.Metrics{ID: "PollCount", MType: models.Counter, Delta: ptrInt64(3)}).Return(nil),
+		)
+
+		n, err := Import(ctx, strings.NewReader(input), mockWriter)
+		require.NoError(t, err)
+		assert.Equal(t, 2, n)
+	})
+
+	t.Run("invalid metric", func(t *testing.T) {
+		input := `{"id":"Alloc","type":"gauge","value":1.5}
+{"id":"Bad","type":"histogram"}
+`
+		mockWriter.EXPECT().Save(ctx, gomock.Any()).Return(nil)
+
+		n, err := Import(ctx, strings.NewReader(input), mockWriter)
+		assert.ErrorIs(t, err, ErrInvalidMetric)
+		assert.EqualError(t, err, `record 2: invalid metric: unknown type "histogram"`)
+		assert.Equal(t, 1, n)
+	})
+
+	t.Run("malformed json", func(t *testing.T) {
+		n, err := Import(ctx, strings.NewReader("{"), mockWriter)
+		assert.Error(t, err)
+		assert.Zero(t, n)
+	})
+
+	t.Run("save error", func(t *testing.T) {
+		mockWriter.EXPECT().Save(ctx, gomock.Any()).Return(errors.New("save error"))
+
+		n, err := Import(ctx, strings.NewReader(`{"id":"c","type":"counter","delta":1}`), mockWriter)
+		assert.EqualError(t, err, "record 1: save error")
+		assert.Zero(t, n)
+	})
+}
+
+func TestImport_Batch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockWriter := NewMockBatchWriter(ctrl)
+	ctx := context.Background()
+
+	t.Run("success", func(t *testing.T) {
+		var input strings.Builder
+		for i := 0; i < importBatchSize+1; i++ {
+			input.WriteString(`{"id":"c","type":"counter","delta":1}` + "\n")
+		}
+		gomock.InOrder(
+			mockWriter.EXPECT().SaveBatch(ctx, gomock.Len(importBatchSize)).Return(nil),
+			mockWriter.EXPECT().SaveBatch(ctx, gomock.Len(1)).Return(nil),
+		)
+
+		n, err := Import(ctx, strings.NewReader(input.String()), mockWriter)
+		require.NoError(t, err)
+		assert.Equal(t, importBatchSize+1, n)
+	})
+
+	t.Run("records before an invalid one are saved", func(t *testing.T) {
+		input := `{"id":"a","type":"gauge","value":1}
+{"id":"b","type":"gauge","value":2}
+{"id":"","type":"gauge","value":3}
+`
+		mockWriter.EXPECT().SaveBatch(ctx, gomock.Len(2)).Return(nil)
+
+		n, err := Import(ctx, strings.NewReader(input), mockWriter)
+		assert.EqualError(t, err, "record 3: invalid metric: empty id")
+		assert.Equal(t, 2, n)
+	})
+
+	t.Run("save error", func(t *testing.T) {
+		input := `{"id":"a","type":"gauge","value":1}
+{"id":"b","type":"gauge","value":2}
+`
+		mockWriter.EXPECT().SaveBatch(ctx, gomock.Len(2)).Return(errors.New("disk full"))
+
+		n, err := Import(ctx, strings.NewReader(input), mockWriter)
+		assert.EqualError(t, err, "records 1-2: disk full")
+		assert.Zero(t, n)
+	})
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		metric  models.Metrics
+		wantErr bool
+	}{
+		{name: "gauge", metric: models.Metrics{ID: "g", MType: models.Gauge, Value: ptrFloat64(1)}},
+		{name: "counter", metric: models.Metrics{ID: "c", MType: models.Counter, Delta: ptrInt64(1)}},
+		{name: "empty id", metric: models.Metrics{ID: " ", MType: models.Gauge, Value: ptrFloat64(1)}, wantErr: true},
+		{name: "gauge without value", metric: models.Metrics{ID: "g", MType: models.Gauge}, wantErr: true},
+		{name: "counter without delta", metric: models.Metrics{ID: "c", MType: models.Counter}, wantErr: true},
+		{name: "unknown type", metric: models.Metrics{ID: "x", MType: "x"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validate(&tt.metric)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidMetric)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}